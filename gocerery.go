@@ -10,11 +10,13 @@ import (
 
 	"gocerery/internal/config"
 	"gocerery/internal/envloader"
+	"gocerery/internal/errorx"
 	"gocerery/internal/handler"
 	"gocerery/internal/svc"
 
 	"github.com/zeromicro/go-zero/core/conf"
 	"github.com/zeromicro/go-zero/rest"
+	"github.com/zeromicro/go-zero/rest/httpx"
 )
 
 var configFile = flag.String("f", "etc/gocerery-api.yaml", "the config file")
@@ -35,6 +37,7 @@ func main() {
 		log.Fatalf("failed to initialize service context: %v", err)
 	}
 	handler.RegisterHandlers(server, ctx)
+	httpx.SetErrorHandlerCtx(errorx.ErrorHandler)
 
 	fmt.Printf("Starting server at %s:%d...\n", c.Host, c.Port)
 	server.Start()
@@ -0,0 +1,188 @@
+package artifact
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gocerery/internal/config"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// 存储模式
+const (
+	ModeLocal = "local"
+	ModeS3    = "s3"
+)
+
+const (
+	defaultDir       = "data/artifacts"
+	defaultMaxSizeMB = 1024
+	// multipartOverhead multipart 请求中文件内容以外的部分（分隔符、字段头）预留的大小
+	multipartOverhead = 1 << 20
+	// maxSweepInterval 清理过期 artifact 的最长间隔
+	maxSweepInterval = time.Hour
+)
+
+// ErrNotFound artifact 不存在或已被清理
+var ErrNotFound = errors.New("artifact not found")
+
+// Meta artifact 的描述信息，与内容一起保存
+type Meta struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"` // 原始文件名，Worker 取回后以此命名
+	Size      int64     `json:"size"`
+	SHA256    string    `json:"sha256"`
+	Owner     string    `json:"owner,omitempty"` // 上传者身份，未启用认证时为空
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Store artifact 的存储后端，API 写入，Worker 读取
+type Store interface {
+	Put(ctx context.Context, meta Meta, body io.Reader) error
+	Stat(ctx context.Context, id string) (Meta, error)
+	Get(ctx context.Context, id string) (io.ReadCloser, Meta, error)
+}
+
+// Sweeper 可删除过期 artifact 的存储，返回删除的数量
+type Sweeper interface {
+	Sweep(ctx context.Context, before time.Time) (int, error)
+}
+
+// New 按配置创建存储；未配置 Mode 时返回 nil
+func New(c config.ArtifactConfig) (Store, error) {
+	switch c.Mode {
+	case "":
+		return nil, nil
+	case ModeLocal:
+		dir := c.Dir
+		if dir == "" {
+			dir = defaultDir
+		}
+		return &localStore{dir: dir}, nil
+	case ModeS3:
+		if c.RetentionHours > 0 {
+			return nil, errors.New("Artifacts.RetentionHours is not supported with Mode=s3, use a bucket lifecycle rule on Artifacts.S3.Prefix")
+		}
+		s, err := newS3Store(c.S3)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	default:
+		return nil, fmt.Errorf("unknown Artifacts.Mode: %s", c.Mode)
+	}
+}
+
+// MaxSize 单个 artifact 的大小上限（字节）
+func MaxSize(c config.ArtifactConfig) int64 {
+	mb := c.MaxSizeMB
+	if mb <= 0 {
+		mb = defaultMaxSizeMB
+	}
+	return int64(mb) << 20
+}
+
+// MaxRequestSize /api/upload/artifact 请求体的大小上限：文件上限加上 multipart 的额外开销
+func MaxRequestSize(c config.ArtifactConfig) int64 {
+	return MaxSize(c) + multipartOverhead
+}
+
+// Retention artifact 的保留时长，未配置时为 0
+func Retention(c config.ArtifactConfig) time.Duration {
+	if c.RetentionHours <= 0 {
+		return 0
+	}
+	return time.Duration(c.RetentionHours) * time.Hour
+}
+
+// RunSweeper 按保留时长定期清理过期 artifact，ctx 结束时返回；存储不支持清理时直接返回
+func RunSweeper(ctx context.Context, s Store, retention time.Duration) {
+	sweeper, ok := s.(Sweeper)
+	if !ok || retention <= 0 {
+		return
+	}
+	interval := min(retention, maxSweepInterval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		removed, err := sweeper.Sweep(ctx, time.Now().Add(-retention))
+		if err != nil {
+			logx.Errorw("artifact sweep failed", logx.Field("error", err))
+		} else if removed > 0 {
+			logx.Infow("expired artifacts removed", logx.Field("count", removed))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// NewID 生成随机的 artifact ID
+func NewID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// ValidID 检查 ID 格式，防止通过 ID 拼出任意路径或对象 key
+func ValidID(id string) bool {
+	if len(id) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil && strings.ToLower(id) == id
+}
+
+// CleanName 取上传文件名的最后一段作为保存的文件名
+func CleanName(name string) (string, error) {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	if name == "." || name == ".." || name == "/" || name == "" {
+		return "", errors.New("file name is required")
+	}
+	return name, nil
+}
+
+// Fetch 把 artifact 取回到 dir 下以原始文件名保存，并校验 sha256，返回文件路径
+func Fetch(ctx context.Context, s Store, id, dir string) (string, error) {
+	body, meta, err := s.Get(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	name, err := CleanName(meta.Name)
+	if err != nil {
+		return "", fmt.Errorf("artifact %s: %w", id, err)
+	}
+
+	localPath := filepath.Join(dir, name)
+	f, err := os.OpenFile(localPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(f, hash), body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("fetch artifact %s: %w", id, err)
+	}
+	if sum := hex.EncodeToString(hash.Sum(nil)); sum != meta.SHA256 {
+		return "", fmt.Errorf("artifact %s checksum mismatch: expected %s, got %s", id, meta.SHA256, sum)
+	}
+	return localPath, nil
+}
@@ -0,0 +1,126 @@
+package artifact
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// localStore 每个 artifact 一个目录：<Dir>/<id>/data 与 <Dir>/<id>/meta.json；
+// API 与 Worker 分开部署时 Dir 需位于共享存储上
+type localStore struct {
+	dir string
+}
+
+func (s *localStore) Put(_ context.Context, meta Meta, body io.Reader) error {
+	dir := filepath.Join(s.dir, meta.ID)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, "data"), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.RemoveAll(dir)
+		return err
+	}
+	// meta.json 最后写入，存在即表示内容已完整
+	data, err := json.Marshal(meta)
+	if err != nil {
+		os.RemoveAll(dir)
+		return err
+	}
+	tmp := filepath.Join(dir, "meta.json.tmp")
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		os.RemoveAll(dir)
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(dir, "meta.json"))
+}
+
+func (s *localStore) Stat(_ context.Context, id string) (Meta, error) {
+	if !ValidID(id) {
+		return Meta{}, ErrNotFound
+	}
+	data, err := os.ReadFile(filepath.Join(s.dir, id, "meta.json"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return Meta{}, ErrNotFound
+	}
+	if err != nil {
+		return Meta{}, err
+	}
+	var meta Meta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return Meta{}, err
+	}
+	return meta, nil
+}
+
+func (s *localStore) Get(ctx context.Context, id string) (io.ReadCloser, Meta, error) {
+	meta, err := s.Stat(ctx, id)
+	if err != nil {
+		return nil, Meta{}, err
+	}
+	f, err := os.Open(filepath.Join(s.dir, id, "data"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, Meta{}, ErrNotFound
+	}
+	if err != nil {
+		return nil, Meta{}, err
+	}
+	return f, meta, nil
+}
+
+// Sweep 删除创建时间早于 before 的 artifact；没有 meta.json 的目录（写入中断）按目录的修改时间判断
+func (s *localStore) Sweep(ctx context.Context, before time.Time) (int, error) {
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, e := range entries {
+		if err := ctx.Err(); err != nil {
+			return removed, err
+		}
+		if !e.IsDir() || !ValidID(e.Name()) {
+			continue
+		}
+		created, err := s.createdAt(e)
+		if err != nil || !created.Before(before) {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(s.dir, e.Name())); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+func (s *localStore) createdAt(e fs.DirEntry) (time.Time, error) {
+	meta, err := s.Stat(context.Background(), e.Name())
+	if err == nil {
+		return meta.CreatedAt, nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return time.Time{}, err
+	}
+	info, err := e.Info()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return info.ModTime(), nil
+}
@@ -0,0 +1,75 @@
+package artifact
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gocerery/internal/config"
+)
+
+func TestLocalStoreSweep(t *testing.T) {
+	dir := t.TempDir()
+	s, err := New(config.ArtifactConfig{Mode: ModeLocal, Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	cutoff := time.Now().Add(-24 * time.Hour)
+	expired := putArtifact(t, s, "old.txt", "old", cutoff.Add(-time.Minute))
+	fresh := putArtifact(t, s, "new.txt", "new", time.Now())
+
+	// 写入中断、没有 meta.json 的目录按修改时间清理
+	partial := filepath.Join(dir, "0123456789abcdef0123456789abcdef")
+	if err := os.MkdirAll(partial, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(partial, old, old); err != nil {
+		t.Fatal(err)
+	}
+	// 不是 artifact 的目录保持不动
+	other := filepath.Join(dir, "keep")
+	if err := os.MkdirAll(other, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(other, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := s.(Sweeper).Sweep(ctx, cutoff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 2 {
+		t.Fatalf("Sweep removed %d, want 2", removed)
+	}
+	if _, err := s.Stat(ctx, expired.ID); err != ErrNotFound {
+		t.Fatalf("expired artifact still present: %v", err)
+	}
+	if _, err := s.Stat(ctx, fresh.ID); err != nil {
+		t.Fatalf("fresh artifact removed: %v", err)
+	}
+	for _, p := range []string{partial, other} {
+		_, err := os.Stat(p)
+		if want := p == other; (err == nil) != want {
+			t.Fatalf("%s exists = %v, want %v", p, err == nil, want)
+		}
+	}
+
+	missing := &localStore{dir: filepath.Join(dir, "missing")}
+	if n, err := missing.Sweep(ctx, time.Now()); n != 0 || err != nil {
+		t.Fatalf("Sweep(missing dir) = %d, %v", n, err)
+	}
+}
+
+func TestMaxRequestSize(t *testing.T) {
+	if got := MaxRequestSize(config.ArtifactConfig{}); got != 1025<<20 {
+		t.Fatalf("MaxRequestSize(default) = %d", got)
+	}
+	if got := MaxRequestSize(config.ArtifactConfig{MaxSizeMB: 10}); got != 11<<20 {
+		t.Fatalf("MaxRequestSize(10 MB) = %d", got)
+	}
+}
@@ -0,0 +1,204 @@
+package artifact
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"gocerery/internal/config"
+)
+
+const (
+	defaultS3Region = "us-east-1"
+	defaultS3Prefix = "artifacts/"
+)
+
+// s3Store 通过 S3 兼容接口（AWS S3、MinIO 等）保存 artifact，使用 path-style 地址与 SigV4 签名；
+// 对象 key 为 <Prefix><id>/data 与 <Prefix><id>/meta.json
+type s3Store struct {
+	endpoint  string
+	region    string
+	bucket    string
+	prefix    string
+	accessKey string
+	secretKey string
+	client    *http.Client
+}
+
+func newS3Store(c config.S3Config) (*s3Store, error) {
+	if c.Endpoint == "" || c.Bucket == "" {
+		return nil, errors.New("Artifacts.S3.Endpoint and Artifacts.S3.Bucket are required")
+	}
+	if c.AccessKey == "" || c.SecretKey == "" {
+		return nil, errors.New("Artifacts.S3.AccessKey and Artifacts.S3.SecretKey are required")
+	}
+	s := &s3Store{
+		endpoint:  strings.TrimRight(c.Endpoint, "/"),
+		region:    c.Region,
+		bucket:    c.Bucket,
+		prefix:    c.Prefix,
+		accessKey: c.AccessKey,
+		secretKey: c.SecretKey,
+		client:    &http.Client{},
+	}
+	if s.region == "" {
+		s.region = defaultS3Region
+	}
+	if s.prefix == "" {
+		s.prefix = defaultS3Prefix
+	}
+	return s, nil
+}
+
+func (s *s3Store) Put(ctx context.Context, meta Meta, body io.Reader) error {
+	if err := s.putObject(ctx, s.key(meta.ID, "data"), body, meta.Size, meta.SHA256); err != nil {
+		return err
+	}
+	// meta.json 最后写入，存在即表示内容已完整
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+	return s.putObject(ctx, s.key(meta.ID, "meta.json"), bytes.NewReader(data), int64(len(data)), hex.EncodeToString(sum[:]))
+}
+
+func (s *s3Store) Stat(ctx context.Context, id string) (Meta, error) {
+	if !ValidID(id) {
+		return Meta{}, ErrNotFound
+	}
+	body, err := s.getObject(ctx, s.key(id, "meta.json"))
+	if err != nil {
+		return Meta{}, err
+	}
+	defer body.Close()
+	var meta Meta
+	if err := json.NewDecoder(io.LimitReader(body, 1<<20)).Decode(&meta); err != nil {
+		return Meta{}, fmt.Errorf("decode artifact meta: %w", err)
+	}
+	return meta, nil
+}
+
+func (s *s3Store) Get(ctx context.Context, id string) (io.ReadCloser, Meta, error) {
+	meta, err := s.Stat(ctx, id)
+	if err != nil {
+		return nil, Meta{}, err
+	}
+	body, err := s.getObject(ctx, s.key(id, "data"))
+	if err != nil {
+		return nil, Meta{}, err
+	}
+	return body, meta, nil
+}
+
+func (s *s3Store) key(id, name string) string {
+	return s.prefix + id + "/" + name
+}
+
+func (s *s3Store) putObject(ctx context.Context, key string, body io.Reader, size int64, payloadHash string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectURL(key), body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	s.sign(req, payloadHash, time.Now())
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("put object %s: %w", key, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return s3Error("put object", key, resp)
+	}
+	return nil
+}
+
+func (s *s3Store) getObject(ctx context.Context, key string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.objectURL(key), nil)
+	if err != nil {
+		return nil, err
+	}
+	s.sign(req, emptyPayloadHash, time.Now())
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("get object %s: %w", key, err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		return nil, s3Error("get object", key, resp)
+	}
+	return resp.Body, nil
+}
+
+func (s *s3Store) objectURL(key string) string {
+	return s.endpoint + "/" + escapePath(s.bucket+"/"+key)
+}
+
+func s3Error(op, key string, resp *http.Response) error {
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("%s %s: %s: %s", op, key, resp.Status, strings.TrimSpace(string(msg)))
+}
+
+// emptyPayloadHash 空请求体的 sha256
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// sign 按 AWS Signature Version 4 为请求签名，签名头为 host、x-amz-content-sha256、x-amz-date
+func (s *s3Store) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	const signedHeaders = "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.Query().Encode(),
+		"host:" + req.URL.Host + "\n" +
+			"x-amz-content-sha256:" + payloadHash + "\n" +
+			"x-amz-date:" + amzDate + "\n",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + s.region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), date)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// escapePath 按 SigV4 的要求逐段编码对象路径，保留 /
+func escapePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, seg := range segments {
+		segments[i] = strings.ReplaceAll(url.PathEscape(seg), "+", "%2B")
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,227 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gocerery/internal/config"
+
+	"github.com/gocelery/gocelery"
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// 审计事件类型
+const (
+	EventTaskSubmitted = "task_submitted" // API 已提交到 Celery
+	EventTaskRejected  = "task_rejected"  // 被命令防护或授权策略拒绝
+	EventTaskStarted   = "task_started"   // Worker 开始执行
+	EventTaskFinished  = "task_finished"  // Worker 执行结束
+)
+
+// 事件来源
+const (
+	SourceAPI    = "api"
+	SourceWorker = "worker"
+)
+
+// 任务类型
+const (
+	KindSSH      = "ssh"
+	KindUpload   = "upload"
+	KindDownload = "download"
+)
+
+// 审计存储方式
+const (
+	ModeFile  = "file"
+	ModeRedis = "redis"
+)
+
+const (
+	defaultPath   = "logs/audit.jsonl"
+	defaultStream = "gocerery-audit"
+	defaultMaxLen = 100000
+
+	defaultLimit = 100
+	maxLimit     = 1000
+)
+
+// ErrDisabled 未配置 Audit.Mode 时查询返回的错误
+var ErrDisabled = errors.New("audit log is not configured")
+
+// Event 一条审计记录；只记录主机地址与账号，凭据不会写入，命令经过脱敏
+type Event struct {
+	Time         time.Time     `json:"time"`
+	Type         string        `json:"type"`
+	Source       string        `json:"source"`
+	Kind         string        `json:"kind"`
+	TaskID       string        `json:"task_id,omitempty"`
+	Principal    string        `json:"principal,omitempty"`
+	SourceIP     string        `json:"source_ip,omitempty"`
+	Targets      []Target      `json:"targets,omitempty"`
+	Commands     []string      `json:"commands,omitempty"`
+	LocalPath    string        `json:"local_path,omitempty"`
+	ArtifactID   string        `json:"artifact_id,omitempty"`
+	RemotePath   string        `json:"remote_path,omitempty"`
+	RemotePaths  []string      `json:"remote_paths,omitempty"` // 下载任务的远程路径（glob）
+	DangerousAck bool          `json:"dangerous_ack,omitempty"`
+	Status       string        `json:"status,omitempty"`
+	Error        string        `json:"error,omitempty"`
+	Hosts        []HostOutcome `json:"hosts,omitempty"`
+}
+
+// Target 任务的目标主机
+type Target struct {
+	Name string `json:"name,omitempty"`
+	Host string `json:"host"`
+	Port int    `json:"port,omitempty"`
+	User string `json:"user,omitempty"`
+}
+
+// HostOutcome 单台主机的执行结果
+type HostOutcome struct {
+	Name     string `json:"name,omitempty"`
+	Host     string `json:"host"`
+	Success  bool   `json:"success"`
+	ExitCode int    `json:"exit_code,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Filter 查询条件，零值字段不过滤；结果按时间倒序，最多 Limit 条
+type Filter struct {
+	Since  time.Time
+	Until  time.Time
+	User   string
+	Host   string
+	TaskID string
+	Limit  int
+}
+
+// sink 审计记录的存储，只追加不修改
+type sink interface {
+	write(ev Event) error
+	query(f Filter) ([]Event, error)
+}
+
+// Recorder 写入与查询审计记录；nil 表示未启用，Record 为空操作
+type Recorder struct {
+	sink sink
+}
+
+// New 按 Audit.Mode 创建存储；redis 模式复用 Celery Backend 的连接池
+func New(c config.AuditConfig, backend *gocelery.RedisCeleryBackend) (*Recorder, error) {
+	switch c.Mode {
+	case "":
+		return nil, nil
+	case ModeFile:
+		path := c.Path
+		if path == "" {
+			path = defaultPath
+		}
+		s, err := newFileSink(path)
+		if err != nil {
+			return nil, err
+		}
+		return &Recorder{sink: s}, nil
+	case ModeRedis:
+		if backend == nil {
+			return nil, errors.New("Audit.Mode redis requires Celery.Backend")
+		}
+		stream := c.Stream
+		if stream == "" {
+			stream = defaultStream
+		}
+		maxLen := c.MaxLen
+		if maxLen <= 0 {
+			maxLen = defaultMaxLen
+		}
+		return &Recorder{sink: &redisSink{backend: backend, stream: stream, maxLen: maxLen}}, nil
+	default:
+		return nil, fmt.Errorf("unknown Audit.Mode: %s", c.Mode)
+	}
+}
+
+// Enabled 是否启用了审计；nil 安全
+func (r *Recorder) Enabled() bool {
+	return r != nil
+}
+
+// Record 脱敏后追加一条记录；审计写入失败不影响任务，只打印日志
+func (r *Recorder) Record(ev Event) {
+	if r == nil {
+		return
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	ev.Commands = RedactCommands(ev.Commands)
+	ev.Error = Redact(ev.Error)
+	for i := range ev.Hosts {
+		ev.Hosts[i].Error = Redact(ev.Hosts[i].Error)
+	}
+	if err := r.sink.write(ev); err != nil {
+		logx.Errorw("failed to write audit event",
+			logx.Field("type", ev.Type),
+			logx.Field("task_id", ev.TaskID),
+			logx.Field("error", err))
+	}
+}
+
+// Query 按条件查询审计记录
+func (r *Recorder) Query(f Filter) ([]Event, error) {
+	if r == nil {
+		return nil, ErrDisabled
+	}
+	switch {
+	case f.Limit <= 0:
+		f.Limit = defaultLimit
+	case f.Limit > maxLimit:
+		f.Limit = maxLimit
+	}
+	return r.sink.query(f)
+}
+
+// match 判断事件是否满足过滤条件，Host 匹配目标主机的名称或地址
+func (f Filter) match(ev Event) bool {
+	if !f.Since.IsZero() && ev.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && ev.Time.After(f.Until) {
+		return false
+	}
+	if f.User != "" && ev.Principal != f.User {
+		return false
+	}
+	if f.TaskID != "" && ev.TaskID != f.TaskID {
+		return false
+	}
+	if f.Host == "" {
+		return true
+	}
+	for _, t := range ev.Targets {
+		if t.Host == f.Host || t.Name == f.Host {
+			return true
+		}
+	}
+	for _, h := range ev.Hosts {
+		if h.Host == f.Host || h.Name == f.Host {
+			return true
+		}
+	}
+	return false
+}
+
+type sourceIPKey struct{}
+
+// WithSourceIP 把请求来源地址写入 context，供提交任务时记录
+func WithSourceIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, sourceIPKey{}, ip)
+}
+
+// SourceIP 读取请求来源地址
+func SourceIP(ctx context.Context) string {
+	ip, _ := ctx.Value(sourceIPKey{}).(string)
+	return ip
+}
@@ -0,0 +1,78 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+// fileSink 以 JSONL 追加写入本地文件，每行一条记录；API 与 Worker 各自写本机文件
+type fileSink struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+func newFileSink(path string) (*fileSink, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create audit log dir: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open audit log: %w", err)
+	}
+	return &fileSink{path: path, file: file}, nil
+}
+
+func (s *fileSink) write(ev Event) error {
+	line, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// O_APPEND 下单次 Write 整行写入，多个进程写同一文件时行不会交错
+	_, err = s.file.Write(line)
+	return err
+}
+
+// query 顺序扫描整个文件，只保留最近的 Limit 条匹配记录；
+// 只能读到写入本机 Path 的记录，Worker 的记录需要与 API 共享同一文件才能查到
+func (s *fileSink) query(f Filter) ([]Event, error) {
+	file, err := os.Open(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("open audit log: %w", err)
+	}
+	defer file.Close()
+
+	var events []Event
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			var ev Event
+			// 跳过写入中断留下的残行
+			if json.Unmarshal(line, &ev) == nil && f.match(ev) {
+				events = append(events, ev)
+				if len(events) > f.Limit {
+					events = events[1:]
+				}
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read audit log: %w", err)
+		}
+	}
+	slices.Reverse(events)
+	return events, nil
+}
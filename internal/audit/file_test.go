@@ -0,0 +1,59 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"gocerery/internal/config"
+)
+
+func TestFileQuery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	rec, err := New(config.AuditConfig{Mode: ModeFile, Path: path}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		rec.Record(Event{Time: base.Add(time.Duration(i) * time.Minute), Type: EventTaskSubmitted, Source: SourceAPI,
+			Kind: KindSSH, TaskID: "task-" + strconv.Itoa(i), Targets: []Target{{Name: "web-" + strconv.Itoa(i%2), Host: "10.0.0.1"}}})
+	}
+	// 写入中断留下的残行被跳过
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"time":"2026-01-01T00:10:00Z","type":"task_sub`)
+	f.Close()
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []string
+	}{
+		{name: "all", filter: Filter{}, want: []string{"task-4", "task-3", "task-2", "task-1", "task-0"}},
+		{name: "limit keeps newest", filter: Filter{Limit: 2}, want: []string{"task-4", "task-3"}},
+		{name: "host name", filter: Filter{Host: "web-1"}, want: []string{"task-3", "task-1"}},
+		{name: "time range", filter: Filter{Since: base.Add(time.Minute), Until: base.Add(2 * time.Minute)}, want: []string{"task-2", "task-1"}},
+		{name: "task id", filter: Filter{TaskID: "task-0"}, want: []string{"task-0"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := rec.Query(tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, ev := range events {
+				got = append(got, ev.TaskID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Query(%+v) = %v, want %v", tt.filter, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package audit
+
+import "regexp"
+
+// redactMask 替换敏感值的占位符
+const redactMask = "***"
+
+// redactRules 命令中常见的凭据写法：KEY=value、--password value / --pass=value、mysql -pxxx、
+// Authorization 请求头与 URL 中的 user:password@
+var redactRules = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	{regexp.MustCompile(`(?i)(\b[\w-]*(?:password|passwd|pwd|secret|token|api[_-]?key|access[_-]?key|private[_-]?key)[\w-]*\s*[=:]\s*)("[^"]*"|'[^']*'|[^\s'"]+)`), "${1}" + redactMask},
+	{regexp.MustCompile(`(?i)(--?(?:password|passwd|pass|token|secret)(?:\s+|=))("[^"]*"|'[^']*'|[^\s'"]+)`), "${1}" + redactMask},
+	{regexp.MustCompile(`(\bmysql(?:dump|admin)?\s(?:.*\s)?-p)("[^"]*"|'[^']*'|[^\s'"]+)`), "${1}" + redactMask},
+	{regexp.MustCompile(`(?i)(authorization:\s*(?:bearer|basic|token)\s+)[^\s'"]+`), "${1}" + redactMask},
+	{regexp.MustCompile(`(://[^/\s:@]+:)[^/\s@]+@`), "${1}" + redactMask + "@"},
+}
+
+// Redact 把字符串中疑似凭据的部分替换为 ***
+func Redact(s string) string {
+	if s == "" {
+		return s
+	}
+	for _, r := range redactRules {
+		s = r.re.ReplaceAllString(s, r.repl)
+	}
+	return s
+}
+
+// RedactCommands 逐条脱敏，不修改传入的切片
+func RedactCommands(commands []string) []string {
+	if len(commands) == 0 {
+		return commands
+	}
+	redacted := make([]string, len(commands))
+	for i, c := range commands {
+		redacted[i] = Redact(c)
+	}
+	return redacted
+}
@@ -0,0 +1,124 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gocelery/gocelery"
+	"github.com/gomodule/redigo/redis"
+)
+
+// redisBatch 每次 XREVRANGE 读取的条数，过滤后不足 Limit 时继续向前翻页
+const redisBatch = 500
+
+// idSlack 记录时间早于 XADD 生成的 Stream ID，按 ID 取范围时放宽上界，再按记录时间精确过滤
+const idSlack = time.Minute
+
+// redisSink 写入 Redis Stream，API 与 Worker 的记录集中在同一个 key 中；
+// Stream ID 以毫秒时间戳开头，按时间范围查询时用作 XREVRANGE 的边界
+type redisSink struct {
+	backend *gocelery.RedisCeleryBackend
+	stream  string
+	maxLen  int64
+}
+
+func (s *redisSink) write(ev Event) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	conn := s.backend.Get()
+	defer conn.Close()
+	_, err = conn.Do("XADD", s.stream, "MAXLEN", "~", s.maxLen, "*", "event", data)
+	return err
+}
+
+func (s *redisSink) query(f Filter) ([]Event, error) {
+	end, start := "+", "-"
+	if !f.Until.IsZero() {
+		end = strconv.FormatInt(f.Until.Add(idSlack).UnixMilli(), 10)
+	}
+	if !f.Since.IsZero() {
+		start = strconv.FormatInt(f.Since.UnixMilli(), 10)
+	}
+
+	conn := s.backend.Get()
+	defer conn.Close()
+	var events []Event
+	for len(events) < f.Limit {
+		entries, err := redis.Values(conn.Do("XREVRANGE", s.stream, end, start, "COUNT", redisBatch))
+		if err != nil {
+			return nil, fmt.Errorf("read audit stream: %w", err)
+		}
+		var lastID string
+		for _, entry := range entries {
+			id, ev, err := parseStreamEntry(entry)
+			if err != nil {
+				return nil, err
+			}
+			lastID = id
+			if ev != nil && f.match(*ev) {
+				events = append(events, *ev)
+				if len(events) == f.Limit {
+					break
+				}
+			}
+		}
+		if len(entries) < redisBatch || lastID == "" {
+			break
+		}
+		end = prevStreamID(lastID)
+		if end == "" {
+			break
+		}
+	}
+	return events, nil
+}
+
+// parseStreamEntry 解析 XREVRANGE 返回的 [id, [field, value, ...]]；无法解析的记录返回 nil
+func parseStreamEntry(entry interface{}) (string, *Event, error) {
+	parts, err := redis.Values(entry, nil)
+	if err != nil || len(parts) != 2 {
+		return "", nil, fmt.Errorf("unexpected audit stream entry: %v", entry)
+	}
+	id, err := redis.String(parts[0], nil)
+	if err != nil {
+		return "", nil, err
+	}
+	fields, err := redis.StringMap(parts[1], nil)
+	if err != nil {
+		return "", nil, err
+	}
+	var ev Event
+	if json.Unmarshal([]byte(fields["event"]), &ev) != nil {
+		return id, nil, nil
+	}
+	return id, &ev, nil
+}
+
+// prevStreamID 返回紧邻 id 之前的 Stream ID，用作下一页的闭区间上界
+func prevStreamID(id string) string {
+	msPart, seqPart, ok := strings.Cut(id, "-")
+	if !ok {
+		return ""
+	}
+	ms, err := strconv.ParseUint(msPart, 10, 64)
+	if err != nil {
+		return ""
+	}
+	seq, err := strconv.ParseUint(seqPart, 10, 64)
+	if err != nil {
+		return ""
+	}
+	if seq > 0 {
+		return fmt.Sprintf("%d-%d", ms, seq-1)
+	}
+	if ms == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d-%d", ms-1, uint64(math.MaxUint64))
+}
@@ -0,0 +1,28 @@
+package auth
+
+import "context"
+
+// 认证方式
+const (
+	MethodAPIKey = "api_key"
+	MethodJWT    = "jwt"
+)
+
+// Identity 通过认证的调用方，随任务一起记录
+type Identity struct {
+	Name   string // API Key 的名称或 JWT 中的身份 claim
+	Method string // api_key 或 jwt
+}
+
+type identityKey struct{}
+
+// WithIdentity 把调用方身份写入上下文
+func WithIdentity(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// FromContext 读取调用方身份；未启用认证时返回 false
+func FromContext(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(Identity)
+	return id, ok
+}
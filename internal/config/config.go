@@ -8,36 +8,195 @@ import "github.com/zeromicro/go-zero/rest"
 // 配置文件结构体
 type Config struct {
 	rest.RestConf
-	Bastion   BastionConfig  `json:"Bastion" yaml:"Bastion" mapstructure:"Bastion"`
-	Targets   []TargetConfig `json:"Targets" yaml:"Targets" mapstructure:"Targets"`
-	Executor  ExecutorConfig `json:"Executor" yaml:"Executor" mapstructure:"Executor"`
-	Celery    CeleryConfig   `json:"Celery" yaml:"Celery" mapstructure:"Celery"`
-	WorkerLog LogConfig      `json:"WorkerLog" yaml:"WorkerLog" mapstructure:"WorkerLog"` // Worker 日志配置
+	Bastion   BastionConfig   `json:"Bastion,optional" yaml:"Bastion" mapstructure:"Bastion"`    // 默认跳板机，请求未指定 jump_hosts/proxy_* 且未设置 direct 时使用
+	Bastions  []BastionConfig `json:"Bastions,optional" yaml:"Bastions" mapstructure:"Bastions"` // 命名跳板机，请求通过 jump_hosts[].name 或 proxy_name 选择
+	Targets   []TargetConfig  `json:"Targets" yaml:"Targets" mapstructure:"Targets"`
+	Executor  ExecutorConfig  `json:"Executor" yaml:"Executor" mapstructure:"Executor"`
+	Celery    CeleryConfig    `json:"Celery" yaml:"Celery" mapstructure:"Celery"`
+	WorkerLog LogConfig       `json:"WorkerLog" yaml:"WorkerLog" mapstructure:"WorkerLog"` // Worker 日志配置
+	Auth      AuthConfig      `json:"Auth,optional" yaml:"Auth" mapstructure:"Auth"`       // API 认证，未配置 APIKeys 与 AccessSecret 时不校验
+	Policy    PolicyConfig    `json:"Policy,optional" yaml:"Policy" mapstructure:"Policy"` // 授权策略，未配置 Roles 时不做检查
+	// 命令防护，API 提交前与 Worker 执行前各检查一次
+	CommandGuard CommandGuardConfig `json:"CommandGuard,optional" yaml:"CommandGuard" mapstructure:"CommandGuard"`
+	// 审计日志，API 记录任务提交，Worker 记录执行开始与结束
+	Audit AuditConfig `json:"Audit,optional" yaml:"Audit" mapstructure:"Audit"`
+	// Celery 消息中的凭据加密，API 与 Worker 使用同一组密钥
+	CredentialEncryption CredentialEncryptionConfig `json:"CredentialEncryption,optional" yaml:"CredentialEncryption" mapstructure:"CredentialEncryption"`
+	// password_ref / key_ref 引用的密钥来源，由 Worker 解析
+	Secrets SecretsConfig `json:"Secrets,optional" yaml:"Secrets" mapstructure:"Secrets"`
+	// 通过 API 上传的文件内容，上传任务可用 artifact_id 代替 local_path
+	Artifacts ArtifactConfig `json:"Artifacts,optional" yaml:"Artifacts" mapstructure:"Artifacts"`
+	// 下载任务从目标主机取回的文件
+	Downloads DownloadConfig `json:"Downloads,optional" yaml:"Downloads" mapstructure:"Downloads"`
+}
+
+// 下载任务配置：Worker 把文件写入 Dir/<task_id>/<主机>/；API 读取同一位置提供列表与打包下载
+type DownloadConfig struct {
+	Store     string `json:"Store,optional" yaml:"Store" mapstructure:"Store"`             // local（默认）: 保留在 Dir，API 与 Worker 分开部署时需为共享存储；artifact: 逐个写入 Artifacts 存储后删除本地文件
+	Dir       string `json:"Dir,optional" yaml:"Dir" mapstructure:"Dir"`                   // 默认 data/downloads
+	MaxSizeMB int    `json:"MaxSizeMB,optional" yaml:"MaxSizeMB" mapstructure:"MaxSizeMB"` // 单台主机取回的总大小上限，默认 1024
+}
+
+// artifact 存储配置：Mode 为空时不启用 /api/upload/artifact；API 与 Worker 需访问同一存储
+type ArtifactConfig struct {
+	Mode           string   `json:"Mode,optional" yaml:"Mode" mapstructure:"Mode"`                               // local: 本地（或共享）目录, s3: S3 兼容对象存储
+	Dir            string   `json:"Dir,optional" yaml:"Dir" mapstructure:"Dir"`                                  // Mode=local 时的目录，默认 data/artifacts
+	MaxSizeMB      int      `json:"MaxSizeMB,optional" yaml:"MaxSizeMB" mapstructure:"MaxSizeMB"`                // 单个文件大小上限，默认 1024
+	RetentionHours int      `json:"RetentionHours,optional" yaml:"RetentionHours" mapstructure:"RetentionHours"` // Mode=local 时 API 删除超过该时长的 artifact，0 为不清理
+	S3             S3Config `json:"S3,optional" yaml:"S3" mapstructure:"S3"`
+}
+
+// S3 兼容对象存储配置，使用 path-style 地址
+type S3Config struct {
+	Endpoint  string `json:"Endpoint,optional" yaml:"Endpoint" mapstructure:"Endpoint"` // 如 https://s3.ap-east-1.amazonaws.com、http://minio:9000
+	Region    string `json:"Region,optional" yaml:"Region" mapstructure:"Region"`       // 默认 us-east-1
+	Bucket    string `json:"Bucket,optional" yaml:"Bucket" mapstructure:"Bucket"`
+	Prefix    string `json:"Prefix,optional" yaml:"Prefix" mapstructure:"Prefix"` // 对象 key 前缀，默认 artifacts/
+	AccessKey string `json:"AccessKey,optional" yaml:"AccessKey" mapstructure:"AccessKey"`
+	SecretKey string `json:"SecretKey,optional" yaml:"SecretKey" mapstructure:"SecretKey"`
+}
+
+// 密钥引用配置：env:、file: 只能引用这里列出的变量与目录，未配置的前缀不可用
+type SecretsConfig struct {
+	Env      []string    `json:"Env,optional" yaml:"Env" mapstructure:"Env"`                // env: 可引用的环境变量名，支持 * 通配，如 DB*_PASS
+	FileDirs []string    `json:"FileDirs,optional" yaml:"FileDirs" mapstructure:"FileDirs"` // file: 可引用的目录（绝对路径）
+	Vault    VaultConfig `json:"Vault,optional" yaml:"Vault" mapstructure:"Vault"`
+	// 请求中直接给出的主机（非主机清单与 Bastions）中允许使用引用的地址，支持 * 通配；
+	// 为空时引用只能用于配置中为该主机指定的 PasswordRef/KeyRef
+	AllowedHosts []string `json:"AllowedHosts,optional" yaml:"AllowedHosts" mapstructure:"AllowedHosts"`
+}
+
+// Vault 配置：通过 HTTP API 读取 KV 密钥，Address 为空时不启用 vault:
+type VaultConfig struct {
+	Address        string   `json:"Address,optional" yaml:"Address" mapstructure:"Address"` // 如 https://vault.example.com:8200
+	Token          string   `json:"Token,optional" yaml:"Token" mapstructure:"Token"`
+	Namespace      string   `json:"Namespace,optional" yaml:"Namespace" mapstructure:"Namespace"`
+	Paths          []string `json:"Paths,optional" yaml:"Paths" mapstructure:"Paths"`                            // 允许读取的路径前缀，为空时只受 Token 权限限制
+	TimeoutSeconds int      `json:"TimeoutSeconds,optional" yaml:"TimeoutSeconds" mapstructure:"TimeoutSeconds"` // 单次请求超时，默认 10 秒
+}
+
+// 凭据加密配置：API 用 ActiveKeyID 对应的密钥加密，Worker 按消息中的密钥 ID 解密；
+// 轮换时先在所有 Worker 上添加新密钥，再切换 API 的 ActiveKeyID，旧消息消费完后移除旧密钥
+type CredentialEncryptionConfig struct {
+	ActiveKeyID      string                `json:"ActiveKeyID,optional" yaml:"ActiveKeyID" mapstructure:"ActiveKeyID"`                // 为空时 API 不加密
+	Keys             []CredentialKeyConfig `json:"Keys,optional" yaml:"Keys" mapstructure:"Keys"`                                     // 全部可用于解密的密钥
+	RequireEncrypted bool                  `json:"RequireEncrypted,optional" yaml:"RequireEncrypted" mapstructure:"RequireEncrypted"` // Worker 拒绝带明文凭据、或未加密却带有指纹/凭据引用的消息
+}
+
+// 凭据加密密钥：base64 编码的 32 字节 AES-256 密钥，从环境变量或文件读取，不写入配置文件
+type CredentialKeyConfig struct {
+	ID      string `json:"ID" yaml:"ID" mapstructure:"ID"`
+	KeyEnv  string `json:"KeyEnv,optional" yaml:"KeyEnv" mapstructure:"KeyEnv"`    // 保存密钥的环境变量名
+	KeyFile string `json:"KeyFile,optional" yaml:"KeyFile" mapstructure:"KeyFile"` // 保存密钥的文件路径
+}
+
+// 审计日志配置：Mode 为空时不记录
+type AuditConfig struct {
+	Mode    string   `json:"Mode,optional" yaml:"Mode" mapstructure:"Mode"`          // file: 本地 JSONL 文件（查询只能看到本机记录）, redis: Celery Backend 中的 Redis Stream
+	Path    string   `json:"Path,optional" yaml:"Path" mapstructure:"Path"`          // Mode=file 时的文件路径，默认 logs/audit.jsonl
+	Stream  string   `json:"Stream,optional" yaml:"Stream" mapstructure:"Stream"`    // Mode=redis 时的 Stream key，默认 gocerery-audit
+	MaxLen  int64    `json:"MaxLen,optional" yaml:"MaxLen" mapstructure:"MaxLen"`    // Mode=redis 时保留的大致条数，默认 100000
+	Readers []string `json:"Readers,optional" yaml:"Readers" mapstructure:"Readers"` // 允许查询审计日志的调用方，为空时不限制
+}
+
+// 命令防护：命中 Deny 规则或不匹配任一 Allow 规则的命令被拒绝；
+// 软规则可由请求的 dangerous_ack 放行，硬规则始终生效
+type CommandGuardConfig struct {
+	DisableDefaultRules bool              `json:"DisableDefaultRules,optional" yaml:"DisableDefaultRules" mapstructure:"DisableDefaultRules"` // 不使用内置的 Deny 规则（rm -rf /、mkfs、shutdown 等）
+	Deny                []GuardRuleConfig `json:"Deny,optional" yaml:"Deny" mapstructure:"Deny"`                                              // 追加在内置规则之后
+	Allow               []string          `json:"Allow,optional" yaml:"Allow" mapstructure:"Allow"`                                           // 正则，非空时命令必须匹配其中之一
+	AllowHard           bool              `json:"AllowHard,optional" yaml:"AllowHard" mapstructure:"AllowHard"`                               // 不匹配 Allow 时按硬规则处理，dangerous_ack 无法放行
+}
+
+// 命令防护规则：Pattern 为 Go 正则，在命令任意位置匹配即命中
+type GuardRuleConfig struct {
+	Name    string `json:"Name" yaml:"Name" mapstructure:"Name"`
+	Pattern string `json:"Pattern" yaml:"Pattern" mapstructure:"Pattern"`
+	Hard    bool   `json:"Hard,optional" yaml:"Hard" mapstructure:"Hard"` // 硬规则不能被 dangerous_ack 放行
+}
+
+// API 认证配置：请求携带 X-API-Key 或 Authorization: Bearer <JWT> 之一
+type AuthConfig struct {
+	AccessSecret     string         `json:"AccessSecret,optional" yaml:"AccessSecret" mapstructure:"AccessSecret"`             // JWT 签名密钥（HS256），为空时不接受 JWT
+	PrevAccessSecret string         `json:"PrevAccessSecret,optional" yaml:"PrevAccessSecret" mapstructure:"PrevAccessSecret"` // 密钥轮换期间仍接受的旧密钥
+	IdentityClaim    string         `json:"IdentityClaim,optional" yaml:"IdentityClaim" mapstructure:"IdentityClaim"`          // JWT 中作为调用方身份的 claim，默认 sub
+	APIKeys          []APIKeyConfig `json:"APIKeys,optional" yaml:"APIKeys" mapstructure:"APIKeys"`
+}
+
+// 授权策略：调用方通过 Bindings 获得角色，每台目标主机上的每条命令都需要被允许该主机的某个角色允许
+type PolicyConfig struct {
+	Roles    []RoleConfig    `json:"Roles,optional" yaml:"Roles" mapstructure:"Roles"`
+	Bindings []BindingConfig `json:"Bindings,optional" yaml:"Bindings" mapstructure:"Bindings"`
+}
+
+// 角色：允许在哪些目标主机上执行哪些命令、上传到哪些目录、从哪些路径下载
+type RoleConfig struct {
+	Name        string   `json:"Name" yaml:"Name" mapstructure:"Name"`
+	Commands    []string `json:"Commands,optional" yaml:"Commands" mapstructure:"Commands"`          // 命令模板，* 匹配不含空白与 shell 元字符的字符串；单独的 * 允许任意命令
+	Groups      []string `json:"Groups,optional" yaml:"Groups" mapstructure:"Groups"`                // 主机清单分组，* 表示清单中的全部主机
+	Targets     []string `json:"Targets,optional" yaml:"Targets" mapstructure:"Targets"`             // 主机清单名称（glob）
+	Hosts       []string `json:"Hosts,optional" yaml:"Hosts" mapstructure:"Hosts"`                   // 主机地址（glob），请求中直接给出的 targets 只能按地址授权
+	UploadPaths []string `json:"UploadPaths,optional" yaml:"UploadPaths" mapstructure:"UploadPaths"` // 允许上传到的远程目录（含子目录），为空时不允许上传
+	LocalPaths  []string `json:"LocalPaths,optional" yaml:"LocalPaths" mapstructure:"LocalPaths"`    // 允许上传的 Worker 本地路径（含子目录），为空时不限制
+	// 允许下载的远程路径（含子目录），为空时不允许下载
+	DownloadPaths []string `json:"DownloadPaths,optional" yaml:"DownloadPaths" mapstructure:"DownloadPaths"`
+}
+
+// 角色绑定：Principal 为 API Key 名称或 JWT 身份，* 表示所有已认证的调用方
+type BindingConfig struct {
+	Principal string   `json:"Principal" yaml:"Principal" mapstructure:"Principal"`
+	Roles     []string `json:"Roles" yaml:"Roles" mapstructure:"Roles"`
+}
+
+// 静态 API Key，Name 作为调用方身份记录到任务
+type APIKeyConfig struct {
+	Name string `json:"Name" yaml:"Name" mapstructure:"Name"`
+	Key  string `json:"Key" yaml:"Key" mapstructure:"Key"`
 }
 
 // 跳板机配置
 type BastionConfig struct {
-	Host     string `json:"Host" yaml:"Host" mapstructure:"Host"`
-	Port     int    `json:"Port" yaml:"Port" mapstructure:"Port"`
-	User     string `json:"User" yaml:"User" mapstructure:"User"`
-	Password string `json:"Password" yaml:"Password" mapstructure:"Password"`
+	Name                 string `json:"Name,optional" yaml:"Name" mapstructure:"Name"` // Bastions 中必填，对应请求的 proxy_name
+	Host                 string `json:"Host,optional" yaml:"Host" mapstructure:"Host"`
+	Port                 int    `json:"Port,optional" yaml:"Port" mapstructure:"Port"`
+	User                 string `json:"User,optional" yaml:"User" mapstructure:"User"`
+	Password             string `json:"Password,optional" yaml:"Password" mapstructure:"Password"`
+	PrivateKey           string `json:"PrivateKey,optional" yaml:"PrivateKey" mapstructure:"PrivateKey"`
+	PrivateKeyPassphrase string `json:"PrivateKeyPassphrase,optional" yaml:"PrivateKeyPassphrase" mapstructure:"PrivateKeyPassphrase"`
+	KeyRef               string `json:"KeyRef,optional" yaml:"KeyRef" mapstructure:"KeyRef"`
+	PasswordRef          string `json:"PasswordRef,optional" yaml:"PasswordRef" mapstructure:"PasswordRef"`
+	HostKeyFingerprint   string `json:"HostKeyFingerprint,optional" yaml:"HostKeyFingerprint" mapstructure:"HostKeyFingerprint"`
 }
 
-// 目标主机配置
+// 目标主机配置（主机清单），请求可通过 target_names/groups/tags 引用
 type TargetConfig struct {
-	Name     string `json:"Name" yaml:"Name" mapstructure:"Name"`
-	Host     string `json:"Host" yaml:"Host" mapstructure:"Host"`
-	Port     int    `json:"Port" yaml:"Port" mapstructure:"Port"`
-	User     string `json:"User" yaml:"User" mapstructure:"User"`
-	Password string `json:"Password" yaml:"Password" mapstructure:"Password"`
+	Name                 string            `json:"Name" yaml:"Name" mapstructure:"Name"`
+	Host                 string            `json:"Host" yaml:"Host" mapstructure:"Host"`
+	Port                 int               `json:"Port,optional" yaml:"Port" mapstructure:"Port"`
+	User                 string            `json:"User" yaml:"User" mapstructure:"User"`
+	Password             string            `json:"Password,optional" yaml:"Password" mapstructure:"Password"`
+	PrivateKey           string            `json:"PrivateKey,optional" yaml:"PrivateKey" mapstructure:"PrivateKey"`
+	PrivateKeyPassphrase string            `json:"PrivateKeyPassphrase,optional" yaml:"PrivateKeyPassphrase" mapstructure:"PrivateKeyPassphrase"`
+	KeyRef               string            `json:"KeyRef,optional" yaml:"KeyRef" mapstructure:"KeyRef"`                // Worker KeyDir 下的私钥文件名，或 env:/file:/vault: 引用
+	PasswordRef          string            `json:"PasswordRef,optional" yaml:"PasswordRef" mapstructure:"PasswordRef"` // env:/file:/vault: 密码引用
+	HostKeyFingerprint   string            `json:"HostKeyFingerprint,optional" yaml:"HostKeyFingerprint" mapstructure:"HostKeyFingerprint"`
+	Groups               []string          `json:"Groups,optional" yaml:"Groups" mapstructure:"Groups"` // 所属分组，如 web、db
+	Tags                 map[string]string `json:"Tags,optional" yaml:"Tags" mapstructure:"Tags"`       // 标签，如 env: prod
 }
 
 // 任务执行器配置
 type ExecutorConfig struct {
+	Backend        string `json:"Backend,optional" yaml:"Backend" mapstructure:"Backend"` // 执行后端：go（默认）, python
 	Script         string `json:"Script" yaml:"Script" mapstructure:"Script"`
-	UploadScript   string `json:"UploadScript" yaml:"UploadScript" mapstructure:"UploadScript"`       // 文件上传脚本路径
-	Concurrency    int    `json:"Concurrency" yaml:"Concurrency" mapstructure:"Concurrency"`          // 并发数
-	TimeoutSeconds int    `json:"TimeoutSeconds" yaml:"TimeoutSeconds" mapstructure:"TimeoutSeconds"` // 超时时间
+	UploadScript   string `json:"UploadScript" yaml:"UploadScript" mapstructure:"UploadScript"`                // 文件上传脚本路径
+	DownloadScript string `json:"DownloadScript,optional" yaml:"DownloadScript" mapstructure:"DownloadScript"` // 文件下载脚本路径，默认 ./scripts/ssh_downloader.py
+	Concurrency    int    `json:"Concurrency" yaml:"Concurrency" mapstructure:"Concurrency"`                   // 并发数
+	JumpPoolSize   int    `json:"JumpPoolSize,optional" yaml:"JumpPoolSize" mapstructure:"JumpPoolSize"`       // 每个任务到跳板机链的连接数，目标主机的通道在其上复用，默认 1
+	TimeoutSeconds int    `json:"TimeoutSeconds" yaml:"TimeoutSeconds" mapstructure:"TimeoutSeconds"`          // 超时时间
+	KeyDir         string `json:"KeyDir,optional" yaml:"KeyDir" mapstructure:"KeyDir"`                         // key_ref 私钥所在目录（Worker 本地）
+	UseAgent       bool   `json:"UseAgent,optional" yaml:"UseAgent" mapstructure:"UseAgent"`                   // 是否使用 SSH_AUTH_SOCK 指向的 ssh-agent
+	HostKeyPolicy  string `json:"HostKeyPolicy,optional" yaml:"HostKeyPolicy" mapstructure:"HostKeyPolicy"`    // 主机密钥校验策略：strict, accept-new（默认）, insecure
+	KnownHostsFile string `json:"KnownHostsFile,optional" yaml:"KnownHostsFile" mapstructure:"KnownHostsFile"` // Worker 本地 known_hosts 文件，默认 data/known_hosts
 }
 
 // Celery 配置
@@ -46,7 +205,9 @@ type CeleryConfig struct {
 	Backend        string `json:"Backend" yaml:"Backend" mapstructure:"Backend"`
 	TaskName       string `json:"TaskName" yaml:"TaskName" mapstructure:"TaskName"`
 	UploadTaskName string `json:"UploadTaskName" yaml:"UploadTaskName" mapstructure:"UploadTaskName"` // 文件上传任务名称
-	Workers        int    `json:"Workers" yaml:"Workers" mapstructure:"Workers"`
+	// 文件下载任务名称，默认 tasks.download_file
+	DownloadTaskName string `json:"DownloadTaskName,optional" yaml:"DownloadTaskName" mapstructure:"DownloadTaskName"`
+	Workers          int    `json:"Workers" yaml:"Workers" mapstructure:"Workers"`
 }
 
 // 日志配置
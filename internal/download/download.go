@@ -0,0 +1,110 @@
+package download
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"gocerery/internal/config"
+)
+
+// 取回文件的保存方式
+const (
+	StoreLocal    = "local"
+	StoreArtifact = "artifact"
+)
+
+const (
+	defaultDir       = "data/downloads"
+	defaultMaxSizeMB = 1024
+)
+
+// Dir 下载根目录，每个任务一个子目录
+func Dir(c config.DownloadConfig) string {
+	if c.Dir == "" {
+		return defaultDir
+	}
+	return c.Dir
+}
+
+// MaxSize 单台主机取回的总大小上限（字节）
+func MaxSize(c config.DownloadConfig) int64 {
+	mb := c.MaxSizeMB
+	if mb <= 0 {
+		mb = defaultMaxSizeMB
+	}
+	return int64(mb) << 20
+}
+
+// CheckStore 校验 Store，artifact 需要同时配置 Artifacts
+func CheckStore(c config.DownloadConfig, artifacts bool) error {
+	switch c.Store {
+	case "", StoreLocal:
+		return nil
+	case StoreArtifact:
+		if !artifacts {
+			return errors.New("Downloads.Store is artifact but Artifacts is not configured")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown Downloads.Store: %s", c.Store)
+	}
+}
+
+// ValidatePattern 远程路径必须是绝对路径，可包含 * ? [...] 通配，不允许 . 与 .. 段
+func ValidatePattern(p string) error {
+	if !strings.HasPrefix(p, "/") {
+		return fmt.Errorf("remote path must be absolute: %s", p)
+	}
+	if p != "/" && path.Clean(p) != strings.TrimSuffix(p, "/") {
+		return fmt.Errorf("remote path must be clean: %s", p)
+	}
+	if _, err := path.Match(p, ""); err != nil {
+		return fmt.Errorf("invalid pattern %q: %w", p, err)
+	}
+	return nil
+}
+
+// StaticPrefix 通配符之前的目录部分，如 /var/log/nginx/*.log 返回 /var/log/nginx；
+// 匹配结果一定位于该目录下，授权时按它检查
+func StaticPrefix(pattern string) string {
+	segments := strings.Split(path.Clean(pattern), "/")
+	for i, seg := range segments {
+		if strings.ContainsAny(seg, "*?[\\") {
+			if i <= 1 {
+				return "/"
+			}
+			return strings.Join(segments[:i], "/")
+		}
+	}
+	return path.Clean(pattern)
+}
+
+// HostDirs 每台主机在任务目录下的子目录名：取名称（为空时取地址），
+// 替换路径分隔符等字符，重名时追加序号
+func HostDirs(names []string) []string {
+	dirs := make([]string, len(names))
+	seen := make(map[string]bool, len(names))
+	for i, name := range names {
+		dir := strings.Map(func(r rune) rune {
+			switch {
+			case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+				return r
+			default:
+				return '_'
+			}
+		}, name)
+		if dir == "" || strings.Trim(dir, ".") == "" {
+			dir = "host"
+		}
+		base := dir
+		for n := 2; seen[dir]; n++ {
+			dir = base + "-" + strconv.Itoa(n)
+		}
+		seen[dir] = true
+		dirs[i] = dir
+	}
+	return dirs
+}
@@ -0,0 +1,87 @@
+package download
+
+import (
+	"reflect"
+	"testing"
+
+	"gocerery/internal/config"
+)
+
+func TestValidatePattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		valid   bool
+	}{
+		{pattern: "/", valid: true},
+		{pattern: "/var/log/nginx/access.log", valid: true},
+		{pattern: "/var/log/nginx/", valid: true},
+		{pattern: "/var/log/*/error-?.log", valid: true},
+		{pattern: "/etc/app/[a-c]*.conf", valid: true},
+		{pattern: "var/log/app.log"},
+		{pattern: ""},
+		{pattern: "/var/log/../../etc/shadow"},
+		{pattern: "/var/./log"},
+		{pattern: "/var//log"},
+		{pattern: "/var/log/[a-"},
+	}
+	for _, tt := range tests {
+		if err := ValidatePattern(tt.pattern); (err == nil) != tt.valid {
+			t.Errorf("ValidatePattern(%q) error = %v, want valid %v", tt.pattern, err, tt.valid)
+		}
+	}
+}
+
+func TestStaticPrefix(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    string
+	}{
+		{pattern: "/var/log/nginx/*.log", want: "/var/log/nginx"},
+		{pattern: "/var/log/*/error.log", want: "/var/log"},
+		{pattern: "/var/log/app-?/x", want: "/var/log"},
+		{pattern: "/etc/[ab]/conf", want: "/etc"},
+		{pattern: "/*/secret", want: "/"},
+		{pattern: "/*", want: "/"},
+		{pattern: "/var/log/nginx/access.log", want: "/var/log/nginx/access.log"},
+		{pattern: "/var/log/nginx/", want: "/var/log/nginx"},
+		{pattern: "/var/log/../../etc/*", want: "/etc"},
+		{pattern: "/", want: "/"},
+	}
+	for _, tt := range tests {
+		if got := StaticPrefix(tt.pattern); got != tt.want {
+			t.Errorf("StaticPrefix(%q) = %q, want %q", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestHostDirs(t *testing.T) {
+	got := HostDirs([]string{"web-1", "web-1", "10.0.0.5", "", "..", "a/b", "web-1-2", "web-1"})
+	want := []string{"web-1", "web-1-2", "10.0.0.5", "host", "host-2", "a_b", "web-1-2-2", "web-1-3"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("HostDirs = %q, want %q", got, want)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	if Dir(config.DownloadConfig{}) != "data/downloads" || MaxSize(config.DownloadConfig{}) != 1024<<20 {
+		t.Fatal("unexpected defaults")
+	}
+	if MaxSize(config.DownloadConfig{MaxSizeMB: 5}) != 5<<20 {
+		t.Fatal("MaxSizeMB not applied")
+	}
+	for _, tt := range []struct {
+		store     string
+		artifacts bool
+		valid     bool
+	}{
+		{store: "", valid: true},
+		{store: StoreLocal, valid: true},
+		{store: StoreArtifact, artifacts: true, valid: true},
+		{store: StoreArtifact},
+		{store: "s3", artifacts: true},
+	} {
+		if err := CheckStore(config.DownloadConfig{Store: tt.store}, tt.artifacts); (err == nil) != tt.valid {
+			t.Errorf("CheckStore(%q, %v) error = %v, want valid %v", tt.store, tt.artifacts, err, tt.valid)
+		}
+	}
+}
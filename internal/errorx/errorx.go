@@ -0,0 +1,42 @@
+package errorx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// CodeError 带 HTTP 状态码的错误，由 ErrorHandler 按 Code 写出响应
+type CodeError struct {
+	Code int
+	Msg  string
+}
+
+func (e *CodeError) Error() string {
+	return e.Msg
+}
+
+func NewCodeError(code int, msg string) error {
+	return &CodeError{Code: code, Msg: msg}
+}
+
+// Unauthorized 请求未通过认证，返回 401
+func Unauthorized(format string, args ...any) error {
+	return &CodeError{Code: http.StatusUnauthorized, Msg: fmt.Sprintf(format, args...)}
+}
+
+// Forbidden 请求被授权策略拒绝，返回 403
+func Forbidden(format string, args ...any) error {
+	return &CodeError{Code: http.StatusForbidden, Msg: fmt.Sprintf(format, args...)}
+}
+
+// ErrorHandler 注册到 httpx.SetErrorHandlerCtx：CodeError 使用其状态码，
+// 其余错误与 go-zero 默认行为一致返回 400，响应体均为纯文本错误信息
+func ErrorHandler(_ context.Context, err error) (int, any) {
+	var codeErr *CodeError
+	if errors.As(err, &codeErr) {
+		return codeErr.Code, err
+	}
+	return http.StatusBadRequest, err
+}
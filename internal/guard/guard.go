@@ -0,0 +1,128 @@
+package guard
+
+import (
+	"fmt"
+	"regexp"
+
+	"gocerery/internal/config"
+)
+
+// allowRuleName 命令不匹配任一 Allow 规则时报告的规则名称
+const allowRuleName = "allow-list"
+
+// commandStart 命令起始位置：开头、空白、管道/分隔符或子 shell 之后，sudo rm 等写法同样命中
+const commandStart = "(^|[\\s;&|(`])"
+
+// commandEnd 命令或参数结束位置，避免 reboot-ok 之类的参数被误判
+const commandEnd = "(\\s|$|[;&|)`])"
+
+// defaultRules 内置 Deny 规则：破坏系统的命令为硬规则，关机重启为软规则
+var defaultRules = []config.GuardRuleConfig{
+	{Name: "rm-root", Pattern: commandStart + `(\S*/)?rm\s+(-\S+\s+)*/\*?` + commandEnd, Hard: true},
+	{Name: "mkfs", Pattern: commandStart + `mkfs(\.\w+)?` + commandEnd, Hard: true},
+	{Name: "dd-to-disk", Pattern: commandStart + `dd\s.*\bof=/dev/(sd|hd|vd|xvd|nvme|mmcblk)`, Hard: true},
+	{Name: "fork-bomb", Pattern: `:\(\)\s*\{\s*:\s*\|\s*:\s*&\s*\}\s*;\s*:`, Hard: true},
+	{Name: "shutdown", Pattern: commandStart + `(\S*/)?(shutdown|poweroff|halt|reboot|init\s+[06])` + commandEnd},
+}
+
+// Guard 按正则规则检查命令，API 与 Worker 使用同一份配置
+type Guard struct {
+	deny      []rule
+	allow     []*regexp.Regexp
+	allowHard bool
+}
+
+type rule struct {
+	name string
+	re   *regexp.Regexp
+	hard bool
+}
+
+// Violation 命令被规则拒绝，Error 中包含命中的规则
+type Violation struct {
+	Command string
+	Rule    string
+	Hard    bool
+}
+
+func (v *Violation) Error() string {
+	if v.Hard {
+		return fmt.Sprintf("command %q is blocked by hard rule %s", v.Command, v.Rule)
+	}
+	return fmt.Sprintf("command %q is blocked by soft rule %s, set dangerous_ack to run it", v.Command, v.Rule)
+}
+
+// New 编译内置与配置的规则，规则名称必填
+func New(c config.CommandGuardConfig) (*Guard, error) {
+	rules := c.Deny
+	if !c.DisableDefaultRules {
+		rules = append(append([]config.GuardRuleConfig(nil), defaultRules...), c.Deny...)
+	}
+	g := &Guard{allowHard: c.AllowHard}
+	for idx, rc := range rules {
+		if rc.Name == "" || rc.Pattern == "" {
+			return nil, fmt.Errorf("CommandGuard.Deny[%d] name/pattern are required", idx)
+		}
+		re, err := regexp.Compile(rc.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("CommandGuard rule %s: %w", rc.Name, err)
+		}
+		g.deny = append(g.deny, rule{name: rc.Name, re: re, hard: rc.Hard})
+	}
+	for idx, pattern := range c.Allow {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("CommandGuard.Allow[%d]: %w", idx, err)
+		}
+		g.allow = append(g.allow, re)
+	}
+	return g, nil
+}
+
+// Check 依次检查每条命令：命中硬规则直接拒绝；命中软规则时 ack 为 false 则拒绝，
+// 为 true 则放行并在返回值中列出被放行的规则，供调用方记录日志
+func (g *Guard) Check(commands []string, ack bool) ([]Violation, error) {
+	if g == nil {
+		return nil, nil
+	}
+	var acked []Violation
+	for _, command := range commands {
+		for _, v := range g.match(command) {
+			if v.Hard || !ack {
+				return nil, &v
+			}
+			acked = append(acked, v)
+		}
+	}
+	return acked, nil
+}
+
+// match 返回命令命中的全部规则，硬规则排在前面
+func (g *Guard) match(command string) []Violation {
+	var hard, soft []Violation
+	add := func(v Violation) {
+		if v.Hard {
+			hard = append(hard, v)
+		} else {
+			soft = append(soft, v)
+		}
+	}
+	for _, r := range g.deny {
+		if r.re.MatchString(command) {
+			add(Violation{Command: command, Rule: r.name, Hard: r.hard})
+		}
+	}
+	if len(g.allow) > 0 && !g.allowed(command) {
+		add(Violation{Command: command, Rule: allowRuleName, Hard: g.allowHard})
+	}
+	return append(hard, soft...)
+}
+
+func (g *Guard) allowed(command string) bool {
+	for _, re := range g.allow {
+		if re.MatchString(command) {
+			return true
+		}
+	}
+	return false
+}
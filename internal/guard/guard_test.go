@@ -0,0 +1,140 @@
+package guard
+
+import (
+	"errors"
+	"testing"
+
+	"gocerery/internal/config"
+)
+
+func TestDefaultRules(t *testing.T) {
+	g, err := New(config.CommandGuardConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		command string
+		rule    string // 为空表示不命中
+		hard    bool
+	}{
+		{command: "rm -rf /", rule: "rm-root", hard: true},
+		{command: "rm -rf /*", rule: "rm-root", hard: true},
+		{command: "sudo rm -r -f /", rule: "rm-root", hard: true},
+		{command: "cd /tmp && /bin/rm -rf / ", rule: "rm-root", hard: true},
+		{command: "rm -rf /var/tmp/cache"},
+		{command: "rm -rf ./build"},
+		{command: "mkfs.ext4 /dev/sdb1", rule: "mkfs", hard: true},
+		{command: "echo mkfs-helper"},
+		{command: "dd if=/dev/zero of=/dev/sda bs=1M", rule: "dd-to-disk", hard: true},
+		{command: "dd if=/dev/zero of=/tmp/img bs=1M"},
+		{command: ":(){ :|:& };:", rule: "fork-bomb", hard: true},
+		{command: "reboot", rule: "shutdown"},
+		{command: "sudo shutdown -h now", rule: "shutdown"},
+		{command: "uptime; /sbin/poweroff", rule: "shutdown"},
+		{command: "init 6", rule: "shutdown"},
+		{command: "echo reboot-ok"},
+		{command: "systemctl status reboot-guard.service"},
+		{command: "uptime"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			_, err := g.Check([]string{tt.command}, false)
+			if tt.rule == "" {
+				if err != nil {
+					t.Fatalf("Check(%q) error = %v, want allowed", tt.command, err)
+				}
+				return
+			}
+			var v *Violation
+			if !errors.As(err, &v) {
+				t.Fatalf("Check(%q) error = %v, want Violation %s", tt.command, err, tt.rule)
+			}
+			if v.Rule != tt.rule || v.Hard != tt.hard {
+				t.Fatalf("Check(%q) = rule %s hard=%v, want %s hard=%v", tt.command, v.Rule, v.Hard, tt.rule, tt.hard)
+			}
+		})
+	}
+}
+
+func TestCheckAck(t *testing.T) {
+	g, err := New(config.CommandGuardConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	acked, err := g.Check([]string{"uptime", "reboot"}, true)
+	if err != nil {
+		t.Fatalf("Check with ack error = %v", err)
+	}
+	if len(acked) != 1 || acked[0].Rule != "shutdown" || acked[0].Command != "reboot" {
+		t.Fatalf("acked = %+v, want the shutdown rule", acked)
+	}
+	// 同一条命令同时命中软规则与硬规则时，ack 也不能放行
+	if _, err := g.Check([]string{"reboot; rm -rf /"}, true); err == nil {
+		t.Fatal("hard rule passed with ack")
+	}
+}
+
+func TestAllowList(t *testing.T) {
+	tests := []struct {
+		name      string
+		c         config.CommandGuardConfig
+		command   string
+		ack       bool
+		wantRule  string
+		wantAcked int
+	}{
+		{name: "allowed", c: config.CommandGuardConfig{Allow: []string{`^systemctl (status|restart) \w+$`}},
+			command: "systemctl restart nginx"},
+		{name: "not allowed soft", c: config.CommandGuardConfig{Allow: []string{`^systemctl status \w+$`}},
+			command: "systemctl stop nginx", wantRule: allowRuleName},
+		{name: "not allowed acked", c: config.CommandGuardConfig{Allow: []string{`^systemctl status \w+$`}},
+			command: "systemctl stop nginx", ack: true, wantAcked: 1},
+		{name: "not allowed hard", c: config.CommandGuardConfig{Allow: []string{`^uptime$`}, AllowHard: true},
+			command: "id", ack: true, wantRule: allowRuleName},
+		{name: "deny wins over allow", c: config.CommandGuardConfig{Allow: []string{`.*`}},
+			command: "rm -rf /", ack: true, wantRule: "rm-root"},
+		{name: "custom hard rule", c: config.CommandGuardConfig{Deny: []config.GuardRuleConfig{{Name: "no-curl-pipe", Pattern: `curl .*\|\s*sh`, Hard: true}}},
+			command: "curl https://x | sh", ack: true, wantRule: "no-curl-pipe"},
+		{name: "defaults disabled", c: config.CommandGuardConfig{DisableDefaultRules: true},
+			command: "reboot"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := New(tt.c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			acked, err := g.Check([]string{tt.command}, tt.ack)
+			var v *Violation
+			if tt.wantRule != "" {
+				if !errors.As(err, &v) || v.Rule != tt.wantRule {
+					t.Fatalf("Check(%q) error = %v, want rule %s", tt.command, err, tt.wantRule)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Check(%q) error = %v", tt.command, err)
+			}
+			if len(acked) != tt.wantAcked {
+				t.Fatalf("Check(%q) acked = %+v, want %d", tt.command, acked, tt.wantAcked)
+			}
+		})
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	for _, c := range []config.CommandGuardConfig{
+		{Deny: []config.GuardRuleConfig{{Pattern: "x"}}},
+		{Deny: []config.GuardRuleConfig{{Name: "x"}}},
+		{Deny: []config.GuardRuleConfig{{Name: "x", Pattern: "("}}},
+		{Allow: []string{"["}},
+	} {
+		if _, err := New(c); err == nil {
+			t.Errorf("New(%+v) succeeded, want error", c)
+		}
+	}
+	var g *Guard
+	if acked, err := g.Check([]string{"rm -rf /"}, false); acked != nil || err != nil {
+		t.Fatal("nil Guard should allow everything")
+	}
+}
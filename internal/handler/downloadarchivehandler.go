@@ -0,0 +1,40 @@
+// Code scaffolded by goctl. Safe to edit.
+// goctl 1.9.2
+
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/zeromicro/go-zero/core/logc"
+	"github.com/zeromicro/go-zero/rest/httpx"
+	"gocerery/internal/logic"
+	"gocerery/internal/svc"
+	"gocerery/internal/types"
+)
+
+func DownloadArchiveHandler(svcCtx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req types.DownloadArchiveRequest
+		if err := httpx.Parse(r, &req); err != nil {
+			httpx.ErrorCtx(r.Context(), w, err)
+			return
+		}
+
+		l := logic.NewDownloadArchiveLogic(r.Context(), svcCtx)
+		archive, err := l.DownloadArchive(&req)
+		if err != nil {
+			httpx.ErrorCtx(r.Context(), w, err)
+			return
+		}
+
+		// 文件检查通过后才发送响应头，之后的错误只能记录日志，客户端会收到不完整的压缩包
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", archive.TaskID+".tar.gz"))
+		w.WriteHeader(http.StatusOK)
+		if err := archive.Write(w); err != nil {
+			logc.Errorw(r.Context(), "DownloadArchiveHandler", logc.Field("error", err))
+		}
+	}
+}
@@ -0,0 +1,34 @@
+// Code scaffolded by goctl. Safe to edit.
+// goctl 1.9.2
+
+package handler
+
+import (
+	"net/http"
+
+	"github.com/zeromicro/go-zero/rest/httpx"
+	"gocerery/internal/audit"
+	"gocerery/internal/logic"
+	"gocerery/internal/svc"
+	"gocerery/internal/types"
+)
+
+func ExecuteDownloadTaskHandler(svcCtx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req types.DownloadTaskRequest
+		if err := httpx.Parse(r, &req); err != nil {
+			httpx.ErrorCtx(r.Context(), w, err)
+			return
+		}
+
+		// 记录请求来源地址，写入审计日志
+		ctx := audit.WithSourceIP(r.Context(), httpx.GetRemoteAddr(r))
+		l := logic.NewExecuteDownloadTaskLogic(ctx, svcCtx)
+		resp, err := l.ExecuteDownloadTask(&req)
+		if err != nil {
+			httpx.ErrorCtx(r.Context(), w, err)
+		} else {
+			httpx.OkJsonCtx(r.Context(), w, resp)
+		}
+	}
+}
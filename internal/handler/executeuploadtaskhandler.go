@@ -7,6 +7,7 @@ import (
 	"net/http"
 
 	"github.com/zeromicro/go-zero/rest/httpx"
+	"gocerery/internal/audit"
 	"gocerery/internal/logic"
 	"gocerery/internal/svc"
 	"gocerery/internal/types"
@@ -20,7 +21,9 @@ func ExecuteUploadTaskHandler(svcCtx *svc.ServiceContext) http.HandlerFunc {
 			return
 		}
 
-		l := logic.NewExecuteUploadTaskLogic(r.Context(), svcCtx)
+		// 记录请求来源地址，写入审计日志
+		ctx := audit.WithSourceIP(r.Context(), httpx.GetRemoteAddr(r))
+		l := logic.NewExecuteUploadTaskLogic(ctx, svcCtx)
 		resp, err := l.ExecuteUploadTask(&req)
 		if err != nil {
 			httpx.ErrorCtx(r.Context(), w, err)
@@ -5,6 +5,7 @@ package handler
 
 import (
 	"net/http"
+	"time"
 
 	"gocerery/internal/svc"
 
@@ -13,27 +14,104 @@ import (
 
 func RegisterHandlers(server *rest.Server, serverCtx *svc.ServiceContext) {
 	server.AddRoutes(
-		[]rest.Route{
-			{
-				Method:  http.MethodPost,
-				Path:    "/api/ssh/task",
-				Handler: ExecuteSshTaskHandler(serverCtx),
-			},
-			{
-				Method:  http.MethodGet,
-				Path:    "/api/ssh/task/:id",
-				Handler: QuerySshTaskHandler(serverCtx),
-			},
-			{
-				Method:  http.MethodPost,
-				Path:    "/api/upload/task",
-				Handler: ExecuteUploadTaskHandler(serverCtx),
-			},
-			{
-				Method:  http.MethodGet,
-				Path:    "/api/upload/task/:id",
-				Handler: QueryUploadTaskHandler(serverCtx),
-			},
-		},
+		rest.WithMiddlewares(
+			[]rest.Middleware{serverCtx.Auth},
+			[]rest.Route{
+				{
+					Method:  http.MethodPost,
+					Path:    "/api/ssh/task",
+					Handler: ExecuteSshTaskHandler(serverCtx),
+				},
+				{
+					Method:  http.MethodGet,
+					Path:    "/api/ssh/task/:id",
+					Handler: QuerySshTaskHandler(serverCtx),
+				},
+				{
+					Method:  http.MethodDelete,
+					Path:    "/api/ssh/task/:id",
+					Handler: RevokeSshTaskHandler(serverCtx),
+				},
+				{
+					Method:  http.MethodPost,
+					Path:    "/api/upload/task",
+					Handler: ExecuteUploadTaskHandler(serverCtx),
+				},
+				{
+					Method:  http.MethodGet,
+					Path:    "/api/upload/task/:id",
+					Handler: QueryUploadTaskHandler(serverCtx),
+				},
+				{
+					Method:  http.MethodDelete,
+					Path:    "/api/upload/task/:id",
+					Handler: RevokeUploadTaskHandler(serverCtx),
+				},
+				{
+					Method:  http.MethodPost,
+					Path:    "/api/download/task",
+					Handler: ExecuteDownloadTaskHandler(serverCtx),
+				},
+				{
+					Method:  http.MethodGet,
+					Path:    "/api/download/task/:id",
+					Handler: QueryDownloadTaskHandler(serverCtx),
+				},
+				{
+					Method:  http.MethodDelete,
+					Path:    "/api/download/task/:id",
+					Handler: RevokeDownloadTaskHandler(serverCtx),
+				},
+				{
+					Method:  http.MethodGet,
+					Path:    "/api/audit",
+					Handler: QueryAuditHandler(serverCtx),
+				},
+			}...,
+		),
+	)
+
+	server.AddRoutes(
+		rest.WithMiddlewares(
+			[]rest.Middleware{serverCtx.Auth},
+			[]rest.Route{
+				{
+					Method:  http.MethodGet,
+					Path:    "/api/ssh/task/:id/stream",
+					Handler: StreamSshTaskHandler(serverCtx),
+				},
+			}...,
+		),
+		rest.WithSSE(),
+		rest.WithTimeout(0*time.Millisecond),
+	)
+
+	server.AddRoutes(
+		rest.WithMiddlewares(
+			[]rest.Middleware{serverCtx.Auth},
+			[]rest.Route{
+				{
+					Method:  http.MethodPost,
+					Path:    "/api/upload/artifact",
+					Handler: UploadArtifactHandler(serverCtx),
+				},
+			}...,
+		),
+		rest.WithTimeout(0*time.Millisecond),
+		rest.WithMaxBytes(1099511627776),
+	)
+
+	server.AddRoutes(
+		rest.WithMiddlewares(
+			[]rest.Middleware{serverCtx.Auth},
+			[]rest.Route{
+				{
+					Method:  http.MethodGet,
+					Path:    "/api/download/task/:id/archive",
+					Handler: DownloadArchiveHandler(serverCtx),
+				},
+			}...,
+		),
+		rest.WithTimeout(0*time.Millisecond),
 	)
 }
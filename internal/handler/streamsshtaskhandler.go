@@ -0,0 +1,87 @@
+// Code scaffolded by goctl. Safe to edit.
+// goctl 1.9.2
+
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"gocerery/internal/logic"
+	"gocerery/internal/svc"
+	"gocerery/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logc"
+	"github.com/zeromicro/go-zero/core/threading"
+	"github.com/zeromicro/go-zero/rest/httpx"
+)
+
+// sseKeepAlive 无事件时发送注释行的间隔，防止代理断开空闲连接
+const sseKeepAlive = 15 * time.Second
+
+func StreamSshTaskHandler(svcCtx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req types.SshTaskStatusRequest
+		if err := httpx.Parse(r, &req); err != nil {
+			httpx.ErrorCtx(r.Context(), w, err)
+			return
+		}
+
+		client := make(chan *types.TaskStreamEvent, 16)
+		var streamErr error
+		l := logic.NewStreamSshTaskLogic(r.Context(), svcCtx)
+		threading.GoSafeCtx(r.Context(), func() {
+			defer close(client)
+			streamErr = l.StreamSshTask(&req, client)
+		})
+
+		// 立即发送响应头，任务尚未开始时客户端也能确认连接已建立
+		w.WriteHeader(http.StatusOK)
+		flush(w)
+
+		ticker := time.NewTicker(sseKeepAlive)
+		defer ticker.Stop()
+		for {
+			select {
+			case data, ok := <-client:
+				if !ok {
+					if streamErr != nil {
+						logc.Errorw(r.Context(), "StreamSshTaskHandler", logc.Field("error", streamErr))
+						writeEvent(w, "error", map[string]string{"error": streamErr.Error()})
+					}
+					return
+				}
+				if err := writeEvent(w, data.Type, data); err != nil {
+					logc.Errorw(r.Context(), "StreamSshTaskHandler", logc.Field("error", err))
+					return
+				}
+			case <-ticker.C:
+				if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+					return
+				}
+				flush(w)
+			}
+		}
+	}
+}
+
+// writeEvent 以 event/data 格式写出一条 SSE 事件
+func writeEvent(w http.ResponseWriter, event string, data any) error {
+	output, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, output); err != nil {
+		return err
+	}
+	flush(w)
+	return nil
+}
+
+func flush(w http.ResponseWriter) {
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
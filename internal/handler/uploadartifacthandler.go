@@ -0,0 +1,37 @@
+// Code scaffolded by goctl. Safe to edit.
+// goctl 1.9.2
+
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/zeromicro/go-zero/rest/httpx"
+	"gocerery/internal/artifact"
+	"gocerery/internal/errorx"
+	"gocerery/internal/logic"
+	"gocerery/internal/svc"
+)
+
+func UploadArtifactHandler(svcCtx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// 路由的 maxBytes 只是上限，实际按 Artifacts.MaxSizeMB 限制；声明的长度超出时不读取请求体
+		limit := artifact.MaxRequestSize(svcCtx.Config.Artifacts)
+		if r.ContentLength > limit {
+			httpx.ErrorCtx(r.Context(), w, errorx.NewCodeError(http.StatusRequestEntityTooLarge,
+				fmt.Sprintf("request body exceeds %d MB", artifact.MaxSize(svcCtx.Config.Artifacts)>>20)))
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+
+		l := logic.NewUploadArtifactLogic(r.Context(), svcCtx)
+		// multipart 请求体由 logic 流式读取，不经过 httpx.Parse
+		resp, err := l.UploadArtifact(r)
+		if err != nil {
+			httpx.ErrorCtx(r.Context(), w, err)
+		} else {
+			httpx.OkJsonCtx(r.Context(), w, resp)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package inventory
+
+import (
+	"errors"
+	"fmt"
+
+	"gocerery/internal/config"
+)
+
+// Inventory 由 Config.Targets 构建的命名主机清单，请求按名称、分组或标签引用主机，
+// 调用方无需传入目标主机凭据
+type Inventory struct {
+	hosts  []config.TargetConfig
+	byName map[string]int
+	groups map[string][]int
+}
+
+// New 校验并索引主机清单：名称必填且唯一，host/user 必填
+func New(targets []config.TargetConfig) (*Inventory, error) {
+	inv := &Inventory{
+		hosts:  targets,
+		byName: make(map[string]int, len(targets)),
+		groups: make(map[string][]int),
+	}
+	for idx, t := range targets {
+		if t.Name == "" {
+			return nil, fmt.Errorf("Targets[%d] name is required", idx)
+		}
+		if t.Host == "" || t.User == "" {
+			return nil, fmt.Errorf("Targets[%d] (%s) host/user are required", idx, t.Name)
+		}
+		if _, ok := inv.byName[t.Name]; ok {
+			return nil, fmt.Errorf("duplicate target name in Targets: %s", t.Name)
+		}
+		inv.byName[t.Name] = idx
+		for _, group := range t.Groups {
+			inv.groups[group] = append(inv.groups[group], idx)
+		}
+	}
+	return inv, nil
+}
+
+// Len 清单中的主机数量
+func (inv *Inventory) Len() int {
+	if inv == nil {
+		return 0
+	}
+	return len(inv.hosts)
+}
+
+// Lookup 按名称查找清单中的主机
+func (inv *Inventory) Lookup(name string) (config.TargetConfig, bool) {
+	if inv == nil {
+		return config.TargetConfig{}, false
+	}
+	idx, ok := inv.byName[name]
+	if !ok {
+		return config.TargetConfig{}, false
+	}
+	return inv.hosts[idx], true
+}
+
+// Select 按名称与分组取并集，再按标签过滤（需全部匹配）；只给出标签时从全部主机中过滤。
+// 结果按清单顺序去重，未知的名称或分组返回错误
+func (inv *Inventory) Select(names, groups []string, tags map[string]string) ([]config.TargetConfig, error) {
+	if len(names) == 0 && len(groups) == 0 && len(tags) == 0 {
+		return nil, nil
+	}
+	if inv.Len() == 0 {
+		return nil, errors.New("target inventory is empty, configure Targets first")
+	}
+
+	selected := make([]bool, len(inv.hosts))
+	if len(names) == 0 && len(groups) == 0 {
+		for idx := range selected {
+			selected[idx] = true
+		}
+	}
+	for _, name := range names {
+		idx, ok := inv.byName[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown target name: %s", name)
+		}
+		selected[idx] = true
+	}
+	for _, group := range groups {
+		members, ok := inv.groups[group]
+		if !ok {
+			return nil, fmt.Errorf("unknown target group: %s", group)
+		}
+		for _, idx := range members {
+			selected[idx] = true
+		}
+	}
+
+	var result []config.TargetConfig
+	for idx, t := range inv.hosts {
+		if selected[idx] && matchTags(t.Tags, tags) {
+			result = append(result, t)
+		}
+	}
+	if len(result) == 0 {
+		return nil, errors.New("no inventory target matches target_names/groups/tags")
+	}
+	return result, nil
+}
+
+func matchTags(hostTags, want map[string]string) bool {
+	for k, v := range want {
+		if hostTags[k] != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,196 @@
+// Code scaffolded by goctl. Safe to edit.
+// goctl 1.9.2
+
+package logic
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gocerery/internal/artifact"
+	"gocerery/internal/download"
+	"gocerery/internal/errorx"
+	"gocerery/internal/svc"
+	"gocerery/internal/taskstate"
+	"gocerery/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type DownloadArchiveLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewDownloadArchiveLogic(ctx context.Context, svcCtx *svc.ServiceContext) *DownloadArchiveLogic {
+	return &DownloadArchiveLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+// DownloadArchive 任务取回的文件列表，由 Write 打包为 tar.gz
+type DownloadArchive struct {
+	TaskID  string
+	entries []archiveEntry
+	open    func(e archiveEntry) (io.ReadCloser, error)
+}
+
+// archiveEntry 压缩包中的一个文件，name 为 <主机目录>/<远程路径>
+type archiveEntry struct {
+	name       string
+	localPath  string // Downloads.Store=local 时的文件路径
+	artifactID string // Downloads.Store=artifact 时的 artifact
+	size       int64
+	modTime    time.Time
+}
+
+// DownloadArchive 读取已结束的下载任务结果，检查文件仍然存在；host 可按主机名称或地址筛选
+func (l *DownloadArchiveLogic) DownloadArchive(req *types.DownloadArchiveRequest) (*DownloadArchive, error) {
+	if req.TaskID == "" {
+		return nil, errors.New("task_id is required")
+	}
+	if l.svcCtx.CeleryBackend == nil {
+		return nil, errors.New("celery backend is not configured")
+	}
+	// 文件内容只提供给提交任务的调用方
+	if submitter := taskSubmitter(l.Logger, l.svcCtx.TaskState, req.TaskID); submitter != "" && submitter != submitterName(l.ctx) {
+		return nil, errorx.Forbidden("download task %s was submitted by another principal", req.TaskID)
+	}
+
+	resultMsg, err := l.svcCtx.CeleryBackend.GetResult(req.TaskID)
+	if err != nil || taskstate.Running(resultMsg.Status) {
+		return nil, errorx.NewCodeError(http.StatusConflict, fmt.Sprintf("download task %s is not finished", req.TaskID))
+	}
+	if resultMsg.Status != taskstate.StatusSuccess {
+		return nil, errorx.NewCodeError(http.StatusConflict, fmt.Sprintf("download task %s ended with status %s", req.TaskID, resultMsg.Status))
+	}
+	resultBytes, err := json.Marshal(resultMsg.Result)
+	if err != nil {
+		return nil, fmt.Errorf("marshal task result: %w", err)
+	}
+	var results []types.DownloadResult
+	if err := json.Unmarshal(resultBytes, &results); err != nil {
+		return nil, fmt.Errorf("unmarshal download task results: %w", err)
+	}
+
+	archive := &DownloadArchive{TaskID: req.TaskID}
+	matched := false
+	for _, res := range results {
+		if req.Host != "" && req.Host != res.Name && req.Host != res.Host {
+			continue
+		}
+		matched = true
+		hostDir := path.Base(path.Clean("/" + res.Dir))
+		for _, f := range res.Files {
+			entry, err := l.stat(res.Dir, f)
+			if err != nil {
+				return nil, err
+			}
+			entry.name = hostDir + "/" + strings.TrimPrefix(path.Clean("/"+f.Path), "/")
+			archive.entries = append(archive.entries, entry)
+		}
+	}
+	if req.Host != "" && !matched {
+		return nil, errorx.NewCodeError(http.StatusNotFound, fmt.Sprintf("host %s is not part of download task %s", req.Host, req.TaskID))
+	}
+	if len(archive.entries) == 0 {
+		return nil, errorx.NewCodeError(http.StatusNotFound, fmt.Sprintf("download task %s has no downloaded files", req.TaskID))
+	}
+
+	ctx, store := l.ctx, l.svcCtx.Artifacts
+	archive.open = func(e archiveEntry) (io.ReadCloser, error) {
+		if e.artifactID != "" {
+			body, _, err := store.Get(ctx, e.artifactID)
+			return body, err
+		}
+		return os.Open(e.localPath)
+	}
+	return archive, nil
+}
+
+// stat 定位单个文件：artifact 存储按 artifact_id，否则按 Downloads.Dir/<task_id>/<主机>/<路径>
+func (l *DownloadArchiveLogic) stat(dir string, f types.DownloadFileResult) (archiveEntry, error) {
+	if f.ArtifactID != "" {
+		if l.svcCtx.Artifacts == nil {
+			return archiveEntry{}, errors.New("artifact store is not configured")
+		}
+		meta, err := l.svcCtx.Artifacts.Stat(l.ctx, f.ArtifactID)
+		if errors.Is(err, artifact.ErrNotFound) {
+			return archiveEntry{}, errorx.NewCodeError(http.StatusNotFound, fmt.Sprintf("downloaded file %s is no longer available", f.Remote))
+		}
+		if err != nil {
+			return archiveEntry{}, fmt.Errorf("read artifact %s: %w", f.ArtifactID, err)
+		}
+		return archiveEntry{artifactID: f.ArtifactID, size: meta.Size, modTime: meta.CreatedAt}, nil
+	}
+
+	// 结果中的路径先 Clean 为绝对路径再拼接，保证不会越出下载目录
+	localPath := filepath.Join(download.Dir(l.svcCtx.Config.Downloads),
+		filepath.FromSlash(path.Clean("/"+dir)), filepath.FromSlash(path.Clean("/"+f.Path)))
+	info, err := os.Stat(localPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return archiveEntry{}, errorx.NewCodeError(http.StatusNotFound, fmt.Sprintf("downloaded file %s is no longer available", f.Remote))
+	}
+	if err != nil {
+		return archiveEntry{}, err
+	}
+	if !info.Mode().IsRegular() {
+		return archiveEntry{}, fmt.Errorf("downloaded file %s is not a regular file", f.Remote)
+	}
+	return archiveEntry{localPath: localPath, size: info.Size(), modTime: info.ModTime()}, nil
+}
+
+// Write 以 tar.gz 格式写出所有文件；响应头已发送，出错时只能中断输出
+func (a *DownloadArchive) Write(w io.Writer) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, e := range a.entries {
+		if err := a.writeEntry(tw, e); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func (a *DownloadArchive) writeEntry(tw *tar.Writer, e archiveEntry) error {
+	body, err := a.open(e)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", e.name, err)
+	}
+	defer body.Close()
+	if err := tw.WriteHeader(&tar.Header{
+		Name:     e.name,
+		Mode:     0o644,
+		Size:     e.size,
+		ModTime:  e.modTime,
+		Typeflag: tar.TypeReg,
+	}); err != nil {
+		return err
+	}
+	// 按头部记录的大小写出，文件在此期间被改写时不会破坏压缩包结构
+	n, err := io.Copy(tw, io.LimitReader(body, e.size))
+	if err != nil {
+		return fmt.Errorf("write %s: %w", e.name, err)
+	}
+	if n < e.size {
+		return fmt.Errorf("write %s: file shrank from %d to %d bytes", e.name, e.size, n)
+	}
+	return nil
+}
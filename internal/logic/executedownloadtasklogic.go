@@ -0,0 +1,137 @@
+// Code scaffolded by goctl. Safe to edit.
+// goctl 1.9.2
+
+package logic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"gocerery/internal/audit"
+	"gocerery/internal/download"
+	"gocerery/internal/policy"
+	"gocerery/internal/svc"
+	"gocerery/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type ExecuteDownloadTaskLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewExecuteDownloadTaskLogic(ctx context.Context, svcCtx *svc.ServiceContext) *ExecuteDownloadTaskLogic {
+	return &ExecuteDownloadTaskLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+func (l *ExecuteDownloadTaskLogic) ExecuteDownloadTask(req *types.DownloadTaskRequest) (*types.DownloadTaskResponse, error) {
+	if l.svcCtx.CeleryClient == nil {
+		return nil, errors.New("celery client is not configured")
+	}
+	proxy := types.JumpHost{
+		Name:                 req.ProxyName,
+		Host:                 req.ProxyHost,
+		Port:                 req.ProxyPort,
+		User:                 req.ProxyUser,
+		Password:             req.ProxyPassword,
+		PrivateKey:           req.ProxyPrivateKey,
+		PrivateKeyPassphrase: req.ProxyPrivateKeyPassphrase,
+		KeyRef:               req.ProxyKeyRef,
+		PasswordRef:          req.ProxyPasswordRef,
+		HostKeyFingerprint:   req.ProxyHostKeyFingerprint,
+	}
+	jumpHosts, err := resolveJumpHosts(l.svcCtx.Config, req.JumpHosts, proxy, req.Direct)
+	if err != nil {
+		return nil, err
+	}
+	req.JumpHosts = jumpHosts
+	resolved, err := resolveTargets(l.svcCtx.Inventory, req.Targets, req.TargetNames, req.Groups, req.Tags)
+	if err != nil {
+		return nil, err
+	}
+	req.Targets = resolved
+	if err := validateDownloadTaskRequest(req, l.svcCtx.Config.Executor.UseAgent); err != nil {
+		return nil, err
+	}
+
+	taskName := "tasks.download_file"
+	if l.svcCtx.Config.Celery.DownloadTaskName != "" {
+		taskName = l.svcCtx.Config.Celery.DownloadTaskName
+	}
+
+	targets, err := buildTargetPayloads(req.Targets)
+	if err != nil {
+		return nil, err
+	}
+	submittedBy := submitterName(l.ctx)
+	event := newAuditEvent(l.ctx, audit.KindDownload, submittedBy, req.Targets)
+	event.RemotePaths = req.RemotePaths
+	if err := authorizeTask(l.Logger, l.svcCtx.Policy, policy.Request{
+		Principal:   submittedBy,
+		Targets:     policyTargets(req.Targets),
+		Download:    true,
+		RemotePaths: req.RemotePaths,
+	}); err != nil {
+		recordRejected(l.svcCtx.Audit, event, err)
+		return nil, err
+	}
+
+	payload := map[string]interface{}{
+		"jump_hosts":   buildJumpHostPayloads(req.JumpHosts),
+		"targets":      targets,
+		"remote_paths": req.RemotePaths,
+		"timeout":      normalizeTimeout(req.Timeout, l.svcCtx.Config.Executor.TimeoutSeconds),
+		"save_log":     req.SaveLog,
+	}
+	if submittedBy != "" {
+		payload["submitted_by"] = submittedBy
+	}
+	if err := l.svcCtx.Sealer.Seal(payload); err != nil {
+		return nil, fmt.Errorf("encrypt credentials: %w", err)
+	}
+
+	asyncResult, err := l.svcCtx.CeleryClient.DelayKwargs(taskName, payload)
+	if err != nil {
+		return nil, fmt.Errorf("submit download task to celery: %w", err)
+	}
+
+	recordSubmitter(l.Logger, l.svcCtx.TaskState, asyncResult.TaskID, submittedBy)
+	event.Type = audit.EventTaskSubmitted
+	event.TaskID = asyncResult.TaskID
+	l.svcCtx.Audit.Record(event)
+	l.Logger.Infow("submitted download task",
+		logx.Field("task_id", asyncResult.TaskID),
+		logx.Field("targets", len(targets)),
+		logx.Field("submitted_by", submittedBy))
+
+	return &types.DownloadTaskResponse{
+		TaskID:  asyncResult.TaskID,
+		Status:  "PENDING",
+		Message: "download task submitted",
+	}, nil
+}
+
+func validateDownloadTaskRequest(req *types.DownloadTaskRequest, allowAgent bool) error {
+	switch {
+	case len(req.Targets) == 0:
+		return errors.New("targets or target_names/groups/tags is required")
+	case len(req.RemotePaths) == 0:
+		return errors.New("remote_paths is required")
+	}
+	for idx, p := range req.RemotePaths {
+		if err := download.ValidatePattern(p); err != nil {
+			return fmt.Errorf("remote_paths[%d]: %w", idx, err)
+		}
+	}
+	if err := validateJumpHosts(req.JumpHosts, allowAgent); err != nil {
+		return err
+	}
+	return validateTargets(req.Targets, allowAgent)
+}
@@ -8,7 +8,16 @@ import (
 	"errors"
 	"fmt"
 
+	"gocerery/internal/audit"
+	"gocerery/internal/auth"
+	"gocerery/internal/config"
+	"gocerery/internal/errorx"
+	"gocerery/internal/guard"
+	"gocerery/internal/inventory"
+	"gocerery/internal/policy"
+	"gocerery/internal/secrets"
 	"gocerery/internal/svc"
+	"gocerery/internal/taskstate"
 	"gocerery/internal/types"
 
 	"github.com/zeromicro/go-zero/core/logx"
@@ -32,7 +41,29 @@ func (l *ExecuteSshTaskLogic) ExecuteSshTask(req *types.SshTaskRequest) (*types.
 	if l.svcCtx.CeleryClient == nil {
 		return nil, errors.New("celery client is not configured")
 	}
-	if err := validateTaskRequest(req); err != nil {
+	proxy := types.JumpHost{
+		Name:                 req.ProxyName,
+		Host:                 req.ProxyHost,
+		Port:                 req.ProxyPort,
+		User:                 req.ProxyUser,
+		Password:             req.ProxyPassword,
+		PrivateKey:           req.ProxyPrivateKey,
+		PrivateKeyPassphrase: req.ProxyPrivateKeyPassphrase,
+		KeyRef:               req.ProxyKeyRef,
+		PasswordRef:          req.ProxyPasswordRef,
+		HostKeyFingerprint:   req.ProxyHostKeyFingerprint,
+	}
+	jumpHosts, err := resolveJumpHosts(l.svcCtx.Config, req.JumpHosts, proxy, req.Direct)
+	if err != nil {
+		return nil, err
+	}
+	req.JumpHosts = jumpHosts
+	resolved, err := resolveTargets(l.svcCtx.Inventory, req.Targets, req.TargetNames, req.Groups, req.Tags)
+	if err != nil {
+		return nil, err
+	}
+	req.Targets = resolved
+	if err := validateTaskRequest(req, l.svcCtx.Config.Executor.UseAgent); err != nil {
 		return nil, err
 	}
 
@@ -45,16 +76,46 @@ func (l *ExecuteSshTaskLogic) ExecuteSshTask(req *types.SshTaskRequest) (*types.
 	if err != nil {
 		return nil, err
 	}
+	commands, allowedExitCodes := buildCommandPayloads(req)
+	submittedBy := submitterName(l.ctx)
+	event := newAuditEvent(l.ctx, audit.KindSSH, submittedBy, req.Targets)
+	event.Commands = commands
+	event.DangerousAck = req.DangerousAck
+	if err := checkCommands(l.Logger, l.svcCtx.Guard, commands, req.DangerousAck); err != nil {
+		recordRejected(l.svcCtx.Audit, event, err)
+		return nil, err
+	}
+	if err := authorizeTask(l.Logger, l.svcCtx.Policy, policy.Request{
+		Principal: submittedBy,
+		Targets:   policyTargets(req.Targets),
+		Commands:  commands,
+	}); err != nil {
+		recordRejected(l.svcCtx.Audit, event, err)
+		return nil, err
+	}
 
 	payload := map[string]interface{}{
-		"proxy_host":     req.ProxyHost,
-		"proxy_port":     normalizePort(req.ProxyPort),
-		"proxy_user":     req.ProxyUser,
-		"proxy_password": req.ProxyPassword,
-		"targets":        targets,
-		"commands":       req.Commands,
-		"timeout":        normalizeTimeout(req.Timeout, l.svcCtx.Config.Executor.TimeoutSeconds),
-		"save_log":       req.SaveLog,
+		"jump_hosts": buildJumpHostPayloads(req.JumpHosts),
+		"targets":    targets,
+		"commands":   commands,
+		"timeout":    normalizeTimeout(req.Timeout, l.svcCtx.Config.Executor.TimeoutSeconds),
+		"save_log":   req.SaveLog,
+	}
+	if allowedExitCodes != nil {
+		payload["allowed_exit_codes"] = allowedExitCodes
+	}
+	if req.OnError != "" {
+		payload["on_error"] = req.OnError
+	}
+	if req.DangerousAck {
+		payload["dangerous_ack"] = true
+	}
+
+	if submittedBy != "" {
+		payload["submitted_by"] = submittedBy
+	}
+	if err := l.svcCtx.Sealer.Seal(payload); err != nil {
+		return nil, fmt.Errorf("encrypt credentials: %w", err)
 	}
 
 	asyncResult, err := l.svcCtx.CeleryClient.DelayKwargs(taskName, payload)
@@ -62,7 +123,14 @@ func (l *ExecuteSshTaskLogic) ExecuteSshTask(req *types.SshTaskRequest) (*types.
 		return nil, fmt.Errorf("submit task to celery: %w", err)
 	}
 
-	l.Logger.Infof("submitted ssh task %s for %d targets", asyncResult.TaskID, len(targets))
+	recordSubmitter(l.Logger, l.svcCtx.TaskState, asyncResult.TaskID, submittedBy)
+	event.Type = audit.EventTaskSubmitted
+	event.TaskID = asyncResult.TaskID
+	l.svcCtx.Audit.Record(event)
+	l.Logger.Infow("submitted ssh task",
+		logx.Field("task_id", asyncResult.TaskID),
+		logx.Field("targets", len(targets)),
+		logx.Field("submitted_by", submittedBy))
 
 	return &types.SshTaskResponse{
 		TaskID:  asyncResult.TaskID,
@@ -71,33 +139,347 @@ func (l *ExecuteSshTaskLogic) ExecuteSshTask(req *types.SshTaskRequest) (*types.
 	}, nil
 }
 
-func validateTaskRequest(req *types.SshTaskRequest) error {
+func validateTaskRequest(req *types.SshTaskRequest, allowAgent bool) error {
 	switch {
-	case req.ProxyHost == "" || req.ProxyUser == "" || req.ProxyPassword == "":
-		return errors.New("proxy host/user/password are required")
 	case len(req.Targets) == 0:
-		return errors.New("targets cannot be empty")
-	case len(req.Commands) == 0:
+		return errors.New("targets or target_names/groups/tags is required")
+	case len(req.Commands) > 0 && len(req.CommandSpecs) > 0:
+		return errors.New("commands and command_specs cannot be used together")
+	case len(req.Commands) == 0 && len(req.CommandSpecs) == 0:
 		return errors.New("commands cannot be empty")
 	}
-	for idx, t := range req.Targets {
-		if t.Host == "" || t.User == "" || t.Password == "" {
-			return fmt.Errorf("target[%d] host/user/password are required", idx)
+	switch req.OnError {
+	case "", "stop", "continue", "ignore":
+	default:
+		return fmt.Errorf("on_error must be one of stop, continue, ignore: %s", req.OnError)
+	}
+	for idx, spec := range req.CommandSpecs {
+		if spec.Command == "" {
+			return fmt.Errorf("command_specs[%d] command cannot be empty", idx)
+		}
+	}
+	if err := validateJumpHosts(req.JumpHosts, allowAgent); err != nil {
+		return err
+	}
+	return validateTargets(req.Targets, allowAgent)
+}
+
+// buildCommandPayloads 把 command_specs 拆成命令列表与对应的允许退出码，
+// 保持 commands 为字符串数组以兼容旧版 Worker
+func buildCommandPayloads(req *types.SshTaskRequest) ([]string, [][]int) {
+	if len(req.CommandSpecs) == 0 {
+		return req.Commands, nil
+	}
+	commands := make([]string, 0, len(req.CommandSpecs))
+	allowedExitCodes := make([][]int, 0, len(req.CommandSpecs))
+	hasAllowed := false
+	for _, spec := range req.CommandSpecs {
+		commands = append(commands, spec.Command)
+		allowedExitCodes = append(allowedExitCodes, spec.AllowedExitCodes)
+		if len(spec.AllowedExitCodes) > 0 {
+			hasAllowed = true
+		}
+	}
+	if !hasAllowed {
+		return commands, nil
+	}
+	return commands, allowedExitCodes
+}
+
+func validateTargets(targets []types.TargetCredential, allowAgent bool) error {
+	for idx, t := range targets {
+		if t.Host == "" || t.User == "" {
+			return fmt.Errorf("target[%d] host/user are required", idx)
+		}
+		if !hasCredential(t.Password, t.PasswordRef, t.PrivateKey, t.KeyRef, allowAgent) {
+			return fmt.Errorf("target[%d] password/password_ref/private_key/key_ref is required", idx)
+		}
+		if err := validateSecretRefs(t.Password, t.PasswordRef, t.KeyRef); err != nil {
+			return fmt.Errorf("target[%d] %w", idx, err)
 		}
 	}
 	return nil
 }
 
+// resolveJumpHosts 确定任务的跳板机链：jump_hosts 按顺序逐跳连接；未提供时兼容旧的 proxy_* / proxy_name 作为单跳；
+// 两者都未提供时，direct 为 true 或未配置默认 Bastion 则直连，否则经默认 Bastion 连接。
+// 带 name 的跳板机从 Bastions 中展开
+func resolveJumpHosts(c config.Config, jumpHosts []types.JumpHost, proxy types.JumpHost, direct bool) ([]types.JumpHost, error) {
+	hasProxy := proxy != types.JumpHost{}
+	switch {
+	case len(jumpHosts) > 0 && hasProxy:
+		return nil, errors.New("jump_hosts and proxy_* cannot be used together")
+	case direct && (len(jumpHosts) > 0 || hasProxy):
+		return nil, errors.New("direct cannot be used together with jump_hosts or proxy_*")
+	}
+	switch {
+	case len(jumpHosts) > 0:
+	case hasProxy:
+		jumpHosts = []types.JumpHost{proxy}
+	case !direct && c.Bastion.Host != "":
+		jumpHosts = []types.JumpHost{bastionJumpHost(c.Bastion)}
+	default:
+		return nil, nil
+	}
+
+	hops := make([]types.JumpHost, 0, len(jumpHosts))
+	for idx, hop := range jumpHosts {
+		if hop.Name != "" {
+			if hop.Host != "" {
+				return nil, fmt.Errorf("jump_hosts[%d] name and host cannot be used together", idx)
+			}
+			bastion, ok := findBastion(c.Bastions, hop.Name)
+			if !ok {
+				return nil, fmt.Errorf("unknown jump host name: %s", hop.Name)
+			}
+			hop = bastionJumpHost(bastion)
+		}
+		hops = append(hops, hop)
+	}
+	return hops, nil
+}
+
+func findBastion(bastions []config.BastionConfig, name string) (config.BastionConfig, bool) {
+	for _, b := range bastions {
+		if b.Name == name {
+			return b, true
+		}
+	}
+	return config.BastionConfig{}, false
+}
+
+func bastionJumpHost(b config.BastionConfig) types.JumpHost {
+	return types.JumpHost{
+		Name:                 b.Name,
+		Host:                 b.Host,
+		Port:                 b.Port,
+		User:                 b.User,
+		Password:             b.Password,
+		PrivateKey:           b.PrivateKey,
+		PrivateKeyPassphrase: b.PrivateKeyPassphrase,
+		KeyRef:               b.KeyRef,
+		PasswordRef:          b.PasswordRef,
+		HostKeyFingerprint:   b.HostKeyFingerprint,
+	}
+}
+
+func validateJumpHosts(hops []types.JumpHost, allowAgent bool) error {
+	for idx, hop := range hops {
+		if hop.Host == "" || hop.User == "" {
+			return fmt.Errorf("jump_hosts[%d] host/user are required", idx)
+		}
+		if !hasCredential(hop.Password, hop.PasswordRef, hop.PrivateKey, hop.KeyRef, allowAgent) {
+			return fmt.Errorf("jump_hosts[%d] password/password_ref/private_key/key_ref is required", idx)
+		}
+		if err := validateSecretRefs(hop.Password, hop.PasswordRef, hop.KeyRef); err != nil {
+			return fmt.Errorf("jump_hosts[%d] %w", idx, err)
+		}
+	}
+	return nil
+}
+
+// resolveTargets 从主机清单展开 target_names/groups/tags，追加在请求显式给出的 targets 之后
+func resolveTargets(inv *inventory.Inventory, targets []types.TargetCredential, names, groups []string, tags map[string]string) ([]types.TargetCredential, error) {
+	selected, err := inv.Select(names, groups, tags)
+	if err != nil {
+		return nil, err
+	}
+	explicit := make(map[string]bool, len(targets))
+	for _, t := range targets {
+		explicit[t.Name] = true
+	}
+	for _, t := range selected {
+		if explicit[t.Name] {
+			return nil, fmt.Errorf("target %s is given in both targets and the inventory selection", t.Name)
+		}
+		targets = append(targets, types.TargetCredential{
+			Name:                 t.Name,
+			Host:                 t.Host,
+			Port:                 t.Port,
+			User:                 t.User,
+			Password:             t.Password,
+			PrivateKey:           t.PrivateKey,
+			PrivateKeyPassphrase: t.PrivateKeyPassphrase,
+			KeyRef:               t.KeyRef,
+			PasswordRef:          t.PasswordRef,
+			HostKeyFingerprint:   t.HostKeyFingerprint,
+		})
+	}
+	return targets, nil
+}
+
+// submitterName 调用方身份，未启用认证时为空
+func submitterName(ctx context.Context) string {
+	if id, ok := auth.FromContext(ctx); ok {
+		return id.Name
+	}
+	return ""
+}
+
+// newAuditEvent 生成 API 侧的审计记录，只包含目标主机的地址与账号
+func newAuditEvent(ctx context.Context, kind, principal string, targets []types.TargetCredential) audit.Event {
+	event := audit.Event{
+		Source:    audit.SourceAPI,
+		Kind:      kind,
+		Principal: principal,
+		SourceIP:  audit.SourceIP(ctx),
+		Targets:   make([]audit.Target, 0, len(targets)),
+	}
+	for _, t := range targets {
+		event.Targets = append(event.Targets, audit.Target{Name: t.Name, Host: t.Host, Port: normalizePort(t.Port), User: t.User})
+	}
+	return event
+}
+
+// recordRejected 记录被命令防护或授权策略拒绝的任务
+func recordRejected(recorder *audit.Recorder, event audit.Event, err error) {
+	event.Type = audit.EventTaskRejected
+	event.Error = err.Error()
+	recorder.Record(event)
+}
+
+// checkCommands 按命令防护规则检查，拒绝时返回 403 错误及命中的规则；
+// dangerous_ack 放行的软规则记录到日志
+func checkCommands(logger logx.Logger, g *guard.Guard, commands []string, ack bool) error {
+	acked, err := g.Check(commands, ack)
+	if err != nil {
+		logger.Infow("task rejected by command guard", logx.Field("reason", err.Error()))
+		return errorx.Forbidden("%s", err)
+	}
+	for _, v := range acked {
+		logger.Infow("dangerous command acknowledged",
+			logx.Field("command", v.Command),
+			logx.Field("rule", v.Rule))
+	}
+	return nil
+}
+
+// authorizeTask 按授权策略检查任务，拒绝时记录日志并返回 403 错误
+func authorizeTask(logger logx.Logger, engine *policy.Engine, req policy.Request) error {
+	if err := engine.Authorize(req); err != nil {
+		logger.Infow("task rejected by policy",
+			logx.Field("principal", req.Principal),
+			logx.Field("reason", err.Error()))
+		return err
+	}
+	return nil
+}
+
+func policyTargets(targets []types.TargetCredential) []policy.Target {
+	result := make([]policy.Target, 0, len(targets))
+	for _, t := range targets {
+		result = append(result, policy.Target{Name: t.Name, Host: t.Host, Port: t.Port, User: t.User})
+	}
+	return result
+}
+
+// recordSubmitter 记录任务已提交及其提交者；任务已提交，记录失败只打印日志
+func recordSubmitter(logger logx.Logger, store *taskstate.Store, taskID, name string) {
+	if store == nil {
+		return
+	}
+	if err := store.MarkSubmitted(taskID); err != nil {
+		logger.Errorf("mark task %s submitted: %v", taskID, err)
+	}
+	if name == "" {
+		return
+	}
+	if err := store.SetSubmitter(taskID, name); err != nil {
+		logger.Errorf("record submitter of task %s: %v", taskID, err)
+	}
+}
+
+// taskSubmitter 查询任务的提交者，查询失败时返回空字符串
+func taskSubmitter(logger logx.Logger, store *taskstate.Store, taskID string) string {
+	if store == nil {
+		return ""
+	}
+	name, err := store.Submitter(taskID)
+	if err != nil {
+		logger.Errorf("query submitter of task %s: %v", taskID, err)
+	}
+	return name
+}
+
+// hasCredential 密码、password_ref、私钥、key_ref 任选其一；Worker 启用 ssh-agent 时允许全部为空
+func hasCredential(password, passwordRef, privateKey, keyRef string, allowAgent bool) bool {
+	return password != "" || passwordRef != "" || privateKey != "" || keyRef != "" || allowAgent
+}
+
+// validateSecretRefs 提前检查引用格式；引用的内容由 Worker 解析
+func validateSecretRefs(password, passwordRef, keyRef string) error {
+	if passwordRef != "" {
+		if password != "" {
+			return errors.New("password and password_ref cannot be used together")
+		}
+		if _, _, err := secrets.Parse(passwordRef); err != nil {
+			return fmt.Errorf("password_ref: %w", err)
+		}
+	}
+	if secrets.IsRef(keyRef) {
+		if _, _, err := secrets.Parse(keyRef); err != nil {
+			return fmt.Errorf("key_ref: %w", err)
+		}
+	}
+	return nil
+}
+
+// buildJumpHostPayloads 按连接顺序生成跳板机列表，空列表表示直连
+func buildJumpHostPayloads(hops []types.JumpHost) []map[string]interface{} {
+	payloads := make([]map[string]interface{}, 0, len(hops))
+	for _, hop := range hops {
+		payload := map[string]interface{}{
+			"host":     hop.Host,
+			"port":     normalizePort(hop.Port),
+			"user":     hop.User,
+			"password": hop.Password,
+		}
+		if hop.PrivateKey != "" {
+			payload["private_key"] = hop.PrivateKey
+		}
+		if hop.PrivateKeyPassphrase != "" {
+			payload["private_key_passphrase"] = hop.PrivateKeyPassphrase
+		}
+		if hop.KeyRef != "" {
+			payload["key_ref"] = hop.KeyRef
+		}
+		if hop.PasswordRef != "" {
+			payload["password_ref"] = hop.PasswordRef
+		}
+		if hop.HostKeyFingerprint != "" {
+			payload["host_key_fingerprint"] = hop.HostKeyFingerprint
+		}
+		payloads = append(payloads, payload)
+	}
+	return payloads
+}
+
 func buildTargetPayloads(targets []types.TargetCredential) ([]map[string]interface{}, error) {
 	payloads := make([]map[string]interface{}, 0, len(targets))
 	for _, t := range targets {
-		payloads = append(payloads, map[string]interface{}{
+		payload := map[string]interface{}{
 			"name":     t.Name,
 			"host":     t.Host,
 			"port":     normalizePort(t.Port),
 			"user":     t.User,
 			"password": t.Password,
-		})
+		}
+		if t.PrivateKey != "" {
+			payload["private_key"] = t.PrivateKey
+		}
+		if t.PrivateKeyPassphrase != "" {
+			payload["private_key_passphrase"] = t.PrivateKeyPassphrase
+		}
+		if t.KeyRef != "" {
+			payload["key_ref"] = t.KeyRef
+		}
+		if t.PasswordRef != "" {
+			payload["password_ref"] = t.PasswordRef
+		}
+		if t.HostKeyFingerprint != "" {
+			payload["host_key_fingerprint"] = t.HostKeyFingerprint
+		}
+		payloads = append(payloads, payload)
 	}
 	return payloads, nil
 }
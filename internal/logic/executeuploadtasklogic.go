@@ -7,7 +7,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"regexp"
+	"strconv"
 
+	"gocerery/internal/audit"
+	"gocerery/internal/pathfilter"
+	"gocerery/internal/policy"
 	"gocerery/internal/svc"
 	"gocerery/internal/types"
 
@@ -32,7 +37,29 @@ func (l *ExecuteUploadTaskLogic) ExecuteUploadTask(req *types.UploadTaskRequest)
 	if l.svcCtx.CeleryClient == nil {
 		return nil, errors.New("celery client is not configured")
 	}
-	if err := validateUploadTaskRequest(req); err != nil {
+	proxy := types.JumpHost{
+		Name:                 req.ProxyName,
+		Host:                 req.ProxyHost,
+		Port:                 req.ProxyPort,
+		User:                 req.ProxyUser,
+		Password:             req.ProxyPassword,
+		PrivateKey:           req.ProxyPrivateKey,
+		PrivateKeyPassphrase: req.ProxyPrivateKeyPassphrase,
+		KeyRef:               req.ProxyKeyRef,
+		PasswordRef:          req.ProxyPasswordRef,
+		HostKeyFingerprint:   req.ProxyHostKeyFingerprint,
+	}
+	jumpHosts, err := resolveJumpHosts(l.svcCtx.Config, req.JumpHosts, proxy, req.Direct)
+	if err != nil {
+		return nil, err
+	}
+	req.JumpHosts = jumpHosts
+	resolved, err := resolveTargets(l.svcCtx.Inventory, req.Targets, req.TargetNames, req.Groups, req.Tags)
+	if err != nil {
+		return nil, err
+	}
+	req.Targets = resolved
+	if err := validateUploadTaskRequest(req, l.svcCtx.Config.Executor.UseAgent); err != nil {
 		return nil, err
 	}
 
@@ -45,17 +72,74 @@ func (l *ExecuteUploadTaskLogic) ExecuteUploadTask(req *types.UploadTaskRequest)
 	if err != nil {
 		return nil, err
 	}
+	submittedBy := submitterName(l.ctx)
+	event := newAuditEvent(l.ctx, audit.KindUpload, submittedBy, req.Targets)
+	event.LocalPath = req.LocalPath
+	event.ArtifactID = req.ArtifactID
+	event.RemotePath = req.RemotePath
+	if req.ArtifactID != "" {
+		if _, err := resolveArtifact(l.ctx, l.svcCtx.Artifacts, req.ArtifactID, submittedBy); err != nil {
+			recordRejected(l.svcCtx.Audit, event, err)
+			return nil, err
+		}
+	}
+	if err := authorizeTask(l.Logger, l.svcCtx.Policy, policy.Request{
+		Principal:  submittedBy,
+		Targets:    policyTargets(req.Targets),
+		Upload:     true,
+		LocalPath:  req.LocalPath,
+		ArtifactID: req.ArtifactID,
+		RemotePath: req.RemotePath,
+	}); err != nil {
+		recordRejected(l.svcCtx.Audit, event, err)
+		return nil, err
+	}
 
 	payload := map[string]interface{}{
-		"proxy_host":     req.ProxyHost,
-		"proxy_port":     normalizePort(req.ProxyPort),
-		"proxy_user":     req.ProxyUser,
-		"proxy_password": req.ProxyPassword,
-		"targets":        targets,
-		"local_path":     req.LocalPath,
-		"remote_path":    req.RemotePath,
-		"timeout":        normalizeTimeout(req.Timeout, l.svcCtx.Config.Executor.TimeoutSeconds),
-		"save_log":       req.SaveLog,
+		"jump_hosts":  buildJumpHostPayloads(req.JumpHosts),
+		"targets":     targets,
+		"remote_path": req.RemotePath,
+		"timeout":     normalizeTimeout(req.Timeout, l.svcCtx.Config.Executor.TimeoutSeconds),
+		"save_log":    req.SaveLog,
+	}
+	if req.ArtifactID != "" {
+		payload["artifact_id"] = req.ArtifactID
+	} else {
+		payload["local_path"] = req.LocalPath
+	}
+	if req.UploadMode != "" {
+		payload["upload_mode"] = req.UploadMode
+	}
+	if req.SyncCompare != "" {
+		payload["sync_compare"] = req.SyncCompare
+	}
+	if req.Mode != "" {
+		payload["mode"] = req.Mode
+	}
+	if req.Owner != "" {
+		payload["owner"] = req.Owner
+	}
+	if req.Group != "" {
+		payload["group"] = req.Group
+	}
+	if req.Atomic {
+		payload["atomic"] = true
+	}
+	if req.BackupExisting {
+		payload["backup_existing"] = true
+	}
+	if len(req.Include) > 0 {
+		payload["include"] = req.Include
+	}
+	if len(req.Exclude) > 0 {
+		payload["exclude"] = req.Exclude
+	}
+
+	if submittedBy != "" {
+		payload["submitted_by"] = submittedBy
+	}
+	if err := l.svcCtx.Sealer.Seal(payload); err != nil {
+		return nil, fmt.Errorf("encrypt credentials: %w", err)
 	}
 
 	asyncResult, err := l.svcCtx.CeleryClient.DelayKwargs(taskName, payload)
@@ -63,7 +147,14 @@ func (l *ExecuteUploadTaskLogic) ExecuteUploadTask(req *types.UploadTaskRequest)
 		return nil, fmt.Errorf("submit upload task to celery: %w", err)
 	}
 
-	l.Logger.Infof("submitted upload task %s for %d targets", asyncResult.TaskID, len(targets))
+	recordSubmitter(l.Logger, l.svcCtx.TaskState, asyncResult.TaskID, submittedBy)
+	event.Type = audit.EventTaskSubmitted
+	event.TaskID = asyncResult.TaskID
+	l.svcCtx.Audit.Record(event)
+	l.Logger.Infow("submitted upload task",
+		logx.Field("task_id", asyncResult.TaskID),
+		logx.Field("targets", len(targets)),
+		logx.Field("submitted_by", submittedBy))
 
 	return &types.UploadTaskResponse{
 		TaskID:  asyncResult.TaskID,
@@ -72,21 +163,59 @@ func (l *ExecuteUploadTaskLogic) ExecuteUploadTask(req *types.UploadTaskRequest)
 	}, nil
 }
 
-func validateUploadTaskRequest(req *types.UploadTaskRequest) error {
+func validateUploadTaskRequest(req *types.UploadTaskRequest, allowAgent bool) error {
 	switch {
-	case req.ProxyHost == "" || req.ProxyUser == "" || req.ProxyPassword == "":
-		return errors.New("proxy host/user/password are required")
 	case len(req.Targets) == 0:
-		return errors.New("targets cannot be empty")
-	case req.LocalPath == "":
-		return errors.New("local_path is required")
+		return errors.New("targets or target_names/groups/tags is required")
+	case req.LocalPath == "" && req.ArtifactID == "":
+		return errors.New("local_path or artifact_id is required")
+	case req.LocalPath != "" && req.ArtifactID != "":
+		return errors.New("local_path and artifact_id cannot be used together")
 	case req.RemotePath == "":
 		return errors.New("remote_path is required")
 	}
-	for idx, t := range req.Targets {
-		if t.Host == "" || t.User == "" || t.Password == "" {
-			return fmt.Errorf("target[%d] host/user/password are required", idx)
+	switch req.UploadMode {
+	case "", "overwrite", "skip_existing", "sync":
+	default:
+		return fmt.Errorf("upload_mode must be one of overwrite, skip_existing, sync: %s", req.UploadMode)
+	}
+	switch req.SyncCompare {
+	case "":
+	case "size_mtime", "sha256":
+		if req.UploadMode != "sync" {
+			return errors.New("sync_compare requires upload_mode sync")
 		}
+	default:
+		return fmt.Errorf("sync_compare must be one of size_mtime, sha256: %s", req.SyncCompare)
+	}
+	if err := validateFilePlacement(req); err != nil {
+		return err
+	}
+	if _, err := pathfilter.New(req.Include, req.Exclude); err != nil {
+		return err
+	}
+	if err := validateJumpHosts(req.JumpHosts, allowAgent); err != nil {
+		return err
+	}
+	return validateTargets(req.Targets, allowAgent)
+}
+
+// accountNamePattern 远端用户名或组名，也可以是数字 ID
+var accountNamePattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,31}$`)
+
+// validateFilePlacement 校验 mode（八进制，如 0644）、owner 与 group
+func validateFilePlacement(req *types.UploadTaskRequest) error {
+	if req.Mode != "" {
+		mode, err := strconv.ParseUint(req.Mode, 8, 32)
+		if err != nil || mode > 0o7777 {
+			return fmt.Errorf("mode must be an octal permission such as 0644: %s", req.Mode)
+		}
+	}
+	if req.Owner != "" && !accountNamePattern.MatchString(req.Owner) {
+		return fmt.Errorf("invalid owner: %s", req.Owner)
+	}
+	if req.Group != "" && !accountNamePattern.MatchString(req.Group) {
+		return fmt.Errorf("invalid group: %s", req.Group)
 	}
 	return nil
 }
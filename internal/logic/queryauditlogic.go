@@ -0,0 +1,118 @@
+// Code scaffolded by goctl. Safe to edit.
+// goctl 1.9.2
+
+package logic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"gocerery/internal/audit"
+	"gocerery/internal/errorx"
+	"gocerery/internal/svc"
+	"gocerery/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type QueryAuditLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewQueryAuditLogic(ctx context.Context, svcCtx *svc.ServiceContext) *QueryAuditLogic {
+	return &QueryAuditLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+func (l *QueryAuditLogic) QueryAudit(req *types.AuditRequest) (*types.AuditResponse, error) {
+	if !l.svcCtx.Audit.Enabled() {
+		return nil, audit.ErrDisabled
+	}
+	readers := l.svcCtx.Config.Audit.Readers
+	if principal := submitterName(l.ctx); len(readers) > 0 && !slices.Contains(readers, principal) {
+		return nil, errorx.Forbidden("principal %q is not allowed to read the audit log", principal)
+	}
+
+	since, err := parseAuditTime("since", req.Since)
+	if err != nil {
+		return nil, err
+	}
+	until, err := parseAuditTime("until", req.Until)
+	if err != nil {
+		return nil, err
+	}
+	if req.Limit < 0 {
+		return nil, errors.New("limit cannot be negative")
+	}
+
+	events, err := l.svcCtx.Audit.Query(audit.Filter{
+		Since:  since,
+		Until:  until,
+		User:   req.User,
+		Host:   req.Host,
+		TaskID: req.TaskID,
+		Limit:  req.Limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &types.AuditResponse{Events: make([]types.AuditEvent, 0, len(events))}
+	for _, ev := range events {
+		resp.Events = append(resp.Events, toAuditEvent(ev))
+	}
+	return resp, nil
+}
+
+// parseAuditTime 时间过滤条件使用 RFC3339 格式，为空时不过滤
+func parseAuditTime(name, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s must be an RFC3339 time: %s", name, value)
+	}
+	return t, nil
+}
+
+func toAuditEvent(ev audit.Event) types.AuditEvent {
+	out := types.AuditEvent{
+		Time:         ev.Time.Format(time.RFC3339Nano),
+		Type:         ev.Type,
+		Source:       ev.Source,
+		Kind:         ev.Kind,
+		TaskID:       ev.TaskID,
+		Principal:    ev.Principal,
+		SourceIP:     ev.SourceIP,
+		Commands:     ev.Commands,
+		LocalPath:    ev.LocalPath,
+		ArtifactID:   ev.ArtifactID,
+		RemotePath:   ev.RemotePath,
+		RemotePaths:  ev.RemotePaths,
+		DangerousAck: ev.DangerousAck,
+		Status:       ev.Status,
+		Error:        ev.Error,
+	}
+	for _, t := range ev.Targets {
+		out.Targets = append(out.Targets, types.AuditTarget{Name: t.Name, Host: t.Host, Port: t.Port, User: t.User})
+	}
+	for _, h := range ev.Hosts {
+		out.Hosts = append(out.Hosts, types.AuditHostOutcome{
+			Name:     h.Name,
+			Host:     h.Host,
+			Success:  h.Success,
+			ExitCode: h.ExitCode,
+			Error:    h.Error,
+		})
+	}
+	return out
+}
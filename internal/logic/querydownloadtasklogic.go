@@ -0,0 +1,85 @@
+// Code scaffolded by goctl. Safe to edit.
+// goctl 1.9.2
+
+package logic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"gocerery/internal/svc"
+	"gocerery/internal/taskstate"
+	"gocerery/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type QueryDownloadTaskLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewQueryDownloadTaskLogic(ctx context.Context, svcCtx *svc.ServiceContext) *QueryDownloadTaskLogic {
+	return &QueryDownloadTaskLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+func (l *QueryDownloadTaskLogic) QueryDownloadTask(req *types.SshTaskStatusRequest) (*types.DownloadTaskStatusResponse, error) {
+	if req.TaskID == "" {
+		return nil, errors.New("task_id is required")
+	}
+	if l.svcCtx.CeleryBackend == nil {
+		return nil, errors.New("celery backend is not configured")
+	}
+
+	resultMsg, err := l.svcCtx.CeleryBackend.GetResult(req.TaskID)
+	if err != nil {
+		l.Logger.Infof("download task %s not finished yet: %v", req.TaskID, err)
+		return &types.DownloadTaskStatusResponse{
+			TaskID:      req.TaskID,
+			Status:      "PENDING",
+			Error:       err.Error(),
+			SubmittedBy: taskSubmitter(l.Logger, l.svcCtx.TaskState, req.TaskID),
+		}, nil
+	}
+
+	resp := &types.DownloadTaskStatusResponse{
+		TaskID:      req.TaskID,
+		Status:      resultMsg.Status,
+		SubmittedBy: taskSubmitter(l.Logger, l.svcCtx.TaskState, req.TaskID),
+	}
+
+	if resultMsg.Result != nil {
+		resultBytes, marshalErr := json.Marshal(resultMsg.Result)
+		if marshalErr != nil {
+			return nil, fmt.Errorf("marshal task result: %w", marshalErr)
+		}
+		var downloadResults []types.DownloadResult
+		if err := json.Unmarshal(resultBytes, &downloadResults); err != nil {
+			return nil, fmt.Errorf("unmarshal download task results: %w", err)
+		}
+		resp.Results = downloadResults
+		resp.Total = len(downloadResults)
+		// Aggregate error from results if any target failed
+		for _, dr := range downloadResults {
+			if taskstate.HostCompleted(resultMsg.Status, dr.State) {
+				resp.Completed++
+			}
+			if !dr.Success && dr.Error != "" && resp.Error == "" {
+				resp.Error = fmt.Sprintf("some targets failed: %s", dr.Error)
+			}
+		}
+	}
+
+	if resp.Error == "" && resultMsg.Status != taskstate.StatusSuccess && !taskstate.Running(resultMsg.Status) {
+		resp.Error = fmt.Sprintf("task status: %s", resultMsg.Status)
+	}
+
+	return resp, nil
+}
@@ -10,6 +10,7 @@ import (
 	"fmt"
 
 	"gocerery/internal/svc"
+	"gocerery/internal/taskstate"
 	"gocerery/internal/types"
 
 	"github.com/zeromicro/go-zero/core/logx"
@@ -41,15 +42,17 @@ func (l *QuerySshTaskLogic) QuerySshTask(req *types.SshTaskStatusRequest) (*type
 	if err != nil {
 		l.Logger.Infof("task %s not finished yet: %v", req.TaskID, err)
 		return &types.SshTaskStatusResponse{
-			TaskID: req.TaskID,
-			Status: "PENDING",
-			Error:  err.Error(),
+			TaskID:      req.TaskID,
+			Status:      "PENDING",
+			Error:       err.Error(),
+			SubmittedBy: taskSubmitter(l.Logger, l.svcCtx.TaskState, req.TaskID),
 		}, nil
 	}
 
 	resp := &types.SshTaskStatusResponse{
-		TaskID: req.TaskID,
-		Status: resultMsg.Status,
+		TaskID:      req.TaskID,
+		Status:      resultMsg.Status,
+		SubmittedBy: taskSubmitter(l.Logger, l.svcCtx.TaskState, req.TaskID),
 	}
 
 	if resultMsg.Result != nil {
@@ -62,15 +65,18 @@ func (l *QuerySshTaskLogic) QuerySshTask(req *types.SshTaskStatusRequest) (*type
 			return nil, fmt.Errorf("unmarshal task result: %w", err)
 		}
 		resp.Results = hostResults
+		resp.Total = len(hostResults)
 		for _, item := range hostResults {
-			if !item.Success {
+			if taskstate.HostCompleted(resultMsg.Status, item.State) {
+				resp.Completed++
+			}
+			if !item.Success && resp.Error == "" {
 				resp.Error = item.Error
-				break
 			}
 		}
 	}
 
-	if resp.Error == "" && resultMsg.Status != "SUCCESS" {
+	if resp.Error == "" && resultMsg.Status != taskstate.StatusSuccess && !taskstate.Running(resultMsg.Status) {
 		resp.Error = fmt.Sprintf("task status: %s", resultMsg.Status)
 	}
 
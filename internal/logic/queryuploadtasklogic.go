@@ -10,6 +10,7 @@ import (
 	"fmt"
 
 	"gocerery/internal/svc"
+	"gocerery/internal/taskstate"
 	"gocerery/internal/types"
 
 	"github.com/zeromicro/go-zero/core/logx"
@@ -41,15 +42,17 @@ func (l *QueryUploadTaskLogic) QueryUploadTask(req *types.SshTaskStatusRequest)
 	if err != nil {
 		l.Logger.Infof("upload task %s not finished yet: %v", req.TaskID, err)
 		return &types.UploadTaskStatusResponse{
-			TaskID: req.TaskID,
-			Status: "PENDING",
-			Error:  err.Error(),
+			TaskID:      req.TaskID,
+			Status:      "PENDING",
+			Error:       err.Error(),
+			SubmittedBy: taskSubmitter(l.Logger, l.svcCtx.TaskState, req.TaskID),
 		}, nil
 	}
 
 	resp := &types.UploadTaskStatusResponse{
-		TaskID: req.TaskID,
-		Status: resultMsg.Status,
+		TaskID:      req.TaskID,
+		Status:      resultMsg.Status,
+		SubmittedBy: taskSubmitter(l.Logger, l.svcCtx.TaskState, req.TaskID),
 	}
 
 	if resultMsg.Result != nil {
@@ -57,60 +60,24 @@ func (l *QueryUploadTaskLogic) QueryUploadTask(req *types.SshTaskStatusRequest)
 		if marshalErr != nil {
 			return nil, fmt.Errorf("marshal task result: %w", marshalErr)
 		}
-		var rawResults []map[string]interface{}
-		if err := json.Unmarshal(resultBytes, &rawResults); err != nil {
+		var uploadResults []types.UploadResult
+		if err := json.Unmarshal(resultBytes, &uploadResults); err != nil {
 			return nil, fmt.Errorf("unmarshal upload task results: %w", err)
 		}
-		uploadResults := make([]types.UploadResult, 0, len(rawResults))
-		for _, raw := range rawResults {
-			ur := types.UploadResult{}
-			if name, ok := raw["name"].(string); ok {
-				ur.Name = name
-			}
-			if host, ok := raw["host"].(string); ok {
-				ur.Host = host
-			}
-			if success, ok := raw["success"].(bool); ok {
-				ur.Success = success
-			}
-			if errMsg, ok := raw["error"].(string); ok {
-				ur.Error = errMsg
-			}
-			// 解析 uploaded_files
-			if uploadedFiles, ok := raw["uploaded_files"].([]interface{}); ok {
-				ur.UploadedFiles = make([]string, 0, len(uploadedFiles))
-				for _, f := range uploadedFiles {
-					if fileInfo, ok := f.(map[string]interface{}); ok {
-						if remote, ok := fileInfo["remote"].(string); ok {
-							ur.UploadedFiles = append(ur.UploadedFiles, remote)
-						}
-					}
-				}
-			}
-			// 解析 failed_files
-			if failedFiles, ok := raw["failed_files"].([]interface{}); ok {
-				ur.FailedFiles = make([]string, 0, len(failedFiles))
-				for _, f := range failedFiles {
-					if fileInfo, ok := f.(map[string]interface{}); ok {
-						if remote, ok := fileInfo["remote"].(string); ok {
-							ur.FailedFiles = append(ur.FailedFiles, remote)
-						}
-					}
-				}
-			}
-			uploadResults = append(uploadResults, ur)
-		}
 		resp.Results = uploadResults
+		resp.Total = len(uploadResults)
 		// Aggregate error from results if any target failed
 		for _, ur := range uploadResults {
-			if !ur.Success && ur.Error != "" {
+			if taskstate.HostCompleted(resultMsg.Status, ur.State) {
+				resp.Completed++
+			}
+			if !ur.Success && ur.Error != "" && resp.Error == "" {
 				resp.Error = fmt.Sprintf("some targets failed: %s", ur.Error)
-				break
 			}
 		}
 	}
 
-	if resp.Error == "" && resultMsg.Status != "SUCCESS" {
+	if resp.Error == "" && resultMsg.Status != taskstate.StatusSuccess && !taskstate.Running(resultMsg.Status) {
 		resp.Error = fmt.Sprintf("task status: %s", resultMsg.Status)
 	}
 
@@ -0,0 +1,31 @@
+// Code scaffolded by goctl. Safe to edit.
+// goctl 1.9.2
+
+package logic
+
+import (
+	"context"
+
+	"gocerery/internal/svc"
+	"gocerery/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type RevokeDownloadTaskLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewRevokeDownloadTaskLogic(ctx context.Context, svcCtx *svc.ServiceContext) *RevokeDownloadTaskLogic {
+	return &RevokeDownloadTaskLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+func (l *RevokeDownloadTaskLogic) RevokeDownloadTask(req *types.SshTaskStatusRequest) (*types.TaskRevokeResponse, error) {
+	return revokeTask(l.Logger, l.svcCtx, req.TaskID)
+}
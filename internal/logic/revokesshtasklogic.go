@@ -0,0 +1,82 @@
+// Code scaffolded by goctl. Safe to edit.
+// goctl 1.9.2
+
+package logic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"gocerery/internal/errorx"
+	"gocerery/internal/svc"
+	"gocerery/internal/taskstate"
+	"gocerery/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type RevokeSshTaskLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewRevokeSshTaskLogic(ctx context.Context, svcCtx *svc.ServiceContext) *RevokeSshTaskLogic {
+	return &RevokeSshTaskLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+func (l *RevokeSshTaskLogic) RevokeSshTask(req *types.SshTaskStatusRequest) (*types.TaskRevokeResponse, error) {
+	return revokeTask(l.Logger, l.svcCtx, req.TaskID)
+}
+
+// revokeTask 标记任务撤销：未开始的任务直接记为 REVOKED，执行中的任务由 Worker 终止后写入部分结果
+func revokeTask(logger logx.Logger, svcCtx *svc.ServiceContext, taskID string) (*types.TaskRevokeResponse, error) {
+	if taskID == "" {
+		return nil, errors.New("task_id is required")
+	}
+	if svcCtx.CeleryBackend == nil || svcCtx.TaskState == nil {
+		return nil, errors.New("celery backend is not configured")
+	}
+
+	running := false
+	if resultMsg, err := svcCtx.CeleryBackend.GetResult(taskID); err == nil {
+		if taskstate.Finished(resultMsg.Status) {
+			return nil, fmt.Errorf("task %s already finished with status %s", taskID, resultMsg.Status)
+		}
+		running = taskstate.Running(resultMsg.Status)
+	} else {
+		// 没有结果时只撤销由 API 提交、仍在排队的任务，不为未知的 task_id 写入 REVOKED
+		submitted, err := svcCtx.TaskState.Submitted(taskID)
+		if err != nil {
+			return nil, fmt.Errorf("query task %s: %w", taskID, err)
+		}
+		if !submitted {
+			return nil, errorx.NewCodeError(http.StatusNotFound, fmt.Sprintf("task %s not found", taskID))
+		}
+	}
+
+	if err := svcCtx.TaskState.Revoke(taskID); err != nil {
+		return nil, fmt.Errorf("revoke task: %w", err)
+	}
+	message := "task revoked"
+	if running {
+		// 由 Worker 终止执行并写入带部分结果的 REVOKED 状态
+		message = "revoke requested, running task will be stopped"
+	} else if err := svcCtx.TaskState.SetStatus(taskID, taskstate.StatusRevoked, nil); err != nil {
+		return nil, fmt.Errorf("store revoked status: %w", err)
+	}
+
+	logger.Infof("revoked task %s (running=%v)", taskID, running)
+
+	return &types.TaskRevokeResponse{
+		TaskID:  taskID,
+		Status:  taskstate.StatusRevoked,
+		Message: message,
+	}, nil
+}
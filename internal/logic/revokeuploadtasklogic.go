@@ -0,0 +1,31 @@
+// Code scaffolded by goctl. Safe to edit.
+// goctl 1.9.2
+
+package logic
+
+import (
+	"context"
+
+	"gocerery/internal/svc"
+	"gocerery/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type RevokeUploadTaskLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewRevokeUploadTaskLogic(ctx context.Context, svcCtx *svc.ServiceContext) *RevokeUploadTaskLogic {
+	return &RevokeUploadTaskLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+func (l *RevokeUploadTaskLogic) RevokeUploadTask(req *types.SshTaskStatusRequest) (*types.TaskRevokeResponse, error) {
+	return revokeTask(l.Logger, l.svcCtx, req.TaskID)
+}
@@ -0,0 +1,134 @@
+// Code scaffolded by goctl. Safe to edit.
+// goctl 1.9.2
+
+package logic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"gocerery/internal/svc"
+	"gocerery/internal/taskstate"
+	"gocerery/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// streamCheckInterval 推送期间检查任务状态的间隔；pub/sub 不保证送达，
+// 漏掉 end 事件或 Worker 异常退出时据此结束推送
+const streamCheckInterval = 5 * time.Second
+
+type StreamSshTaskLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewStreamSshTaskLogic(ctx context.Context, svcCtx *svc.ServiceContext) *StreamSshTaskLogic {
+	return &StreamSshTaskLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+// StreamSshTask 先推送各主机的当前状态，再转发 Worker 发布的实时事件，直到任务结束或客户端断开
+func (l *StreamSshTaskLogic) StreamSshTask(req *types.SshTaskStatusRequest, client chan<- *types.TaskStreamEvent) error {
+	if req.TaskID == "" {
+		return errors.New("task_id is required")
+	}
+	if l.svcCtx.CeleryBackend == nil || l.svcCtx.TaskState == nil {
+		return errors.New("celery backend is not configured")
+	}
+
+	// 先订阅再读取当前状态，避免两者之间的事件丢失
+	events, err := l.svcCtx.TaskState.Subscribe(l.ctx, req.TaskID)
+	if err != nil {
+		return fmt.Errorf("subscribe task stream: %w", err)
+	}
+
+	finished, err := l.sendSnapshot(req.TaskID, client)
+	if err != nil || finished {
+		return err
+	}
+
+	ticker := time.NewTicker(streamCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.ctx.Done():
+			return nil
+		case ev, ok := <-events:
+			if !ok {
+				if l.ctx.Err() != nil {
+					return nil
+				}
+				return errors.New("task stream closed")
+			}
+			// taskstate.StreamEvent 与 types.TaskStreamEvent 字段一致
+			event := types.TaskStreamEvent(ev)
+			if !l.send(client, &event) || ev.Type == taskstate.EventEnd {
+				return nil
+			}
+		case <-ticker.C:
+			resultMsg, err := l.svcCtx.CeleryBackend.GetResult(req.TaskID)
+			if err == nil && taskstate.Finished(resultMsg.Status) {
+				l.send(client, &types.TaskStreamEvent{Type: taskstate.EventEnd, Status: resultMsg.Status})
+				return nil
+			}
+		}
+	}
+}
+
+// sendSnapshot 推送各主机的当前状态；任务已结束时同时推送 end 事件并返回 true
+func (l *StreamSshTaskLogic) sendSnapshot(taskID string, client chan<- *types.TaskStreamEvent) (bool, error) {
+	resultMsg, err := l.svcCtx.CeleryBackend.GetResult(taskID)
+	if err != nil {
+		// 任务尚未被 Worker 取走
+		return false, nil
+	}
+
+	if resultMsg.Result != nil {
+		resultBytes, err := json.Marshal(resultMsg.Result)
+		if err != nil {
+			return false, fmt.Errorf("marshal task result: %w", err)
+		}
+		var hostResults []types.HostResult
+		if err := json.Unmarshal(resultBytes, &hostResults); err != nil {
+			return false, fmt.Errorf("unmarshal task result: %w", err)
+		}
+		for i, item := range hostResults {
+			ev := &types.TaskStreamEvent{
+				Type:    taskstate.EventState,
+				Index:   i,
+				Name:    item.Name,
+				Host:    item.Host,
+				State:   item.State,
+				Command: item.CurrentCommand,
+				Error:   item.Error,
+			}
+			if !l.send(client, ev) {
+				return true, nil
+			}
+		}
+	}
+
+	if taskstate.Finished(resultMsg.Status) {
+		l.send(client, &types.TaskStreamEvent{Type: taskstate.EventEnd, Status: resultMsg.Status})
+		return true, nil
+	}
+	return false, nil
+}
+
+// send 客户端断开时返回 false
+func (l *StreamSshTaskLogic) send(client chan<- *types.TaskStreamEvent, ev *types.TaskStreamEvent) bool {
+	select {
+	case client <- ev:
+		return true
+	case <-l.ctx.Done():
+		return false
+	}
+}
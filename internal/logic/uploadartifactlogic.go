@@ -0,0 +1,154 @@
+// Code scaffolded by goctl. Safe to edit.
+// goctl 1.9.2
+
+package logic
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"time"
+
+	"gocerery/internal/artifact"
+	"gocerery/internal/errorx"
+	"gocerery/internal/svc"
+	"gocerery/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// artifactField multipart 表单中的文件字段名
+const artifactField = "file"
+
+type UploadArtifactLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewUploadArtifactLogic(ctx context.Context, svcCtx *svc.ServiceContext) *UploadArtifactLogic {
+	return &UploadArtifactLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+// UploadArtifact 读取 multipart 表单中的 file 字段，先写入临时文件计算大小与 sha256，再保存到 artifact 存储
+func (l *UploadArtifactLogic) UploadArtifact(r *http.Request) (*types.UploadArtifactResponse, error) {
+	store := l.svcCtx.Artifacts
+	if store == nil {
+		return nil, errors.New("artifact store is not configured")
+	}
+	maxSize := artifact.MaxSize(l.svcCtx.Config.Artifacts)
+	part, err := artifactPart(r)
+	if err != nil {
+		return nil, bodyError(err, maxSize)
+	}
+	defer part.Close()
+	name, err := artifact.CleanName(part.FileName())
+	if err != nil {
+		return nil, err
+	}
+
+	tmp, err := os.CreateTemp("", "gocerery-artifact-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), io.LimitReader(part, maxSize+1))
+	if err != nil {
+		return nil, bodyError(fmt.Errorf("read uploaded file: %w", err), maxSize)
+	}
+	if size > maxSize {
+		return nil, errorx.NewCodeError(http.StatusRequestEntityTooLarge, fmt.Sprintf("file exceeds %d MB", maxSize>>20))
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	id, err := artifact.NewID()
+	if err != nil {
+		return nil, err
+	}
+	meta := artifact.Meta{
+		ID:        id,
+		Name:      name,
+		Size:      size,
+		SHA256:    hex.EncodeToString(hash.Sum(nil)),
+		Owner:     submitterName(l.ctx),
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := store.Put(l.ctx, meta, tmp); err != nil {
+		return nil, fmt.Errorf("store artifact: %w", err)
+	}
+
+	l.Logger.Infow("artifact uploaded",
+		logx.Field("artifact_id", meta.ID),
+		logx.Field("name", meta.Name),
+		logx.Field("size", meta.Size),
+		logx.Field("owner", meta.Owner))
+	return &types.UploadArtifactResponse{
+		ArtifactID: meta.ID,
+		Name:       meta.Name,
+		Size:       meta.Size,
+		SHA256:     meta.SHA256,
+	}, nil
+}
+
+// artifactPart 跳过其他表单字段，返回 file 字段
+func artifactPart(r *http.Request) (*multipart.Part, error) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, fmt.Errorf("multipart/form-data body is required: %w", err)
+	}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, fmt.Errorf("form field %q is required", artifactField)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read multipart body: %w", err)
+		}
+		if part.FormName() == artifactField {
+			return part, nil
+		}
+		part.Close()
+	}
+}
+
+// resolveArtifact 检查上传任务引用的 artifact 是否存在；启用认证时只能使用自己上传的 artifact
+func resolveArtifact(ctx context.Context, store artifact.Store, id, principal string) (artifact.Meta, error) {
+	if store == nil {
+		return artifact.Meta{}, errors.New("artifact store is not configured")
+	}
+	meta, err := store.Stat(ctx, id)
+	if errors.Is(err, artifact.ErrNotFound) {
+		return artifact.Meta{}, fmt.Errorf("unknown artifact_id: %s", id)
+	}
+	if err != nil {
+		return artifact.Meta{}, fmt.Errorf("read artifact %s: %w", id, err)
+	}
+	if meta.Owner != "" && meta.Owner != principal {
+		return artifact.Meta{}, errorx.Forbidden("artifact %s was uploaded by another principal", id)
+	}
+	return meta, nil
+}
+
+// bodyError 请求体超过 handler 设置的上限时返回 413
+func bodyError(err error, maxSize int64) error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return errorx.NewCodeError(http.StatusRequestEntityTooLarge, fmt.Sprintf("request body exceeds %d MB", maxSize>>20))
+	}
+	return err
+}
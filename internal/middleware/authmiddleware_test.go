@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gocerery/internal/auth"
+	"gocerery/internal/config"
+	"gocerery/internal/errorx"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/zeromicro/go-zero/rest/httpx"
+)
+
+func signToken(t *testing.T, secret string, claims jwt.MapClaims) string {
+	t.Helper()
+	tok, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tok
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	httpx.SetErrorHandlerCtx(errorx.ErrorHandler)
+	m, err := NewAuthMiddleware(config.AuthConfig{
+		AccessSecret:     "current-secret",
+		PrevAccessSecret: "previous-secret",
+		APIKeys:          []config.APIKeyConfig{{Name: "ci", Key: "ci-key"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := time.Now().Add(time.Hour).Unix()
+
+	tests := []struct {
+		name     string
+		header   map[string]string
+		wantCode int
+		wantBody string
+		wantID   auth.Identity
+	}{
+		{name: "api key", header: map[string]string{apiKeyHeader: "ci-key"},
+			wantCode: http.StatusOK, wantID: auth.Identity{Name: "ci", Method: auth.MethodAPIKey}},
+		{name: "bad api key", header: map[string]string{apiKeyHeader: "nope"},
+			wantCode: http.StatusUnauthorized, wantBody: "invalid api key"},
+		{name: "no credentials",
+			wantCode: http.StatusUnauthorized, wantBody: "missing api key or bearer token"},
+		{name: "jwt", header: map[string]string{"Authorization": "Bearer " + signToken(t, "current-secret", jwt.MapClaims{"sub": "alice", "exp": exp})},
+			wantCode: http.StatusOK, wantID: auth.Identity{Name: "alice", Method: auth.MethodJWT}},
+		{name: "jwt signed with previous secret", header: map[string]string{"Authorization": "Bearer " + signToken(t, "previous-secret", jwt.MapClaims{"sub": "bob", "exp": exp})},
+			wantCode: http.StatusOK, wantID: auth.Identity{Name: "bob", Method: auth.MethodJWT}},
+		{name: "jwt with unknown secret", header: map[string]string{"Authorization": "Bearer " + signToken(t, "other", jwt.MapClaims{"sub": "eve", "exp": exp})},
+			wantCode: http.StatusUnauthorized, wantBody: "invalid auth token"},
+		{name: "expired jwt", header: map[string]string{"Authorization": "Bearer " + signToken(t, "current-secret", jwt.MapClaims{"sub": "alice", "exp": time.Now().Add(-time.Minute).Unix()})},
+			wantCode: http.StatusUnauthorized, wantBody: "invalid auth token"},
+		{name: "jwt without identity claim", header: map[string]string{"Authorization": "Bearer " + signToken(t, "current-secret", jwt.MapClaims{"exp": exp})},
+			wantCode: http.StatusUnauthorized, wantBody: "auth token has no sub claim"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const payload = `{"command":"uptime"}`
+			var got auth.Identity
+			h := m.Handle(func(w http.ResponseWriter, r *http.Request) {
+				got, _ = auth.FromContext(r.Context())
+				if body, _ := io.ReadAll(r.Body); string(body) != payload {
+					t.Errorf("handler read body %q, want %q", body, payload)
+				}
+			})
+			req := httptest.NewRequest(http.MethodPost, "/api/ssh/task", strings.NewReader(payload))
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			h(rec, req)
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d (body %q)", rec.Code, tt.wantCode, rec.Body.String())
+			}
+			if body := strings.TrimSpace(rec.Body.String()); body != tt.wantBody {
+				t.Errorf("body = %q, want %q", body, tt.wantBody)
+			}
+			if got != tt.wantID {
+				t.Errorf("identity = %+v, want %+v", got, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestAuthMiddlewareDisabled(t *testing.T) {
+	m, err := NewAuthMiddleware(config.AuthConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	called := false
+	m.Handle(func(http.ResponseWriter, *http.Request) { called = true })(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/audit", nil))
+	if m.Enabled() || !called {
+		t.Fatalf("Enabled() = %v, handler called = %v, want disabled pass-through", m.Enabled(), called)
+	}
+}
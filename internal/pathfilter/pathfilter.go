@@ -0,0 +1,142 @@
+package pathfilter
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Filter 按 gitignore 风格的 include/exclude 规则筛选目录上传中的文件，
+// 路径为相对上传目录、以 / 分隔的路径；scripts/ssh_uploader.py 中的实现与此一致
+type Filter struct {
+	include []pattern
+	exclude []pattern
+}
+
+type pattern struct {
+	segments []string // 按 / 拆分的规则，** 匹配任意层级（含零层）
+	dirOnly  bool     // 以 / 结尾的规则只匹配目录
+}
+
+// New 解析规则，两个列表都为空时返回 nil，nil 的 Filter 不过滤任何文件：
+//   - 不含 /（结尾的 / 除外）的规则匹配任意层级的文件名或目录名，如 *.swp、node_modules
+//   - 含 / 的规则从上传目录开始匹配，开头的 / 可省略，如 /build、docs/*.md
+//   - 以 / 结尾的规则只匹配目录，如 cache/
+//   - * ? [...] 不跨越 /，[!...] 与 [^...] 均表示取反，不支持 ! 开头的否定规则与 \ 转义
+func New(include, exclude []string) (*Filter, error) {
+	if len(include) == 0 && len(exclude) == 0 {
+		return nil, nil
+	}
+	f := &Filter{}
+	var err error
+	if f.include, err = parsePatterns("include", include); err != nil {
+		return nil, err
+	}
+	if f.exclude, err = parsePatterns("exclude", exclude); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func parsePatterns(field string, raw []string) ([]pattern, error) {
+	patterns := make([]pattern, 0, len(raw))
+	for idx, s := range raw {
+		p, err := parsePattern(s)
+		if err != nil {
+			return nil, fmt.Errorf("%s[%d]: %w", field, idx, err)
+		}
+		patterns = append(patterns, p)
+	}
+	return patterns, nil
+}
+
+func parsePattern(s string) (pattern, error) {
+	switch {
+	case strings.TrimSpace(s) == "":
+		return pattern{}, errors.New("pattern cannot be empty")
+	case strings.HasPrefix(s, "!"):
+		return pattern{}, fmt.Errorf("negated patterns are not supported, use include instead: %s", s)
+	case strings.Contains(s, "\\"):
+		return pattern{}, fmt.Errorf("backslash is not supported in patterns: %s", s)
+	}
+	p := pattern{dirOnly: strings.HasSuffix(s, "/")}
+	body := strings.Trim(s, "/")
+	anchored := strings.HasPrefix(s, "/") || strings.Contains(body, "/")
+	if body == "" {
+		return pattern{}, fmt.Errorf("invalid pattern: %s", s)
+	}
+	if !anchored {
+		p.segments = append(p.segments, "**")
+	}
+	for _, seg := range strings.Split(body, "/") {
+		switch seg {
+		case "", ".", "..":
+			return pattern{}, fmt.Errorf("invalid pattern: %s", s)
+		case "**":
+		default:
+			seg = strings.ReplaceAll(seg, "[!", "[^")
+			if _, err := path.Match(seg, ""); err != nil {
+				return pattern{}, fmt.Errorf("invalid pattern %q: %w", s, err)
+			}
+		}
+		p.segments = append(p.segments, seg)
+	}
+	return p, nil
+}
+
+// Skip 判断相对路径 rel 是否被过滤掉：命中 exclude 的文件或目录被跳过（目录不再展开）；
+// 配置了 include 时，文件本身或所在的某一级目录需命中 include，目录总会被展开
+func (f *Filter) Skip(rel string, dir bool) bool {
+	if f == nil {
+		return false
+	}
+	segments := strings.Split(rel, "/")
+	if matchAny(f.exclude, segments, dir) {
+		return true
+	}
+	if dir || len(f.include) == 0 {
+		return false
+	}
+	for i := len(segments); i > 0; i-- {
+		if matchAny(f.include, segments[:i], i < len(segments)) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchAny(patterns []pattern, segments []string, dir bool) bool {
+	for _, p := range patterns {
+		if (dir || !p.dirOnly) && matchSegments(p.segments, segments) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchSegments 逐段匹配；结尾的 ** 至少匹配一层，与 gitignore 中 foo/** 只匹配 foo 下的内容一致
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			pattern = pattern[1:]
+			if len(pattern) == 0 {
+				return len(name) > 0
+			}
+			for i := range name {
+				if matchSegments(pattern, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
@@ -0,0 +1,163 @@
+package pathfilter
+
+import (
+	"bytes"
+	"encoding/json"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+type skipCase struct {
+	Include []string `json:"include"`
+	Exclude []string `json:"exclude"`
+	Path    string   `json:"path"`
+	Dir     bool     `json:"dir"`
+	Skip    bool     `json:"skip"`
+}
+
+// skipCases 同时用于 Go 与 scripts/ssh_uploader.py 的匹配实现
+var skipCases = []skipCase{
+	// 不含 / 的规则匹配任意层级
+	{Exclude: []string{"build"}, Path: "build", Dir: true, Skip: true},
+	{Exclude: []string{"build"}, Path: "src/build", Dir: true, Skip: true},
+	{Exclude: []string{"build"}, Path: "src/build", Skip: true},
+	{Exclude: []string{"build"}, Path: "src/builder", Skip: false},
+	{Exclude: []string{"*.swp"}, Path: "a/b/.main.go.swp", Skip: true},
+	{Exclude: []string{"*.swp"}, Path: "a.swpx", Skip: false},
+	// 开头的 / 或中间的 / 从上传目录开始匹配
+	{Exclude: []string{"/build"}, Path: "build", Dir: true, Skip: true},
+	{Exclude: []string{"/build"}, Path: "src/build", Dir: true, Skip: false},
+	{Exclude: []string{"docs/*.md"}, Path: "docs/a.md", Skip: true},
+	{Exclude: []string{"docs/*.md"}, Path: "x/docs/a.md", Skip: false},
+	{Exclude: []string{"docs/*.md"}, Path: "docs/sub/a.md", Skip: false},
+	// **
+	{Exclude: []string{"docs/**/*.png"}, Path: "docs/a.png", Skip: true},
+	{Exclude: []string{"docs/**/*.png"}, Path: "docs/x/y/a.png", Skip: true},
+	{Exclude: []string{"docs/**/*.png"}, Path: "a.png", Skip: false},
+	{Exclude: []string{"logs/**"}, Path: "logs", Dir: true, Skip: false},
+	{Exclude: []string{"logs/**"}, Path: "logs/app/a.log", Skip: true},
+	{Exclude: []string{"**/tmp"}, Path: "tmp", Dir: true, Skip: true},
+	{Exclude: []string{"**/tmp"}, Path: "a/b/tmp", Dir: true, Skip: true},
+	// 结尾的 / 只匹配目录
+	{Exclude: []string{"cache/"}, Path: "cache", Dir: true, Skip: true},
+	{Exclude: []string{"cache/"}, Path: "a/cache", Dir: true, Skip: true},
+	{Exclude: []string{"cache/"}, Path: "cache", Skip: false},
+	{Exclude: []string{"/out/"}, Path: "out", Skip: false},
+	// * ? [...] 不跨越 /，[!...] 与 [^...] 均表示取反
+	{Exclude: []string{"*.[!m]*"}, Path: "main.go", Skip: true},
+	{Exclude: []string{"*.[!m]*"}, Path: "README.md", Skip: false},
+	{Exclude: []string{"*.[^m]*"}, Path: "main.go", Skip: true},
+	{Exclude: []string{"*.[^m]*"}, Path: "README.md", Skip: false},
+	{Exclude: []string{"file[0-9].txt"}, Path: "x/file7.txt", Skip: true},
+	{Exclude: []string{"file[0-9].txt"}, Path: "filea.txt", Skip: false},
+	{Exclude: []string{"a?.log"}, Path: "ab.log", Skip: true},
+	{Exclude: []string{"a?.log"}, Path: "abc.log", Skip: false},
+	{Exclude: []string{"src/*"}, Path: "src/a", Dir: true, Skip: true},
+	{Exclude: []string{"src/*.go"}, Path: "src/a/b.go", Skip: false},
+	// include：文件本身或所在的某一级目录命中即可，目录总会被展开
+	{Include: []string{"*.md"}, Path: "docs/a.md", Skip: false},
+	{Include: []string{"*.md"}, Path: "main.go", Skip: true},
+	{Include: []string{"*.md"}, Path: "src", Dir: true, Skip: false},
+	{Include: []string{"docs/"}, Path: "docs/sub/a.go", Skip: false},
+	{Include: []string{"docs/"}, Path: "docs", Skip: true},
+	{Include: []string{"**/api/*"}, Path: "svc/api/x.go", Skip: false},
+	{Include: []string{"**/api/*"}, Path: "api/v1/x.go", Skip: false},
+	{Include: []string{"**/api/*"}, Path: "api.go", Skip: true},
+	// exclude 优先于 include
+	{Include: []string{"*.md"}, Exclude: []string{"README.md"}, Path: "README.md", Skip: true},
+	{Include: []string{"*.md"}, Exclude: []string{"README.md"}, Path: "docs/guide.md", Skip: false},
+	{Include: []string{"docs/"}, Exclude: []string{"*.png"}, Path: "docs/a.png", Skip: true},
+	{Include: []string{"docs/"}, Exclude: []string{"*.png"}, Path: "docs/a.md", Skip: false},
+	{Include: []string{"docs/"}, Exclude: []string{"*.png"}, Path: "src/a.md", Skip: true},
+	{Include: []string{"app", "/main.go"}, Exclude: []string{"app/build/**"}, Path: "app/build/bin/app", Skip: true},
+	{Include: []string{"app", "/main.go"}, Exclude: []string{"app/build/**"}, Path: "app/cmd/main.go", Skip: false},
+	{Include: []string{"app", "/main.go"}, Exclude: []string{"app/build/**"}, Path: "cmd/main.go", Skip: true},
+	{Include: []string{"*.go"}, Exclude: []string{"vendor/"}, Path: "vendor", Dir: true, Skip: true},
+}
+
+func TestSkip(t *testing.T) {
+	for _, tt := range skipCases {
+		f, err := New(tt.Include, tt.Exclude)
+		if err != nil {
+			t.Fatalf("New(%q, %q) error = %v", tt.Include, tt.Exclude, err)
+		}
+		if got := f.Skip(tt.Path, tt.Dir); got != tt.Skip {
+			t.Errorf("include=%q exclude=%q Skip(%q, dir=%v) = %v, want %v",
+				tt.Include, tt.Exclude, tt.Path, tt.Dir, got, tt.Skip)
+		}
+	}
+}
+
+func TestNewEmpty(t *testing.T) {
+	f, err := New(nil, nil)
+	if err != nil || f != nil {
+		t.Fatalf("New(nil, nil) = %v, %v, want nil filter", f, err)
+	}
+	if f.Skip("a/b.go", false) {
+		t.Fatal("nil filter skipped a file")
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	for _, p := range []string{"", "  ", "/", "!*.go", `a\b`, "a//b", "a/../b", "./a", "[a"} {
+		if _, err := New(nil, []string{p}); err == nil {
+			t.Errorf("New(exclude=%q) succeeded, want error", p)
+		}
+		if _, err := New([]string{p}, nil); err == nil {
+			t.Errorf("New(include=%q) succeeded, want error", p)
+		}
+	}
+}
+
+// pythonMatcher 以 skipCases 调用 ssh_uploader.py 的 parse_pattern/filtered_out；
+// 未安装 paramiko 时以空模块代替，只用到匹配函数
+const pythonMatcher = `
+import json, sys, types
+try:
+    import paramiko
+except ImportError:
+    stub = types.ModuleType("paramiko")
+    stub.__getattr__ = lambda name: type(name, (Exception,), {})
+    sys.modules["paramiko"] = stub
+import ssh_uploader as u
+out = []
+for c in json.load(sys.stdin):
+    u.include_patterns = [u.parse_pattern(p) for p in c["include"] or []]
+    u.exclude_patterns = [u.parse_pattern(p) for p in c["exclude"] or []]
+    out.append(u.filtered_out(c["path"], c["dir"]))
+json.dump(out, sys.stdout)
+`
+
+func TestPythonMatcherParity(t *testing.T) {
+	python, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("python3 not found")
+	}
+	input, err := json.Marshal(skipCases)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(python, "-B", "-c", pythonMatcher)
+	cmd.Dir = filepath.Join("..", "..", "scripts")
+	cmd.Stdin = bytes.NewReader(input)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("run python matcher: %v\n%s", err, stderr.String())
+	}
+	var got []bool
+	if err := json.Unmarshal(output, &got); err != nil {
+		t.Fatalf("decode python output %q: %v", output, err)
+	}
+	if len(got) != len(skipCases) {
+		t.Fatalf("python returned %d results, want %d", len(got), len(skipCases))
+	}
+	for i, tt := range skipCases {
+		if got[i] != tt.Skip {
+			t.Errorf("python: include=%q exclude=%q filtered_out(%q, dir=%v) = %v, want %v",
+				tt.Include, tt.Exclude, tt.Path, tt.Dir, got[i], tt.Skip)
+		}
+	}
+}
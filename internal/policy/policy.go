@@ -0,0 +1,297 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gocerery/internal/config"
+	"gocerery/internal/download"
+	"gocerery/internal/errorx"
+	"gocerery/internal/inventory"
+)
+
+// anyPrincipal 绑定到所有已认证调用方的 Principal
+const anyPrincipal = "*"
+
+// commandWildcard 命令模板中 * 可匹配的字符：不含空白、引号与 shell 元字符，
+// 防止 "systemctl restart app-*" 匹配到 "systemctl restart app-x; rm -rf /"
+const commandWildcard = "[^\\s;&|<>()$`\\\\'\"{}*?\\[\\]!#~]*"
+
+// Engine 按角色判断调用方能否在目标主机上执行命令、上传或下载文件；未配置 Roles 时不做检查
+type Engine struct {
+	roles    map[string]*role
+	bindings map[string][]*role
+	inv      *inventory.Inventory
+}
+
+type role struct {
+	name          string
+	anyCommand    bool
+	commands      []*regexp.Regexp
+	groups        []string
+	targets       []string
+	hosts         []string
+	uploadPaths   []string
+	localPaths    []string
+	downloadPaths []string
+}
+
+// Target 待授权的目标主机
+type Target struct {
+	Name string
+	Host string
+	Port int
+	User string
+}
+
+// Request 待授权的任务；Upload 为 true 时检查 LocalPath/RemotePath，Download 为 true 时检查 RemotePaths，
+// 否则检查 Commands。上传内容来自 ArtifactID 时不读取 Worker 本地文件，不检查 LocalPaths
+type Request struct {
+	Principal   string
+	Targets     []Target
+	Commands    []string
+	Upload      bool
+	LocalPath   string
+	ArtifactID  string
+	RemotePath  string
+	Download    bool
+	RemotePaths []string
+}
+
+// New 校验并编译策略：角色名称唯一、绑定引用的角色必须存在、模板格式合法
+func New(c config.PolicyConfig, inv *inventory.Inventory) (*Engine, error) {
+	e := &Engine{
+		roles:    make(map[string]*role, len(c.Roles)),
+		bindings: make(map[string][]*role, len(c.Bindings)),
+		inv:      inv,
+	}
+	for idx, rc := range c.Roles {
+		if rc.Name == "" {
+			return nil, fmt.Errorf("Policy.Roles[%d] name is required", idx)
+		}
+		if _, ok := e.roles[rc.Name]; ok {
+			return nil, fmt.Errorf("duplicate role name in Policy.Roles: %s", rc.Name)
+		}
+		r, err := compileRole(rc)
+		if err != nil {
+			return nil, fmt.Errorf("Policy.Roles[%d] (%s): %w", idx, rc.Name, err)
+		}
+		e.roles[rc.Name] = r
+	}
+	for idx, b := range c.Bindings {
+		if b.Principal == "" {
+			return nil, fmt.Errorf("Policy.Bindings[%d] principal is required", idx)
+		}
+		for _, name := range b.Roles {
+			r, ok := e.roles[name]
+			if !ok {
+				return nil, fmt.Errorf("Policy.Bindings[%d] (%s) references unknown role: %s", idx, b.Principal, name)
+			}
+			e.bindings[b.Principal] = append(e.bindings[b.Principal], r)
+		}
+	}
+	return e, nil
+}
+
+func compileRole(rc config.RoleConfig) (*role, error) {
+	r := &role{
+		name:          rc.Name,
+		groups:        rc.Groups,
+		targets:       rc.Targets,
+		hosts:         rc.Hosts,
+		uploadPaths:   cleanPaths(rc.UploadPaths),
+		localPaths:    cleanPaths(rc.LocalPaths),
+		downloadPaths: cleanPaths(rc.DownloadPaths),
+	}
+	for _, pattern := range slices.Concat(rc.Targets, rc.Hosts, rc.Groups) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	for _, tmpl := range rc.Commands {
+		tmpl = strings.TrimSpace(tmpl)
+		if tmpl == "*" {
+			r.anyCommand = true
+			continue
+		}
+		if tmpl == "" {
+			return nil, errors.New("empty command template")
+		}
+		r.commands = append(r.commands, compileCommand(tmpl))
+	}
+	return r, nil
+}
+
+// compileCommand 把命令模板转为完整匹配的正则，模板中除 * 外均按字面匹配
+func compileCommand(tmpl string) *regexp.Regexp {
+	parts := strings.Split(tmpl, "*")
+	for i := range parts {
+		parts[i] = regexp.QuoteMeta(parts[i])
+	}
+	return regexp.MustCompile("^" + strings.Join(parts, commandWildcard) + "$")
+}
+
+func cleanPaths(paths []string) []string {
+	cleaned := make([]string, 0, len(paths))
+	for _, p := range paths {
+		cleaned = append(cleaned, path.Clean(p))
+	}
+	return cleaned
+}
+
+// Enabled 是否配置了任一角色；nil 安全
+func (e *Engine) Enabled() bool {
+	return e != nil && len(e.roles) > 0
+}
+
+// Authorize 每台目标主机都需要有角色允许，且该主机上的每条命令（或上传路径、下载路径）
+// 都需要被允许该主机的某个角色允许；拒绝时返回 403 错误并说明原因
+func (e *Engine) Authorize(req Request) error {
+	if !e.Enabled() {
+		return nil
+	}
+	roles := e.rolesOf(req.Principal)
+	if len(roles) == 0 {
+		return errorx.Forbidden("principal %q has no role", req.Principal)
+	}
+
+	for _, t := range req.Targets {
+		var allowed []*role
+		for _, r := range roles {
+			if r.allowsTarget(e.inv, t) {
+				allowed = append(allowed, r)
+			}
+		}
+		if len(allowed) == 0 {
+			return errorx.Forbidden("principal %q is not allowed to access target %s (%s)", req.Principal, targetName(t), t.Host)
+		}
+
+		if req.Upload {
+			if !anyRole(allowed, func(r *role) bool { return r.allowsUpload(req.LocalPath, req.ArtifactID != "", req.RemotePath) }) {
+				source := req.LocalPath
+				if req.ArtifactID != "" {
+					source = "artifact " + req.ArtifactID
+				}
+				return errorx.Forbidden("principal %q is not allowed to upload %s to %s on target %s", req.Principal, source, req.RemotePath, targetName(t))
+			}
+			continue
+		}
+		if req.Download {
+			for _, p := range req.RemotePaths {
+				if !anyRole(allowed, func(r *role) bool { return r.allowsDownload(p) }) {
+					return errorx.Forbidden("principal %q is not allowed to download %s from target %s", req.Principal, p, targetName(t))
+				}
+			}
+			continue
+		}
+		for _, command := range req.Commands {
+			if !anyRole(allowed, func(r *role) bool { return r.allowsCommand(command) }) {
+				return errorx.Forbidden("principal %q is not allowed to run %q on target %s", req.Principal, command, targetName(t))
+			}
+		}
+	}
+	return nil
+}
+
+func (e *Engine) rolesOf(principal string) []*role {
+	if principal == "" {
+		return nil
+	}
+	return slices.Concat(e.bindings[principal], e.bindings[anyPrincipal])
+}
+
+func anyRole(roles []*role, fn func(r *role) bool) bool {
+	for _, r := range roles {
+		if fn(r) {
+			return true
+		}
+	}
+	return false
+}
+
+func targetName(t Target) string {
+	if t.Name != "" {
+		return t.Name
+	}
+	return t.Host
+}
+
+// allowsTarget 清单中的主机按名称或分组授权；请求直接给出的主机只能按地址授权，
+// 避免调用方借用清单主机的名称访问其他地址
+func (r *role) allowsTarget(inv *inventory.Inventory, t Target) bool {
+	if matchAny(r.hosts, t.Host) {
+		return true
+	}
+	host, ok := inv.Lookup(t.Name)
+	if !ok || host.Host != t.Host || host.User != t.User || normalizePort(host.Port) != normalizePort(t.Port) {
+		return false
+	}
+	if matchAny(r.targets, host.Name) || slices.Contains(r.groups, "*") {
+		return true
+	}
+	for _, group := range host.Groups {
+		if matchAny(r.groups, group) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *role) allowsCommand(command string) bool {
+	if r.anyCommand {
+		return true
+	}
+	command = strings.TrimSpace(command)
+	for _, re := range r.commands {
+		if re.MatchString(command) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *role) allowsUpload(localPath string, artifact bool, remotePath string) bool {
+	if !underAny(r.uploadPaths, remotePath) {
+		return false
+	}
+	return artifact || len(r.localPaths) == 0 || underAny(r.localPaths, localPath)
+}
+
+// allowsDownload 按通配符之前的目录检查，匹配结果都位于该目录下
+func (r *role) allowsDownload(pattern string) bool {
+	return underAny(r.downloadPaths, download.StaticPrefix(pattern))
+}
+
+func matchAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, value); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// underAny 判断 p 是否为某个目录本身或其子路径；p 先经过 Clean，防止 .. 越界
+func underAny(dirs []string, p string) bool {
+	if p == "" {
+		return false
+	}
+	p = path.Clean(p)
+	for _, dir := range dirs {
+		if p == dir || dir == "/" || strings.HasPrefix(p, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizePort(port int) int {
+	if port <= 0 {
+		return 22
+	}
+	return port
+}
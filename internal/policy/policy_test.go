@@ -0,0 +1,145 @@
+package policy
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"gocerery/internal/config"
+	"gocerery/internal/errorx"
+	"gocerery/internal/inventory"
+)
+
+func newEngine(t *testing.T) *Engine {
+	t.Helper()
+	inv, err := inventory.New([]config.TargetConfig{
+		{Name: "web-1", Host: "10.0.0.31", User: "deploy", Groups: []string{"web"}},
+		{Name: "web-2", Host: "10.0.0.32", Port: 2222, User: "deploy", Groups: []string{"web"}},
+		{Name: "db-1", Host: "10.0.0.21", User: "dba", Groups: []string{"db"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := New(config.PolicyConfig{
+		Roles: []config.RoleConfig{
+			{Name: "web-ops", Groups: []string{"web"}, Commands: []string{"systemctl restart app-*", "uptime"},
+				UploadPaths: []string{"/opt/app"}, LocalPaths: []string{"/srv/releases"}, DownloadPaths: []string{"/var/log/app"}},
+			{Name: "lab", Hosts: []string{"192.168.8.*"}, Commands: []string{"*"}, UploadPaths: []string{"/"}},
+			{Name: "readonly", Groups: []string{"*"}, Commands: []string{"uptime"}},
+		},
+		Bindings: []config.BindingConfig{
+			{Principal: "alice", Roles: []string{"web-ops", "lab"}},
+			{Principal: "*", Roles: []string{"readonly"}},
+		},
+	}, inv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+var (
+	web1 = Target{Name: "web-1", Host: "10.0.0.31", Port: 22, User: "deploy"}
+	web2 = Target{Name: "web-2", Host: "10.0.0.32", Port: 2222, User: "deploy"}
+	db1  = Target{Name: "db-1", Host: "10.0.0.21", User: "dba"}
+	lab  = Target{Host: "192.168.8.10", Port: 22, User: "root"}
+)
+
+func TestAuthorize(t *testing.T) {
+	e := newEngine(t)
+	tests := []struct {
+		name  string
+		req   Request
+		allow bool
+	}{
+		{name: "command template", allow: true,
+			req: Request{Principal: "alice", Targets: []Target{web1, web2}, Commands: []string{"systemctl restart app-api", " uptime "}}},
+		{name: "wildcard does not cross shell metacharacters",
+			req: Request{Principal: "alice", Targets: []Target{web1}, Commands: []string{"systemctl restart app-x; rm -rf /"}}},
+		{name: "wildcard does not match spaces",
+			req: Request{Principal: "alice", Targets: []Target{web1}, Commands: []string{"systemctl restart app-x other"}}},
+		{name: "command outside role",
+			req: Request{Principal: "alice", Targets: []Target{web1}, Commands: []string{"reboot"}}},
+		{name: "host outside groups",
+			req: Request{Principal: "alice", Targets: []Target{web1, db1}, Commands: []string{"systemctl restart app-api"}}},
+		{name: "binding for all principals", allow: true,
+			req: Request{Principal: "bob", Targets: []Target{db1}, Commands: []string{"uptime"}}},
+		{name: "no principal",
+			req: Request{Targets: []Target{db1}, Commands: []string{"uptime"}}},
+		{name: "inventory name with another address",
+			req: Request{Principal: "alice", Targets: []Target{{Name: "web-1", Host: "203.0.113.5", User: "deploy"}}, Commands: []string{"uptime"}}},
+		{name: "inventory name with another user",
+			req: Request{Principal: "alice", Targets: []Target{{Name: "web-1", Host: "10.0.0.31", User: "root"}}, Commands: []string{"uptime"}}},
+		{name: "inventory name with another port",
+			req: Request{Principal: "alice", Targets: []Target{{Name: "web-2", Host: "10.0.0.32", Port: 22, User: "deploy"}}, Commands: []string{"uptime"}}},
+		{name: "ad-hoc host by address", allow: true,
+			req: Request{Principal: "alice", Targets: []Target{lab}, Commands: []string{"rm -rf /tmp/x"}}},
+		{name: "roles combine per host", allow: true,
+			req: Request{Principal: "alice", Targets: []Target{web1, lab}, Commands: []string{"uptime"}}},
+		{name: "command allowed only on other host",
+			req: Request{Principal: "alice", Targets: []Target{web1, lab}, Commands: []string{"id"}}},
+		{name: "upload", allow: true,
+			req: Request{Principal: "alice", Targets: []Target{web1}, Upload: true, LocalPath: "/srv/releases/v1.tar.gz", RemotePath: "/opt/app/releases"}},
+		{name: "upload local path escapes",
+			req: Request{Principal: "alice", Targets: []Target{web1}, Upload: true, LocalPath: "/srv/releases/../../etc/shadow", RemotePath: "/opt/app"}},
+		{name: "upload remote path escapes",
+			req: Request{Principal: "alice", Targets: []Target{web1}, Upload: true, LocalPath: "/srv/releases/a", RemotePath: "/opt/app/../../etc"}},
+		{name: "upload remote prefix is not a directory boundary",
+			req: Request{Principal: "alice", Targets: []Target{web1}, Upload: true, LocalPath: "/srv/releases/a", RemotePath: "/opt/application"}},
+		{name: "upload artifact skips local paths", allow: true,
+			req: Request{Principal: "alice", Targets: []Target{web1}, Upload: true, ArtifactID: "0123456789abcdef0123456789abcdef", RemotePath: "/opt/app"}},
+		{name: "upload without upload paths",
+			req: Request{Principal: "bob", Targets: []Target{db1}, Upload: true, LocalPath: "/tmp/a", RemotePath: "/tmp"}},
+		{name: "download", allow: true,
+			req: Request{Principal: "alice", Targets: []Target{web1}, Download: true, RemotePaths: []string{"/var/log/app/*.log", "/var/log/app"}}},
+		{name: "download glob in directory",
+			req: Request{Principal: "alice", Targets: []Target{web1}, Download: true, RemotePaths: []string{"/var/log/*/secret"}}},
+		{name: "download escapes",
+			req: Request{Principal: "alice", Targets: []Target{web1}, Download: true, RemotePaths: []string{"/var/log/app/../../../etc/shadow"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := e.Authorize(tt.req)
+			if tt.allow {
+				if err != nil {
+					t.Fatalf("Authorize error = %v, want allowed", err)
+				}
+				return
+			}
+			var codeErr *errorx.CodeError
+			if !errors.As(err, &codeErr) || codeErr.Code != http.StatusForbidden {
+				t.Fatalf("Authorize error = %v, want 403", err)
+			}
+		})
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	var nilEngine *Engine
+	if nilEngine.Enabled() || nilEngine.Authorize(Request{Commands: []string{"reboot"}}) != nil {
+		t.Fatal("nil Engine should allow everything")
+	}
+	e, err := New(config.PolicyConfig{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Enabled() || e.Authorize(Request{Commands: []string{"reboot"}}) != nil {
+		t.Fatal("Engine without roles should allow everything")
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	tests := []config.PolicyConfig{
+		{Roles: []config.RoleConfig{{Commands: []string{"*"}}}},
+		{Roles: []config.RoleConfig{{Name: "a"}, {Name: "a"}}},
+		{Roles: []config.RoleConfig{{Name: "a", Commands: []string{" "}}}},
+		{Roles: []config.RoleConfig{{Name: "a", Hosts: []string{"[10.0"}}}},
+		{Roles: []config.RoleConfig{{Name: "a"}}, Bindings: []config.BindingConfig{{Roles: []string{"a"}}}},
+		{Roles: []config.RoleConfig{{Name: "a"}}, Bindings: []config.BindingConfig{{Principal: "p", Roles: []string{"b"}}}},
+	}
+	for idx, c := range tests {
+		if _, err := New(c, nil); err == nil {
+			t.Errorf("case %d: New succeeded, want error", idx)
+		}
+	}
+}
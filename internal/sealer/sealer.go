@@ -0,0 +1,291 @@
+package sealer
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gocerery/internal/config"
+)
+
+// 消息中的字段名
+const (
+	envelopeField = "sealed"      // {"kid": 密钥 ID, "dek": 被主密钥加密的数据密钥}
+	authField     = "sealed_auth" // 单个端点加密后的凭据
+)
+
+// credentialFields 需要加密的凭据字段；key_ref 只是 Worker 本地文件名，保持明文
+var credentialFields = []string{"password", "private_key", "private_key_passphrase"}
+
+// boundFields 保持明文但绑定到密文上的端点字段：改动指纹可让 Worker 信任伪造的主机，
+// 改动引用可替换 Worker 解析出的凭据
+var boundFields = []string{"host_key_fingerprint", "password_ref", "key_ref"}
+
+// endpointLists 消息中包含凭据的端点列表
+var endpointLists = []string{"jump_hosts", "targets"}
+
+// Sealer 对 Celery 消息中的凭据做信封加密：每条消息生成随机数据密钥加密各端点的凭据，
+// 数据密钥再用主密钥加密，与密钥 ID 一起放入消息；均使用 AES-256-GCM。
+// 每个端点都带密文（没有凭据时为空），以 列表名/下标/host/port/user 及 boundFields 作为附加数据，
+// 篡改地址、账号、主机密钥指纹或凭据引用后无法解密
+type Sealer struct {
+	activeID string
+	keys     map[string]cipher.AEAD
+	require  bool
+}
+
+// New 加载主密钥；未配置密钥时返回 nil，Seal 与 Open 为空操作
+func New(c config.CredentialEncryptionConfig) (*Sealer, error) {
+	if len(c.Keys) == 0 {
+		if c.ActiveKeyID != "" || c.RequireEncrypted {
+			return nil, errors.New("CredentialEncryption.Keys is required")
+		}
+		return nil, nil
+	}
+	s := &Sealer{activeID: c.ActiveKeyID, keys: make(map[string]cipher.AEAD, len(c.Keys)), require: c.RequireEncrypted}
+	for idx, kc := range c.Keys {
+		if kc.ID == "" {
+			return nil, fmt.Errorf("CredentialEncryption.Keys[%d] id is required", idx)
+		}
+		if _, ok := s.keys[kc.ID]; ok {
+			return nil, fmt.Errorf("duplicate key id in CredentialEncryption.Keys: %s", kc.ID)
+		}
+		key, err := loadKey(kc)
+		if err != nil {
+			return nil, fmt.Errorf("CredentialEncryption.Keys[%d] (%s): %w", idx, kc.ID, err)
+		}
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+		s.keys[kc.ID] = aead
+	}
+	if s.activeID != "" {
+		if _, ok := s.keys[s.activeID]; !ok {
+			return nil, fmt.Errorf("CredentialEncryption.ActiveKeyID %s is not in Keys", s.activeID)
+		}
+	}
+	return s, nil
+}
+
+// loadKey 从环境变量或文件读取 base64 编码的 32 字节密钥
+func loadKey(kc config.CredentialKeyConfig) ([]byte, error) {
+	var encoded string
+	switch {
+	case kc.KeyEnv != "" && kc.KeyFile != "":
+		return nil, errors.New("KeyEnv and KeyFile cannot be used together")
+	case kc.KeyEnv != "":
+		encoded = os.Getenv(kc.KeyEnv)
+		if encoded == "" {
+			return nil, fmt.Errorf("environment variable %s is empty", kc.KeyEnv)
+		}
+	case kc.KeyFile != "":
+		data, err := os.ReadFile(kc.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("read key file: %w", err)
+		}
+		encoded = string(data)
+	default:
+		return nil, errors.New("KeyEnv or KeyFile is required")
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("decode key: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("key must be 32 bytes, got %d", len(key))
+	}
+	return key, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Seal 加密消息中 jump_hosts/targets 的凭据字段（API 提交前调用）；未配置 ActiveKeyID 时不修改消息
+func (s *Sealer) Seal(payload map[string]interface{}) error {
+	if s == nil || s.activeID == "" {
+		return nil
+	}
+	dek := make([]byte, 32)
+	if _, err := rand.Read(dek); err != nil {
+		return err
+	}
+	dekAEAD, err := newAEAD(dek)
+	if err != nil {
+		return err
+	}
+	wrapped, err := seal(s.keys[s.activeID], dek, []byte(dekAAD(s.activeID)))
+	if err != nil {
+		return err
+	}
+
+	for _, list := range endpointLists {
+		endpoints, _ := payload[list].([]map[string]interface{})
+		for idx, ep := range endpoints {
+			creds := make(map[string]string, len(credentialFields))
+			for _, field := range credentialFields {
+				if v, _ := ep[field].(string); v != "" {
+					creds[field] = v
+				}
+				delete(ep, field)
+			}
+			plain, err := json.Marshal(creds)
+			if err != nil {
+				return err
+			}
+			sealed, err := seal(dekAEAD, plain, []byte(endpointAAD(list, idx, ep)))
+			if err != nil {
+				return err
+			}
+			ep[authField] = sealed
+		}
+	}
+	payload[envelopeField] = map[string]interface{}{"kid": s.activeID, "dek": wrapped}
+	return nil
+}
+
+// Open 解密消息中的凭据并还原为明文字段（Worker 解析消息前调用）。
+// 密钥 ID 未知、密文或端点地址被篡改、加密消息中混入明文凭据时返回错误；
+// 配置 RequireEncrypted 时拒绝未加密但带凭据的消息
+func (s *Sealer) Open(payload map[string]interface{}) error {
+	raw, sealed := payload[envelopeField]
+	if !sealed {
+		if s != nil && s.require && hasUnsealedFields(payload) {
+			return errors.New("message carries unencrypted credentials or credential settings")
+		}
+		return nil
+	}
+	if s == nil {
+		return errors.New("message credentials are encrypted but no CredentialEncryption.Keys are configured")
+	}
+
+	envelope, ok := raw.(map[string]interface{})
+	if !ok {
+		return errors.New("invalid credential envelope")
+	}
+	kid, _ := envelope["kid"].(string)
+	wrapped, _ := envelope["dek"].(string)
+	kek, ok := s.keys[kid]
+	if !ok {
+		return fmt.Errorf("unknown credential key id: %s", kid)
+	}
+	dek, err := open(kek, wrapped, []byte(dekAAD(kid)))
+	if err != nil {
+		return errors.New("credential envelope has been tampered with or uses a wrong key")
+	}
+	dekAEAD, err := newAEAD(dek)
+	if err != nil {
+		return errors.New("credential envelope has an invalid data key")
+	}
+
+	for _, list := range endpointLists {
+		endpoints, _ := payload[list].([]interface{})
+		for idx, item := range endpoints {
+			ep, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			for _, field := range credentialFields {
+				if v, _ := ep[field].(string); v != "" {
+					return fmt.Errorf("%s[%d] carries unencrypted %s in an encrypted message", list, idx, field)
+				}
+			}
+			ciphertext, _ := ep[authField].(string)
+			if ciphertext == "" {
+				return fmt.Errorf("%s[%d] is not sealed in an encrypted message", list, idx)
+			}
+			plain, err := open(dekAEAD, ciphertext, []byte(endpointAAD(list, idx, ep)))
+			if err != nil {
+				return fmt.Errorf("%s[%d] credentials have been tampered with", list, idx)
+			}
+			var creds map[string]string
+			if err := json.Unmarshal(plain, &creds); err != nil {
+				return fmt.Errorf("%s[%d] credentials: %w", list, idx, err)
+			}
+			delete(ep, authField)
+			for field, v := range creds {
+				ep[field] = v
+			}
+		}
+	}
+	delete(payload, envelopeField)
+	return nil
+}
+
+// hasUnsealedFields 未加密的消息中是否有凭据或 boundFields；
+// 后者只有随密文一起才能防篡改，RequireEncrypted 时同样拒绝
+func hasUnsealedFields(payload map[string]interface{}) bool {
+	for _, list := range endpointLists {
+		endpoints, _ := payload[list].([]interface{})
+		for _, item := range endpoints {
+			ep, _ := item.(map[string]interface{})
+			for _, field := range slices.Concat(credentialFields, boundFields) {
+				if v, _ := ep[field].(string); v != "" {
+					return true
+				}
+			}
+		}
+	}
+	for _, field := range credentialFields {
+		if v, _ := payload["proxy_"+field].(string); v != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func dekAAD(kid string) string {
+	return "gocerery-dek|" + kid
+}
+
+// endpointAAD 把端点在消息中的位置、host/port/user 与 boundFields 绑定到密文上
+func endpointAAD(list string, idx int, ep map[string]interface{}) string {
+	aad := fmt.Sprintf("%s|%d|%v|%s|%v", list, idx, ep["host"], portString(ep["port"]), ep["user"])
+	for _, field := range boundFields {
+		v, _ := ep[field].(string)
+		aad += "|" + strconv.Quote(v)
+	}
+	return aad
+}
+
+// portString API 侧为 int，经 JSON 传到 Worker 后为 float64，统一格式化
+func portString(v interface{}) string {
+	switch val := v.(type) {
+	case int:
+		return strconv.Itoa(val)
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	default:
+		return fmt.Sprintf("%v", val)
+	}
+}
+
+func seal(aead cipher.AEAD, plain, aad []byte) (string, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, plain, aad)), nil
+}
+
+func open(aead cipher.AEAD, encoded string, aad []byte) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(data) < aead.NonceSize() {
+		return nil, errors.New("malformed ciphertext")
+	}
+	nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, aad)
+}
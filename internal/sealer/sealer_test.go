@@ -0,0 +1,294 @@
+package sealer
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gocerery/internal/config"
+)
+
+func newKey(t *testing.T) string {
+	t.Helper()
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+func newSealer(t *testing.T, c config.CredentialEncryptionConfig) *Sealer {
+	t.Helper()
+	s, err := New(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// apiPayload API 侧提交前的消息：端点为 []map[string]interface{}，端口为 int
+func apiPayload() map[string]interface{} {
+	return map[string]interface{}{
+		"jump_hosts": []map[string]interface{}{
+			{"host": "111.200.213.14", "port": 63525, "user": "h3c", "password": "jump-pass"},
+		},
+		"targets": []map[string]interface{}{
+			{"host": "10.0.0.21", "port": 22, "user": "dba", "private_key": "PEM", "private_key_passphrase": "pp",
+				"host_key_fingerprint": "SHA256:nThbg6kXUpJWGl7E1IGOCspRomTxdCARLviKw6E5SY8"},
+			{"host": "10.0.0.22", "port": 22, "user": "dba", "key_ref": "deploy_ed25519"},
+		},
+		"commands": []string{"uptime"},
+	}
+}
+
+// toWorker 模拟经 Celery 传输：JSON 编解码后端口变为 float64，列表变为 []interface{}
+func toWorker(t *testing.T, payload map[string]interface{}) map[string]interface{} {
+	t.Helper()
+	data, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out map[string]interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func endpoint(payload map[string]interface{}, list string, idx int) map[string]interface{} {
+	return payload[list].([]interface{})[idx].(map[string]interface{})
+}
+
+func TestSealOpenRoundTrip(t *testing.T) {
+	t.Setenv("TEST_SEALER_KEY", newKey(t))
+	s := newSealer(t, config.CredentialEncryptionConfig{
+		ActiveKeyID: "2026a",
+		Keys:        []config.CredentialKeyConfig{{ID: "2026a", KeyEnv: "TEST_SEALER_KEY"}},
+	})
+	payload := apiPayload()
+	if err := s.Seal(payload); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(payload)
+	for _, secret := range []string{"jump-pass", "PEM", `"pp"`} {
+		if strings.Contains(string(data), secret) {
+			t.Fatalf("sealed message still contains %s: %s", secret, data)
+		}
+	}
+	if !strings.Contains(string(data), "deploy_ed25519") {
+		t.Fatal("key_ref should stay in plain text")
+	}
+
+	msg := toWorker(t, payload)
+	if err := s.Open(msg); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := msg[envelopeField]; ok {
+		t.Fatal("envelope not removed after Open")
+	}
+	if got := endpoint(msg, "jump_hosts", 0)["password"]; got != "jump-pass" {
+		t.Fatalf("jump_hosts[0].password = %v", got)
+	}
+	target := endpoint(msg, "targets", 0)
+	if target["private_key"] != "PEM" || target["private_key_passphrase"] != "pp" {
+		t.Fatalf("targets[0] = %v", target)
+	}
+	if _, ok := target[authField]; ok {
+		t.Fatal("sealed_auth not removed after Open")
+	}
+	if got := endpoint(msg, "targets", 1)["key_ref"]; got != "deploy_ed25519" {
+		t.Fatalf("targets[1].key_ref = %v", got)
+	}
+}
+
+func TestOpenTampered(t *testing.T) {
+	tests := []struct {
+		name    string
+		tamper  func(msg map[string]interface{})
+		wantErr string
+	}{
+		{name: "host", tamper: func(msg map[string]interface{}) {
+			endpoint(msg, "targets", 0)["host"] = "203.0.113.5"
+		}, wantErr: "targets[0] credentials have been tampered with"},
+		{name: "port", tamper: func(msg map[string]interface{}) {
+			endpoint(msg, "targets", 0)["port"] = float64(2222)
+		}, wantErr: "targets[0] credentials have been tampered with"},
+		{name: "user", tamper: func(msg map[string]interface{}) {
+			endpoint(msg, "jump_hosts", 0)["user"] = "root"
+		}, wantErr: "jump_hosts[0] credentials have been tampered with"},
+		{name: "moved to another list", tamper: func(msg map[string]interface{}) {
+			jump := endpoint(msg, "jump_hosts", 0)
+			msg["targets"] = []interface{}{jump}
+			msg["jump_hosts"] = []interface{}{}
+		}, wantErr: "targets[0] credentials have been tampered with"},
+		{name: "reordered", tamper: func(msg map[string]interface{}) {
+			targets := msg["targets"].([]interface{})
+			targets[0], targets[1] = targets[1], targets[0]
+		}, wantErr: "targets[0] credentials have been tampered with"},
+		{name: "host key fingerprint re-pinned", tamper: func(msg map[string]interface{}) {
+			endpoint(msg, "targets", 0)["host_key_fingerprint"] = "SHA256:attackerattackerattackerattackerattacker00"
+		}, wantErr: "targets[0] credentials have been tampered with"},
+		{name: "host key fingerprint added", tamper: func(msg map[string]interface{}) {
+			endpoint(msg, "jump_hosts", 0)["host_key_fingerprint"] = "SHA256:attackerattackerattackerattackerattacker00"
+		}, wantErr: "jump_hosts[0] credentials have been tampered with"},
+		{name: "key_ref swapped", tamper: func(msg map[string]interface{}) {
+			endpoint(msg, "targets", 1)["key_ref"] = "root_ed25519"
+		}, wantErr: "targets[1] credentials have been tampered with"},
+		{name: "password_ref added", tamper: func(msg map[string]interface{}) {
+			endpoint(msg, "targets", 1)["password_ref"] = "env:ROOT_PASS"
+		}, wantErr: "targets[1] credentials have been tampered with"},
+		{name: "sealed_auth stripped", tamper: func(msg map[string]interface{}) {
+			delete(endpoint(msg, "targets", 1), authField)
+		}, wantErr: "targets[1] is not sealed"},
+		{name: "endpoint appended", tamper: func(msg map[string]interface{}) {
+			msg["targets"] = append(msg["targets"].([]interface{}), map[string]interface{}{"host": "203.0.113.5", "user": "root", "password_ref": "env:ROOT_PASS"})
+		}, wantErr: "targets[2] is not sealed"},
+		{name: "key id swapped", tamper: func(msg map[string]interface{}) {
+			msg[envelopeField].(map[string]interface{})["kid"] = "k2"
+		}, wantErr: "tampered with or uses a wrong key"},
+		{name: "unknown key id", tamper: func(msg map[string]interface{}) {
+			msg[envelopeField].(map[string]interface{})["kid"] = "k9"
+		}, wantErr: "unknown credential key id"},
+		{name: "plaintext mixed in", tamper: func(msg map[string]interface{}) {
+			endpoint(msg, "targets", 1)["password"] = "injected"
+		}, wantErr: "targets[1] carries unencrypted password"},
+		{name: "truncated ciphertext", tamper: func(msg map[string]interface{}) {
+			endpoint(msg, "targets", 0)[authField] = "AAAA"
+		}, wantErr: "targets[0] credentials have been tampered with"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TEST_SEALER_K1", newKey(t))
+			t.Setenv("TEST_SEALER_K2", newKey(t))
+			s := newSealer(t, config.CredentialEncryptionConfig{
+				ActiveKeyID: "k1",
+				Keys:        []config.CredentialKeyConfig{{ID: "k1", KeyEnv: "TEST_SEALER_K1"}, {ID: "k2", KeyEnv: "TEST_SEALER_K2"}},
+			})
+			payload := apiPayload()
+			if err := s.Seal(payload); err != nil {
+				t.Fatal(err)
+			}
+			msg := toWorker(t, payload)
+			tt.tamper(msg)
+			err := s.Open(msg)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Open error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// 轮换后 Worker 仍可用非活动密钥解密旧消息
+func TestOpenRotatedKey(t *testing.T) {
+	dir := t.TempDir()
+	oldKey, newKeyFile := filepath.Join(dir, "old.key"), filepath.Join(dir, "new.key")
+	os.WriteFile(oldKey, []byte(newKey(t)+"\n"), 0o600)
+	os.WriteFile(newKeyFile, []byte(newKey(t)), 0o600)
+
+	api := newSealer(t, config.CredentialEncryptionConfig{
+		ActiveKeyID: "old",
+		Keys:        []config.CredentialKeyConfig{{ID: "old", KeyFile: oldKey}},
+	})
+	payload := apiPayload()
+	if err := api.Seal(payload); err != nil {
+		t.Fatal(err)
+	}
+
+	worker := newSealer(t, config.CredentialEncryptionConfig{
+		ActiveKeyID: "new",
+		Keys:        []config.CredentialKeyConfig{{ID: "new", KeyFile: newKeyFile}, {ID: "old", KeyFile: oldKey}},
+	})
+	msg := toWorker(t, payload)
+	if err := worker.Open(msg); err != nil {
+		t.Fatal(err)
+	}
+	if endpoint(msg, "jump_hosts", 0)["password"] != "jump-pass" {
+		t.Fatal("credentials not restored with the rotated key")
+	}
+}
+
+func TestOpenUnsealed(t *testing.T) {
+	plain := func() map[string]interface{} { return toWorker(t, apiPayload()) }
+
+	var disabled *Sealer
+	if err := disabled.Seal(apiPayload()); err != nil {
+		t.Fatalf("nil Sealer.Seal error = %v", err)
+	}
+	if err := disabled.Open(plain()); err != nil {
+		t.Fatalf("nil Sealer.Open error = %v", err)
+	}
+
+	t.Setenv("TEST_SEALER_KEY", newKey(t))
+	keys := []config.CredentialKeyConfig{{ID: "k1", KeyEnv: "TEST_SEALER_KEY"}}
+	decryptOnly := newSealer(t, config.CredentialEncryptionConfig{Keys: keys})
+	payload := apiPayload()
+	if err := decryptOnly.Seal(payload); err != nil || payload[envelopeField] != nil {
+		t.Fatalf("Seal without ActiveKeyID modified the message: %v", err)
+	}
+	if err := decryptOnly.Open(plain()); err != nil {
+		t.Fatalf("Open(plaintext) error = %v", err)
+	}
+
+	required := newSealer(t, config.CredentialEncryptionConfig{Keys: keys, RequireEncrypted: true})
+	if err := required.Open(plain()); err == nil {
+		t.Fatal("RequireEncrypted accepted plaintext credentials")
+	}
+	if err := required.Open(map[string]interface{}{"proxy_password": "p"}); err == nil {
+		t.Fatal("RequireEncrypted accepted plaintext proxy_password")
+	}
+	for _, field := range []string{"key_ref", "password_ref", "host_key_fingerprint"} {
+		msg := map[string]interface{}{"targets": []interface{}{map[string]interface{}{"host": "10.0.0.22", field: "v"}}}
+		if err := required.Open(msg); err == nil {
+			t.Fatalf("RequireEncrypted accepted an unsealed %s", field)
+		}
+	}
+	if err := required.Open(map[string]interface{}{"targets": []interface{}{map[string]interface{}{"host": "10.0.0.22", "user": "dba"}}}); err != nil {
+		t.Fatalf("RequireEncrypted rejected a message without credentials: %v", err)
+	}
+
+	sealedMsg := apiPayload()
+	active := newSealer(t, config.CredentialEncryptionConfig{ActiveKeyID: "k1", Keys: keys})
+	if err := active.Seal(sealedMsg); err != nil {
+		t.Fatal(err)
+	}
+	if err := disabled.Open(toWorker(t, sealedMsg)); err == nil {
+		t.Fatal("nil Sealer opened an encrypted message")
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	dir := t.TempDir()
+	short := filepath.Join(dir, "short.key")
+	os.WriteFile(short, []byte(base64.StdEncoding.EncodeToString(make([]byte, 16))), 0o600)
+	good := filepath.Join(dir, "good.key")
+	os.WriteFile(good, []byte(newKey(t)), 0o600)
+
+	tests := []struct {
+		name string
+		c    config.CredentialEncryptionConfig
+	}{
+		{name: "active key without keys", c: config.CredentialEncryptionConfig{ActiveKeyID: "k1"}},
+		{name: "require without keys", c: config.CredentialEncryptionConfig{RequireEncrypted: true}},
+		{name: "missing id", c: config.CredentialEncryptionConfig{Keys: []config.CredentialKeyConfig{{KeyFile: good}}}},
+		{name: "duplicate id", c: config.CredentialEncryptionConfig{Keys: []config.CredentialKeyConfig{{ID: "k", KeyFile: good}, {ID: "k", KeyFile: good}}}},
+		{name: "short key", c: config.CredentialEncryptionConfig{Keys: []config.CredentialKeyConfig{{ID: "k", KeyFile: short}}}},
+		{name: "no source", c: config.CredentialEncryptionConfig{Keys: []config.CredentialKeyConfig{{ID: "k"}}}},
+		{name: "both sources", c: config.CredentialEncryptionConfig{Keys: []config.CredentialKeyConfig{{ID: "k", KeyFile: good, KeyEnv: "X"}}}},
+		{name: "empty env", c: config.CredentialEncryptionConfig{Keys: []config.CredentialKeyConfig{{ID: "k", KeyEnv: "TEST_SEALER_UNSET"}}}},
+		{name: "unknown active key", c: config.CredentialEncryptionConfig{ActiveKeyID: "k2", Keys: []config.CredentialKeyConfig{{ID: "k", KeyFile: good}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.c); err == nil {
+				t.Fatal("New succeeded, want error")
+			}
+		})
+	}
+	if s, err := New(config.CredentialEncryptionConfig{}); s != nil || err != nil {
+		t.Fatalf("New(empty) = %v, %v, want nil", s, err)
+	}
+}
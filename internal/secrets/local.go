@@ -0,0 +1,84 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// envProvider 读取 Worker 进程的环境变量，变量名须匹配 Secrets.Env 中的某个模式
+type envProvider struct {
+	allowed []string
+}
+
+func (p envProvider) Resolve(_ context.Context, name string) (string, error) {
+	allowed := slices.ContainsFunc(p.allowed, func(pattern string) bool {
+		ok, _ := path.Match(pattern, name)
+		return ok
+	})
+	if !allowed {
+		return "", fmt.Errorf("env:%s is not in Secrets.Env", name)
+	}
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+	return value, nil
+}
+
+// fileProvider 读取 Secrets.FileDirs 目录内的文件，解析符号链接后仍须位于目录内
+type fileProvider struct {
+	dirs     []string
+	realDirs []string // 目录本身解析符号链接后的路径
+}
+
+func newFileProvider(dirs []string) (fileProvider, error) {
+	var p fileProvider
+	for _, dir := range dirs {
+		if !filepath.IsAbs(dir) {
+			return fileProvider{}, fmt.Errorf("Secrets.FileDirs must be absolute paths: %s", dir)
+		}
+		dir = filepath.Clean(dir)
+		real, err := filepath.EvalSymlinks(dir)
+		if err != nil {
+			real = dir
+		}
+		p.dirs = append(p.dirs, dir)
+		p.realDirs = append(p.realDirs, real)
+	}
+	return p, nil
+}
+
+func (p fileProvider) Resolve(_ context.Context, name string) (string, error) {
+	if !filepath.IsAbs(name) {
+		return "", fmt.Errorf("file:%s must be an absolute path", name)
+	}
+	name = filepath.Clean(name)
+	if !within(p.dirs, name) {
+		return "", fmt.Errorf("file:%s is not under Secrets.FileDirs", name)
+	}
+	real, err := filepath.EvalSymlinks(name)
+	if err != nil {
+		return "", fmt.Errorf("read secret file: %w", err)
+	}
+	if !within(p.realDirs, real) {
+		return "", fmt.Errorf("file:%s resolves outside Secrets.FileDirs", name)
+	}
+	data, err := os.ReadFile(real)
+	if err != nil {
+		return "", fmt.Errorf("read secret file: %w", err)
+	}
+	// 去掉编辑器或 echo 写入的结尾换行，避免成为密码的一部分
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+func within(dirs []string, name string) bool {
+	return slices.ContainsFunc(dirs, func(dir string) bool {
+		rel, err := filepath.Rel(dir, name)
+		return err == nil && filepath.IsLocal(rel)
+	})
+}
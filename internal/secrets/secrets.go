@@ -0,0 +1,106 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gocerery/internal/config"
+)
+
+// 支持的引用前缀
+const (
+	SchemeEnv   = "env"
+	SchemeFile  = "file"
+	SchemeVault = "vault"
+)
+
+// SecretProvider 按引用读取密钥，ref 为去掉 scheme: 前缀后的部分
+type SecretProvider interface {
+	Resolve(ctx context.Context, ref string) (string, error)
+}
+
+// Resolver 按 password_ref/key_ref 的前缀把引用分发给对应的 SecretProvider
+type Resolver struct {
+	providers map[string]SecretProvider
+}
+
+// New 按配置创建 Resolver：env/file 只允许引用配置中列出的变量与目录，vault 在配置 Address 后启用
+func New(c config.SecretsConfig) (*Resolver, error) {
+	r := &Resolver{providers: make(map[string]SecretProvider)}
+	if len(c.Env) > 0 {
+		r.Register(SchemeEnv, envProvider{allowed: c.Env})
+	}
+	if len(c.FileDirs) > 0 {
+		p, err := newFileProvider(c.FileDirs)
+		if err != nil {
+			return nil, err
+		}
+		r.Register(SchemeFile, p)
+	}
+	if c.Vault.Address != "" {
+		p, err := NewVaultProvider(c.Vault)
+		if err != nil {
+			return nil, err
+		}
+		r.Register(SchemeVault, p)
+	}
+	return r, nil
+}
+
+// Register 注册或替换某个前缀的 SecretProvider
+func (r *Resolver) Register(scheme string, p SecretProvider) {
+	r.providers[scheme] = p
+}
+
+// Resolve 解析形如 env:NAME、file:/path、vault:path#field 的引用
+func (r *Resolver) Resolve(ctx context.Context, ref string) (string, error) {
+	scheme, rest, err := Parse(ref)
+	if err != nil {
+		return "", err
+	}
+	var p SecretProvider
+	if r != nil {
+		p = r.providers[scheme]
+	}
+	if p == nil {
+		return "", fmt.Errorf("%s: secret references are not enabled on this worker", scheme)
+	}
+	value, err := p.Resolve(ctx, rest)
+	if err != nil {
+		return "", err
+	}
+	if value == "" {
+		return "", fmt.Errorf("secret %s is empty", ref)
+	}
+	return value, nil
+}
+
+// IsRef 判断 key_ref 是否为带前缀的引用；不带前缀时仍表示 Executor.KeyDir 下的文件名
+func IsRef(ref string) bool {
+	scheme, _, ok := strings.Cut(ref, ":")
+	return ok && isScheme(scheme)
+}
+
+// Parse 拆分引用的前缀与内容，API 提交任务时用于提前校验格式
+func Parse(ref string) (scheme, rest string, err error) {
+	scheme, rest, ok := strings.Cut(ref, ":")
+	if !ok || !isScheme(scheme) {
+		return "", "", fmt.Errorf("invalid secret reference %q: must start with env:, file: or vault:", ref)
+	}
+	if rest == "" {
+		return "", "", fmt.Errorf("invalid secret reference %q", ref)
+	}
+	if scheme == SchemeVault {
+		path, field, _ := strings.Cut(rest, "#")
+		if path == "" || field == "" {
+			return "", "", errors.New("vault reference must be vault:<path>#<field>")
+		}
+	}
+	return scheme, rest, nil
+}
+
+func isScheme(s string) bool {
+	return s == SchemeEnv || s == SchemeFile || s == SchemeVault
+}
@@ -0,0 +1,123 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"gocerery/internal/config"
+)
+
+// defaultVaultTimeout 未配置 Vault.TimeoutSeconds 时单次请求的超时
+const defaultVaultTimeout = 10 * time.Second
+
+// VaultProvider 通过 HTTP API 读取 Vault（或兼容接口）中的密钥，引用格式为 path#field，
+// 如 secret/data/web1#password；同时支持 KV v1 与 KV v2 的响应格式
+type VaultProvider struct {
+	address   string
+	token     string
+	namespace string
+	paths     []string
+	client    *http.Client
+}
+
+// NewVaultProvider 根据配置创建 VaultProvider
+func NewVaultProvider(c config.VaultConfig) (*VaultProvider, error) {
+	if c.Address == "" {
+		return nil, errors.New("Secrets.Vault.Address is required")
+	}
+	if c.Token == "" {
+		return nil, errors.New("Secrets.Vault.Token is required")
+	}
+	timeout := defaultVaultTimeout
+	if c.TimeoutSeconds > 0 {
+		timeout = time.Duration(c.TimeoutSeconds) * time.Second
+	}
+	return &VaultProvider{
+		address:   strings.TrimRight(c.Address, "/"),
+		token:     c.Token,
+		namespace: c.Namespace,
+		paths:     c.Paths,
+		client:    &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// vaultResponse Vault 读取接口的响应；KV v2 的键值位于 data.data 中
+type vaultResponse struct {
+	Data   map[string]interface{} `json:"data"`
+	Errors []string               `json:"errors"`
+}
+
+func (p *VaultProvider) Resolve(ctx context.Context, ref string) (string, error) {
+	secretPath, field, _ := strings.Cut(ref, "#")
+	secretPath = strings.Trim(secretPath, "/")
+	if secretPath == "" || field == "" {
+		return "", errors.New("vault reference must be vault:<path>#<field>")
+	}
+	// 拒绝 ../ 等写法，避免绕过 Paths 限制
+	if path.Clean(secretPath) != secretPath {
+		return "", fmt.Errorf("invalid vault path: %s", secretPath)
+	}
+	if !p.allowed(secretPath) {
+		return "", fmt.Errorf("vault:%s is not under Secrets.Vault.Paths", secretPath)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.address+"/v1/"+secretPath, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", p.token)
+	if p.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", p.namespace)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("read vault secret %s: %w", secretPath, err)
+	}
+	defer resp.Body.Close()
+
+	var body vaultResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil && resp.StatusCode == http.StatusOK {
+		return "", fmt.Errorf("decode vault response for %s: %w", secretPath, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		if len(body.Errors) > 0 {
+			return "", fmt.Errorf("read vault secret %s: %s: %s", secretPath, resp.Status, strings.Join(body.Errors, "; "))
+		}
+		return "", fmt.Errorf("read vault secret %s: %s", secretPath, resp.Status)
+	}
+
+	data := body.Data
+	if inner, ok := data["data"].(map[string]interface{}); ok {
+		if _, v2 := data["metadata"]; v2 {
+			data = inner
+		}
+	}
+	value, ok := data[field]
+	if !ok {
+		return "", fmt.Errorf("vault secret %s has no field %s", secretPath, field)
+	}
+	s, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("vault secret %s field %s is not a string", secretPath, field)
+	}
+	return s, nil
+}
+
+// allowed 未配置 Paths 时只受 Token 自身权限限制
+func (p *VaultProvider) allowed(secretPath string) bool {
+	if len(p.paths) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(p.paths, func(prefix string) bool {
+		prefix = strings.Trim(prefix, "/")
+		return secretPath == prefix || strings.HasPrefix(secretPath, prefix+"/")
+	})
+}
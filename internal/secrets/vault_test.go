@@ -0,0 +1,137 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gocerery/internal/config"
+)
+
+// newVaultStub 模拟 Vault 的读取接口：校验 Token 与命名空间，按路径返回 KV v1/v2 响应
+func newVaultStub(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "s.test" || r.Header.Get("X-Vault-Namespace") != "ops" {
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]interface{}{"errors": []string{"permission denied"}})
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/ssh/web1":
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{
+				"data":     map[string]interface{}{"password": "v2-pass", "port": 22},
+				"metadata": map[string]interface{}{"version": 3},
+			}})
+		case "/v1/kv/ssh/db1":
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"password": "v1-pass"}})
+		case "/v1/secret/data/ssh/slow":
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]interface{}{"errors": []string{}})
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestVaultProviderResolve(t *testing.T) {
+	srv := newVaultStub(t)
+	p, err := NewVaultProvider(config.VaultConfig{
+		Address:   srv.URL + "/",
+		Token:     "s.test",
+		Namespace: "ops",
+		Paths:     []string{"secret/data/ssh", "/kv/ssh/"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		ref     string
+		want    string
+		wantErr string
+	}{
+		{name: "kv v2", ref: "secret/data/ssh/web1#password", want: "v2-pass"},
+		{name: "kv v1", ref: "kv/ssh/db1#password", want: "v1-pass"},
+		{name: "missing field", ref: "secret/data/ssh/web1#user", wantErr: "has no field user"},
+		{name: "non-string field", ref: "secret/data/ssh/web1#port", wantErr: "is not a string"},
+		{name: "not found", ref: "secret/data/ssh/none#password", wantErr: "404"},
+		{name: "outside paths", ref: "secret/data/db/root#password", wantErr: "not under Secrets.Vault.Paths"},
+		{name: "prefix is not a path boundary", ref: "secret/data/sshx/web1#password", wantErr: "not under Secrets.Vault.Paths"},
+		{name: "dot dot", ref: "secret/data/ssh/../db/root#password", wantErr: "invalid vault path"},
+		{name: "no field", ref: "secret/data/ssh/web1", wantErr: "vault:<path>#<field>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := p.Resolve(context.Background(), tt.ref)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Resolve(%q) error = %v, want containing %q", tt.ref, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Resolve(%q) error = %v", tt.ref, err)
+			}
+			if got != tt.want {
+				t.Fatalf("Resolve(%q) = %q, want %q", tt.ref, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVaultProviderForbidden(t *testing.T) {
+	srv := newVaultStub(t)
+	p, err := NewVaultProvider(config.VaultConfig{Address: srv.URL, Token: "s.other"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = p.Resolve(context.Background(), "secret/data/ssh/web1#password")
+	if err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Fatalf("Resolve error = %v, want permission denied", err)
+	}
+}
+
+// 任务撤销或超时时 Vault 请求随上下文取消
+func TestVaultProviderContextCancel(t *testing.T) {
+	srv := newVaultStub(t)
+	p, err := NewVaultProvider(config.VaultConfig{Address: srv.URL, Token: "s.test", Namespace: "ops"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = p.Resolve(ctx, "secret/data/ssh/slow#password")
+	if err == nil {
+		t.Fatal("Resolve succeeded, want context error")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("Resolve returned after %v, want it to follow the context deadline", elapsed)
+	}
+}
+
+func TestResolverVaultRef(t *testing.T) {
+	srv := newVaultStub(t)
+	r, err := New(config.SecretsConfig{Vault: config.VaultConfig{Address: srv.URL, Token: "s.test", Namespace: "ops"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := r.Resolve(context.Background(), "vault:secret/data/ssh/web1#password")
+	if err != nil || got != "v2-pass" {
+		t.Fatalf("Resolve = %q, %v, want v2-pass", got, err)
+	}
+	if _, err := r.Resolve(context.Background(), "env:DB1_PASS"); err == nil {
+		t.Fatal("env: resolved without Secrets.Env")
+	}
+}
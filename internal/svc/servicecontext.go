@@ -1,22 +1,91 @@
 package svc
 
 import (
+	"context"
+	"errors"
 	"fmt"
 
+	"gocerery/internal/artifact"
+	"gocerery/internal/audit"
 	"gocerery/internal/config"
+	"gocerery/internal/download"
+	"gocerery/internal/guard"
+	"gocerery/internal/inventory"
+	"gocerery/internal/middleware"
+	"gocerery/internal/policy"
+	"gocerery/internal/sealer"
+	"gocerery/internal/taskstate"
 
 	"github.com/gocelery/gocelery"
+	"github.com/zeromicro/go-zero/core/logx"
+	"github.com/zeromicro/go-zero/core/threading"
+	"github.com/zeromicro/go-zero/rest"
 )
 
 type ServiceContext struct {
 	Config        config.Config
 	CeleryClient  *gocelery.CeleryClient
 	CeleryBackend *gocelery.RedisCeleryBackend
+	TaskState     *taskstate.Store
+	Inventory     *inventory.Inventory
+	Auth          rest.Middleware
+	Policy        *policy.Engine
+	Guard         *guard.Guard
+	Audit         *audit.Recorder
+	Sealer        *sealer.Sealer
+	Artifacts     artifact.Store
 }
 
 func NewServiceContext(c config.Config) (*ServiceContext, error) {
+	inv, err := inventory.New(c.Targets)
+	if err != nil {
+		return nil, fmt.Errorf("load target inventory: %w", err)
+	}
+	if err := checkBastions(c.Bastions); err != nil {
+		return nil, err
+	}
+	authMiddleware, err := middleware.NewAuthMiddleware(c.Auth)
+	if err != nil {
+		return nil, err
+	}
+	if !authMiddleware.Enabled() {
+		logx.Infow("authentication is disabled, configure Auth.APIKeys or Auth.AccessSecret to protect the API")
+	}
+	engine, err := policy.New(c.Policy, inv)
+	if err != nil {
+		return nil, fmt.Errorf("load policy: %w", err)
+	}
+	if engine.Enabled() && !authMiddleware.Enabled() {
+		return nil, errors.New("Policy requires Auth to be configured")
+	}
+	commandGuard, err := guard.New(c.CommandGuard)
+	if err != nil {
+		return nil, fmt.Errorf("load command guard: %w", err)
+	}
+	credentialSealer, err := sealer.New(c.CredentialEncryption)
+	if err != nil {
+		return nil, fmt.Errorf("load credential keys: %w", err)
+	}
+	artifacts, err := artifact.New(c.Artifacts)
+	if err != nil {
+		return nil, fmt.Errorf("init artifact store: %w", err)
+	}
+	if err := download.CheckStore(c.Downloads, artifacts != nil); err != nil {
+		return nil, err
+	}
+	if retention := artifact.Retention(c.Artifacts); artifacts != nil && retention > 0 {
+		threading.GoSafe(func() {
+			artifact.RunSweeper(context.Background(), artifacts, retention)
+		})
+	}
 	ctx := &ServiceContext{
-		Config: c,
+		Config:    c,
+		Inventory: inv,
+		Auth:      authMiddleware.Handle,
+		Policy:    engine,
+		Guard:     commandGuard,
+		Sealer:    credentialSealer,
+		Artifacts: artifacts,
 	}
 
 	if c.Celery.Broker != "" && c.Celery.Backend != "" {
@@ -34,6 +103,17 @@ func NewServiceContext(c config.Config) (*ServiceContext, error) {
 
 		ctx.CeleryClient = client
 		ctx.CeleryBackend = backend
+		ctx.TaskState = taskstate.New(backend)
+	}
+
+	recorder, err := audit.New(c.Audit, ctx.CeleryBackend)
+	if err != nil {
+		return nil, fmt.Errorf("init audit log: %w", err)
+	}
+	ctx.Audit = recorder
+	if c.Audit.Mode == audit.ModeFile {
+		logx.Infow("Audit.Mode is file, GET /api/audit only returns events written to this host's Audit.Path; " +
+			"worker events are missing unless workers share the same file, use redis for separate deployments")
 	}
 
 	return ctx, nil
@@ -41,3 +121,20 @@ func NewServiceContext(c config.Config) (*ServiceContext, error) {
 
 // Code scaffolded by goctl. Safe to edit.
 // goctl 1.9.2
+
+// checkBastions 命名跳板机的 Name 必填且唯一
+func checkBastions(bastions []config.BastionConfig) error {
+	seen := make(map[string]bool, len(bastions))
+	for idx, b := range bastions {
+		switch {
+		case b.Name == "":
+			return fmt.Errorf("Bastions[%d] name is required", idx)
+		case b.Host == "" || b.User == "":
+			return fmt.Errorf("Bastions[%d] (%s) host/user are required", idx, b.Name)
+		case seen[b.Name]:
+			return fmt.Errorf("duplicate bastion name in Bastions: %s", b.Name)
+		}
+		seen[b.Name] = true
+	}
+	return nil
+}
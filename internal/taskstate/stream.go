@@ -0,0 +1,96 @@
+package taskstate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// 实时事件类型
+const (
+	EventState  = "state"  // 主机状态变化
+	EventOutput = "output" // 命令输出的一行
+	EventEnd    = "end"    // 任务结束，Status 为最终状态
+)
+
+// StreamEvent Worker 通过 Redis pub/sub 推送的实时事件
+type StreamEvent struct {
+	Type    string `json:"type"`
+	Index   int    `json:"index"` // 目标主机下标
+	Name    string `json:"name,omitempty"`
+	Host    string `json:"host,omitempty"`
+	State   string `json:"state,omitempty"`
+	Command int    `json:"command,omitempty"` // 命令序号（从 1 开始）
+	Stream  string `json:"stream,omitempty"`  // stdout 或 stderr
+	Line    string `json:"line,omitempty"`
+	Status  string `json:"status,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+func streamChannel(taskID string) string {
+	return fmt.Sprintf("gocerery-task-stream-%s", taskID)
+}
+
+// Publish 发布任务的实时事件；没有订阅者时事件直接丢弃
+func (s *Store) Publish(taskID string, ev StreamEvent) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	conn := s.backend.Get()
+	defer conn.Close()
+	_, err = conn.Do("PUBLISH", streamChannel(taskID), data)
+	return err
+}
+
+// Subscribe 订阅任务的实时事件，返回时订阅已生效；
+// ctx 结束或连接断开时关闭返回的通道
+func (s *Store) Subscribe(ctx context.Context, taskID string) (<-chan StreamEvent, error) {
+	// 订阅会独占连接，不从连接池中获取
+	conn, err := s.backend.Dial()
+	if err != nil {
+		return nil, err
+	}
+	psc := redis.PubSubConn{Conn: conn}
+	if err := psc.Subscribe(streamChannel(taskID)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	switch reply := psc.Receive().(type) {
+	case redis.Subscription:
+	case error:
+		conn.Close()
+		return nil, reply
+	default:
+		conn.Close()
+		return nil, fmt.Errorf("unexpected subscribe reply: %v", reply)
+	}
+
+	events := make(chan StreamEvent, 64)
+	// ctx 结束时关闭连接以中断阻塞的 Receive
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	go func() {
+		defer close(events)
+		defer stop()
+		defer conn.Close()
+		for {
+			switch msg := psc.Receive().(type) {
+			case redis.Message:
+				var ev StreamEvent
+				if err := json.Unmarshal(msg.Data, &ev); err != nil {
+					continue
+				}
+				select {
+				case events <- ev:
+				case <-ctx.Done():
+					return
+				}
+			case error:
+				return
+			}
+		}
+	}()
+	return events, nil
+}
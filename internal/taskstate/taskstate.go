@@ -0,0 +1,132 @@
+package taskstate
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gocelery/gocelery"
+	"github.com/gomodule/redigo/redis"
+)
+
+// 任务状态，与 Celery 保持一致
+const (
+	StatusPending  = "PENDING"
+	StatusStarted  = "STARTED"
+	StatusProgress = "PROGRESS"
+	StatusSuccess  = "SUCCESS"
+	StatusFailure  = "FAILURE"
+	StatusRevoked  = "REVOKED"
+)
+
+// 单台主机在执行过程中的状态
+const (
+	HostQueued     = "queued"
+	HostConnecting = "connecting"
+	HostRunning    = "running"
+	HostDone       = "done"
+)
+
+// ttlSeconds 与 gocelery 写入 celery-task-meta 的过期时间一致
+const ttlSeconds = 86400
+
+// Store 基于 Celery Redis Backend 保存任务撤销标记与状态，API 与 Worker 共用
+type Store struct {
+	backend *gocelery.RedisCeleryBackend
+}
+
+func New(backend *gocelery.RedisCeleryBackend) *Store {
+	return &Store{backend: backend}
+}
+
+// Finished 判断状态是否为终态
+func Finished(status string) bool {
+	switch status {
+	case StatusSuccess, StatusFailure, StatusRevoked:
+		return true
+	default:
+		return false
+	}
+}
+
+// Running 判断任务是否正在 Worker 中执行
+func Running(status string) bool {
+	return status == StatusStarted || status == StatusProgress
+}
+
+// HostCompleted 判断单台主机是否已完成；旧版结果不带 state，任务终态时视为完成
+func HostCompleted(taskStatus, hostState string) bool {
+	if hostState == "" {
+		return Finished(taskStatus)
+	}
+	return hostState == HostDone
+}
+
+func revokedKey(taskID string) string {
+	return fmt.Sprintf("gocerery-revoked-%s", taskID)
+}
+
+// Revoke 标记任务已撤销，Worker 启动任务前及执行过程中会检查该标记
+func (s *Store) Revoke(taskID string) error {
+	conn := s.backend.Get()
+	defer conn.Close()
+	_, err := conn.Do("SETEX", revokedKey(taskID), ttlSeconds, 1)
+	return err
+}
+
+// IsRevoked 查询任务是否已被撤销
+func (s *Store) IsRevoked(taskID string) (bool, error) {
+	conn := s.backend.Get()
+	defer conn.Close()
+	return redis.Bool(conn.Do("EXISTS", revokedKey(taskID)))
+}
+
+func submittedKey(taskID string) string {
+	return fmt.Sprintf("gocerery-task-submitted-%s", taskID)
+}
+
+// MarkSubmitted 记录任务已由 API 提交，撤销时据此区分排队中的任务与未知的 task_id
+func (s *Store) MarkSubmitted(taskID string) error {
+	conn := s.backend.Get()
+	defer conn.Close()
+	_, err := conn.Do("SETEX", submittedKey(taskID), ttlSeconds, 1)
+	return err
+}
+
+// Submitted 查询任务是否由 API 提交
+func (s *Store) Submitted(taskID string) (bool, error) {
+	conn := s.backend.Get()
+	defer conn.Close()
+	return redis.Bool(conn.Do("EXISTS", submittedKey(taskID)))
+}
+
+func submitterKey(taskID string) string {
+	return fmt.Sprintf("gocerery-task-submitter-%s", taskID)
+}
+
+// SetSubmitter 记录提交任务的调用方身份
+func (s *Store) SetSubmitter(taskID, name string) error {
+	conn := s.backend.Get()
+	defer conn.Close()
+	_, err := conn.Do("SETEX", submitterKey(taskID), ttlSeconds, name)
+	return err
+}
+
+// Submitter 查询提交任务的调用方身份，未记录时返回空字符串
+func (s *Store) Submitter(taskID string) (string, error) {
+	conn := s.backend.Get()
+	defer conn.Close()
+	name, err := redis.String(conn.Do("GET", submitterKey(taskID)))
+	if errors.Is(err, redis.ErrNil) {
+		return "", nil
+	}
+	return name, err
+}
+
+// SetStatus 以 Celery 结果格式写入任务状态，result 为空时仅更新状态
+func (s *Store) SetStatus(taskID, status string, result interface{}) error {
+	return s.backend.SetResult(taskID, &gocelery.ResultMessage{
+		ID:     taskID,
+		Status: status,
+		Result: result,
+	})
+}
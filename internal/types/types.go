@@ -3,25 +3,187 @@
 
 package types
 
-type HostResult struct {
-	Name     string `json:"name"`
+type AuditEvent struct {
+	Time         string             `json:"time"`
+	Type         string             `json:"type"`
+	Source       string             `json:"source"`
+	Kind         string             `json:"kind"`
+	TaskID       string             `json:"task_id,omitempty"`
+	Principal    string             `json:"principal,omitempty"`
+	SourceIP     string             `json:"source_ip,omitempty"`
+	Targets      []AuditTarget      `json:"targets,omitempty"`
+	Commands     []string           `json:"commands,omitempty"`
+	LocalPath    string             `json:"local_path,omitempty"`
+	ArtifactID   string             `json:"artifact_id,omitempty"`
+	RemotePath   string             `json:"remote_path,omitempty"`
+	RemotePaths  []string           `json:"remote_paths,omitempty"`
+	DangerousAck bool               `json:"dangerous_ack,omitempty"`
+	Status       string             `json:"status,omitempty"`
+	Error        string             `json:"error,omitempty"`
+	Hosts        []AuditHostOutcome `json:"hosts,omitempty"`
+}
+
+type AuditHostOutcome struct {
+	Name     string `json:"name,omitempty"`
 	Host     string `json:"host"`
 	Success  bool   `json:"success"`
-	Stdout   string `json:"stdout"`
-	Stderr   string `json:"stderr"`
-	ExitCode int    `json:"exit_code"`
+	ExitCode int    `json:"exit_code,omitempty"`
 	Error    string `json:"error,omitempty"`
 }
 
+type AuditRequest struct {
+	Since  string `form:"since,optional"`
+	Until  string `form:"until,optional"`
+	User   string `form:"user,optional"`
+	Host   string `form:"host,optional"`
+	TaskID string `form:"task_id,optional"`
+	Limit  int    `form:"limit,optional"`
+}
+
+type AuditResponse struct {
+	Events []AuditEvent `json:"events"`
+}
+
+type AuditTarget struct {
+	Name string `json:"name,omitempty"`
+	Host string `json:"host"`
+	Port int    `json:"port,omitempty"`
+	User string `json:"user,omitempty"`
+}
+
+type CommandResult struct {
+	Command    string `json:"command"`
+	Stdout     string `json:"stdout"`
+	Stderr     string `json:"stderr"`
+	ExitCode   int    `json:"exit_code"`
+	StartedAt  string `json:"started_at"`
+	FinishedAt string `json:"finished_at"`
+	DurationMs int64  `json:"duration_ms"`
+	Success    bool   `json:"success"`
+	Error      string `json:"error,omitempty"`
+}
+
+type CommandSpec struct {
+	Command          string `json:"command"`
+	AllowedExitCodes []int  `json:"allowed_exit_codes,optional"`
+}
+
+type DownloadArchiveRequest struct {
+	TaskID string `path:"id"`
+	Host   string `form:"host,optional"`
+}
+
+type DownloadFileResult struct {
+	Remote     string `json:"remote"`
+	Path       string `json:"path,omitempty"`
+	Bytes      int64  `json:"bytes"`
+	DurationMs int64  `json:"duration_ms"`
+	SHA256     string `json:"sha256,omitempty"`
+	ArtifactID string `json:"artifact_id,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+type DownloadResult struct {
+	Name        string               `json:"name"`
+	Host        string               `json:"host"`
+	Success     bool                 `json:"success"`
+	Dir         string               `json:"dir,omitempty"`
+	Files       []DownloadFileResult `json:"files,omitempty"`
+	FailedFiles []DownloadFileResult `json:"failed_files,omitempty"`
+	Bytes       int64                `json:"bytes"`
+	Error       string               `json:"error,omitempty"`
+	ErrorCode   string               `json:"error_code,omitempty"`
+	State       string               `json:"state,omitempty"`
+}
+
+type DownloadTaskRequest struct {
+	ProxyName                 string             `json:"proxy_name,optional"`
+	ProxyHost                 string             `json:"proxy_host,optional"`
+	ProxyPort                 int                `json:"proxy_port,optional"`
+	ProxyUser                 string             `json:"proxy_user,optional"`
+	ProxyPassword             string             `json:"proxy_password,optional"`
+	ProxyPrivateKey           string             `json:"proxy_private_key,optional"`
+	ProxyPrivateKeyPassphrase string             `json:"proxy_private_key_passphrase,optional"`
+	ProxyKeyRef               string             `json:"proxy_key_ref,optional"`
+	ProxyPasswordRef          string             `json:"proxy_password_ref,optional"`
+	ProxyHostKeyFingerprint   string             `json:"proxy_host_key_fingerprint,optional"`
+	JumpHosts                 []JumpHost         `json:"jump_hosts,optional"`
+	Direct                    bool               `json:"direct,optional"`
+	Targets                   []TargetCredential `json:"targets,optional"`
+	TargetNames               []string           `json:"target_names,optional"`
+	Groups                    []string           `json:"groups,optional"`
+	Tags                      map[string]string  `json:"tags,optional"`
+	RemotePaths               []string           `json:"remote_paths"`
+	Timeout                   int                `json:"timeout,optional"`
+	SaveLog                   bool               `json:"save_log,optional"`
+}
+
+type DownloadTaskResponse struct {
+	TaskID  string `json:"task_id"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+type DownloadTaskStatusResponse struct {
+	TaskID      string           `json:"task_id"`
+	Status      string           `json:"status"`
+	Results     []DownloadResult `json:"results,omitempty"`
+	Completed   int              `json:"completed"`
+	Total       int              `json:"total"`
+	Error       string           `json:"error,omitempty"`
+	SubmittedBy string           `json:"submitted_by,omitempty"`
+}
+
+type HostResult struct {
+	Name           string          `json:"name"`
+	Host           string          `json:"host"`
+	Success        bool            `json:"success"`
+	Stdout         string          `json:"stdout"`
+	Stderr         string          `json:"stderr"`
+	ExitCode       int             `json:"exit_code"`
+	Error          string          `json:"error,omitempty"`
+	ErrorCode      string          `json:"error_code,omitempty"`
+	Commands       []CommandResult `json:"commands,omitempty"`
+	State          string          `json:"state,omitempty"`
+	CurrentCommand int             `json:"current_command,omitempty"`
+}
+
+type JumpHost struct {
+	Name                 string `json:"name,optional"`
+	Host                 string `json:"host,optional"`
+	Port                 int    `json:"port,optional"`
+	User                 string `json:"user,optional"`
+	Password             string `json:"password,optional"`
+	PrivateKey           string `json:"private_key,optional"`
+	PrivateKeyPassphrase string `json:"private_key_passphrase,optional"`
+	KeyRef               string `json:"key_ref,optional"`
+	PasswordRef          string `json:"password_ref,optional"`
+	HostKeyFingerprint   string `json:"host_key_fingerprint,optional"`
+}
+
 type SshTaskRequest struct {
-	ProxyHost     string             `json:"proxy_host"`
-	ProxyPort     int                `json:"proxy_port"`
-	ProxyUser     string             `json:"proxy_user"`
-	ProxyPassword string             `json:"proxy_password"`
-	Targets       []TargetCredential `json:"targets"`
-	Commands      []string           `json:"commands"`
-	Timeout       int                `json:"timeout,omitempty"`
-	SaveLog       bool               `json:"save_log,omitempty"`
+	ProxyName                 string             `json:"proxy_name,optional"`
+	ProxyHost                 string             `json:"proxy_host,optional"`
+	ProxyPort                 int                `json:"proxy_port,optional"`
+	ProxyUser                 string             `json:"proxy_user,optional"`
+	ProxyPassword             string             `json:"proxy_password,optional"`
+	ProxyPrivateKey           string             `json:"proxy_private_key,optional"`
+	ProxyPrivateKeyPassphrase string             `json:"proxy_private_key_passphrase,optional"`
+	ProxyKeyRef               string             `json:"proxy_key_ref,optional"`
+	ProxyPasswordRef          string             `json:"proxy_password_ref,optional"`
+	ProxyHostKeyFingerprint   string             `json:"proxy_host_key_fingerprint,optional"`
+	JumpHosts                 []JumpHost         `json:"jump_hosts,optional"`
+	Direct                    bool               `json:"direct,optional"`
+	Targets                   []TargetCredential `json:"targets,optional"`
+	TargetNames               []string           `json:"target_names,optional"`
+	Groups                    []string           `json:"groups,optional"`
+	Tags                      map[string]string  `json:"tags,optional"`
+	Commands                  []string           `json:"commands,optional"`
+	CommandSpecs              []CommandSpec      `json:"command_specs,optional"`
+	OnError                   string             `json:"on_error,optional"`
+	DangerousAck              bool               `json:"dangerous_ack,optional"`
+	Timeout                   int                `json:"timeout,optional"`
+	SaveLog                   bool               `json:"save_log,optional"`
 }
 
 type SshTaskResponse struct {
@@ -35,39 +197,114 @@ type SshTaskStatusRequest struct {
 }
 
 type SshTaskStatusResponse struct {
-	TaskID  string       `json:"task_id"`
-	Status  string       `json:"status"`
-	Results []HostResult `json:"results,omitempty"`
-	Error   string       `json:"error,omitempty"`
+	TaskID      string       `json:"task_id"`
+	Status      string       `json:"status"`
+	Results     []HostResult `json:"results,omitempty"`
+	Completed   int          `json:"completed"`
+	Total       int          `json:"total"`
+	Error       string       `json:"error,omitempty"`
+	SubmittedBy string       `json:"submitted_by,omitempty"`
 }
 
 type TargetCredential struct {
-	Name     string `json:"name"`
-	Host     string `json:"host"`
-	Port     int    `json:"port"`
-	User     string `json:"user"`
-	Password string `json:"password"`
+	Name                 string `json:"name"`
+	Host                 string `json:"host"`
+	Port                 int    `json:"port"`
+	User                 string `json:"user"`
+	Password             string `json:"password,optional"`
+	PrivateKey           string `json:"private_key,optional"`
+	PrivateKeyPassphrase string `json:"private_key_passphrase,optional"`
+	KeyRef               string `json:"key_ref,optional"`
+	PasswordRef          string `json:"password_ref,optional"`
+	HostKeyFingerprint   string `json:"host_key_fingerprint,optional"`
+}
+
+type TaskRevokeResponse struct {
+	TaskID  string `json:"task_id"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+type TaskStreamEvent struct {
+	Type    string `json:"type"`
+	Index   int    `json:"index"`
+	Name    string `json:"name,omitempty"`
+	Host    string `json:"host,omitempty"`
+	State   string `json:"state,omitempty"`
+	Command int    `json:"command,omitempty"`
+	Stream  string `json:"stream,omitempty"`
+	Line    string `json:"line,omitempty"`
+	Status  string `json:"status,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+type UploadArtifactResponse struct {
+	ArtifactID string `json:"artifact_id"`
+	Name       string `json:"name"`
+	Size       int64  `json:"size"`
+	SHA256     string `json:"sha256"`
+}
+
+type UploadFileResult struct {
+	Local      string `json:"local"`
+	Remote     string `json:"remote"`
+	Bytes      int64  `json:"bytes"`
+	DurationMs int64  `json:"duration_ms"`
+	SHA256     string `json:"sha256,omitempty"`
+	Mode       string `json:"mode,omitempty"`
+	Owner      string `json:"owner,omitempty"`
+	Group      string `json:"group,omitempty"`
+	Atomic     bool   `json:"atomic,omitempty"`
+	Backup     string `json:"backup,omitempty"`
+	Error      string `json:"error,omitempty"`
 }
 
 type UploadResult struct {
-	Name          string   `json:"name"`
-	Host          string   `json:"host"`
-	Success       bool     `json:"success"`
-	UploadedFiles []string `json:"uploaded_files,omitempty"`
-	FailedFiles   []string `json:"failed_files,omitempty"`
-	Error         string   `json:"error,omitempty"`
+	Name          string             `json:"name"`
+	Host          string             `json:"host"`
+	Success       bool               `json:"success"`
+	UploadedFiles []UploadFileResult `json:"uploaded_files,omitempty"`
+	FailedFiles   []UploadFileResult `json:"failed_files,omitempty"`
+	Transferred   int                `json:"transferred"`
+	Skipped       int                `json:"skipped"`
+	Verified      int                `json:"verified"`
+	Filtered      int                `json:"filtered"`
+	Error         string             `json:"error,omitempty"`
+	ErrorCode     string             `json:"error_code,omitempty"`
+	State         string             `json:"state,omitempty"`
 }
 
 type UploadTaskRequest struct {
-	ProxyHost     string             `json:"proxy_host"`
-	ProxyPort     int                `json:"proxy_port"`
-	ProxyUser     string             `json:"proxy_user"`
-	ProxyPassword string             `json:"proxy_password"`
-	Targets       []TargetCredential `json:"targets"`
-	LocalPath     string             `json:"local_path"`
-	RemotePath    string             `json:"remote_path"`
-	Timeout       int                `json:"timeout,omitempty"`
-	SaveLog       bool               `json:"save_log,omitempty"`
+	ProxyName                 string             `json:"proxy_name,optional"`
+	ProxyHost                 string             `json:"proxy_host,optional"`
+	ProxyPort                 int                `json:"proxy_port,optional"`
+	ProxyUser                 string             `json:"proxy_user,optional"`
+	ProxyPassword             string             `json:"proxy_password,optional"`
+	ProxyPrivateKey           string             `json:"proxy_private_key,optional"`
+	ProxyPrivateKeyPassphrase string             `json:"proxy_private_key_passphrase,optional"`
+	ProxyKeyRef               string             `json:"proxy_key_ref,optional"`
+	ProxyPasswordRef          string             `json:"proxy_password_ref,optional"`
+	ProxyHostKeyFingerprint   string             `json:"proxy_host_key_fingerprint,optional"`
+	JumpHosts                 []JumpHost         `json:"jump_hosts,optional"`
+	Direct                    bool               `json:"direct,optional"`
+	Targets                   []TargetCredential `json:"targets,optional"`
+	TargetNames               []string           `json:"target_names,optional"`
+	Groups                    []string           `json:"groups,optional"`
+	Tags                      map[string]string  `json:"tags,optional"`
+	LocalPath                 string             `json:"local_path,optional"`
+	ArtifactID                string             `json:"artifact_id,optional"`
+	RemotePath                string             `json:"remote_path"`
+	UploadMode                string             `json:"upload_mode,optional"`
+	SyncCompare               string             `json:"sync_compare,optional"`
+	Mode                      string             `json:"mode,optional"`
+	Owner                     string             `json:"owner,optional"`
+	Group                     string             `json:"group,optional"`
+	Atomic                    bool               `json:"atomic,optional"`
+	BackupExisting            bool               `json:"backup_existing,optional"`
+	Include                   []string           `json:"include,optional"`
+	Exclude                   []string           `json:"exclude,optional"`
+	Timeout                   int                `json:"timeout,optional"`
+	SaveLog                   bool               `json:"save_log,optional"`
 }
 
 type UploadTaskResponse struct {
@@ -77,8 +314,11 @@ type UploadTaskResponse struct {
 }
 
 type UploadTaskStatusResponse struct {
-	TaskID  string         `json:"task_id"`
-	Status  string         `json:"status"`
-	Results []UploadResult `json:"results,omitempty"`
-	Error   string         `json:"error,omitempty"`
+	TaskID      string         `json:"task_id"`
+	Status      string         `json:"status"`
+	Results     []UploadResult `json:"results,omitempty"`
+	Completed   int            `json:"completed"`
+	Total       int            `json:"total"`
+	Error       string         `json:"error,omitempty"`
+	SubmittedBy string         `json:"submitted_by,omitempty"`
 }
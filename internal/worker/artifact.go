@@ -0,0 +1,31 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"gocerery/internal/artifact"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// fetchArtifact 把 artifact 取回到临时目录，返回本地文件路径；上传结束后调用 cleanup 删除
+func (r *Runner) fetchArtifact(id string) (string, func(), error) {
+	if r.artifacts == nil {
+		return "", nil, errors.New("artifact_id is given but Artifacts is not configured on this worker")
+	}
+	dir, err := os.MkdirTemp("", "gocerery-artifact-")
+	if err != nil {
+		return "", nil, err
+	}
+	localPath, err := artifact.Fetch(context.Background(), r.artifacts, id, dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", nil, err
+	}
+	logx.Infow("[WORKER] artifact fetched",
+		logx.Field("artifact_id", id),
+		logx.Field("local_path", localPath))
+	return localPath, func() { os.RemoveAll(dir) }, nil
+}
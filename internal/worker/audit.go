@@ -0,0 +1,71 @@
+package worker
+
+import (
+	"gocerery/internal/audit"
+)
+
+// newAuditEvent 生成 Worker 侧的审计记录，任务 ID 取自 Celery 消息
+func newAuditEvent(payload map[string]interface{}, kind, principal string, targets []targetPayload) audit.Event {
+	event := audit.Event{
+		Source:    audit.SourceWorker,
+		Kind:      kind,
+		TaskID:    stringValue(payload[taskIDKwarg]),
+		Principal: principal,
+		Targets:   make([]audit.Target, 0, len(targets)),
+	}
+	for _, t := range targets {
+		event.Targets = append(event.Targets, audit.Target{Name: t.Name, Host: t.Host, Port: t.Port, User: t.User})
+	}
+	return event
+}
+
+// recordFinished 记录任务结束时的状态与各主机结果
+func (r *Runner) recordFinished(event audit.Event, status string, err error, hosts []audit.HostOutcome) {
+	event.Type = audit.EventTaskFinished
+	event.Status = status
+	event.Hosts = hosts
+	if err != nil {
+		event.Error = err.Error()
+	}
+	r.audit.Record(event)
+}
+
+func hostOutcomes(results []HostResult) []audit.HostOutcome {
+	outcomes := make([]audit.HostOutcome, 0, len(results))
+	for _, res := range results {
+		outcomes = append(outcomes, audit.HostOutcome{
+			Name:     res.Name,
+			Host:     res.Host,
+			Success:  res.Success,
+			ExitCode: res.ExitCode,
+			Error:    res.Error,
+		})
+	}
+	return outcomes
+}
+
+func downloadOutcomes(results []DownloadResult) []audit.HostOutcome {
+	outcomes := make([]audit.HostOutcome, 0, len(results))
+	for _, res := range results {
+		outcomes = append(outcomes, audit.HostOutcome{
+			Name:    res.Name,
+			Host:    res.Host,
+			Success: res.Success,
+			Error:   res.Error,
+		})
+	}
+	return outcomes
+}
+
+func uploadOutcomes(results []UploadResult) []audit.HostOutcome {
+	outcomes := make([]audit.HostOutcome, 0, len(results))
+	for _, res := range results {
+		outcomes = append(outcomes, audit.HostOutcome{
+			Name:    res.Name,
+			Host:    res.Host,
+			Success: res.Success,
+			Error:   res.Error,
+		})
+	}
+	return outcomes
+}
@@ -0,0 +1,280 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"gocerery/internal/artifact"
+	"gocerery/internal/audit"
+	"gocerery/internal/download"
+	"gocerery/internal/sealer"
+	"gocerery/internal/taskstate"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// DownloadTask 实现 CeleryTask 接口，用于处理文件下载任务
+type DownloadTask struct {
+	runner  *Runner
+	mu      sync.Mutex
+	payload map[string]interface{}
+}
+
+// ParseKwargs 解析 kwargs 参数
+func (t *DownloadTask) ParseKwargs(kwargs map[string]interface{}) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.payload = kwargs
+	return nil
+}
+
+// RunTask 执行任务
+func (t *DownloadTask) RunTask() (interface{}, error) {
+	t.mu.Lock()
+	payload := t.payload
+	t.mu.Unlock()
+	return t.runner.executeDownload(payload)
+}
+
+type downloadTaskPayload struct {
+	JumpHosts   []sshEndpoint // 按顺序经过的跳板机，为空时直连目标主机
+	Targets     []targetPayload
+	RemotePaths []string // 远程绝对路径，支持 * ? [...] 通配；匹配到目录时递归下载
+	LocalDirs   []string // 与 Targets 下标对应的本地目录，执行前由 Runner 填充
+	MaxBytes    int64    // 单台主机取回的总大小上限
+	Timeout     int
+	SubmittedBy string // 提交任务的调用方身份，仅用于日志
+}
+
+func parseDownloadPayload(data map[string]interface{}, s *sealer.Sealer) (*downloadTaskPayload, error) {
+	if err := s.Open(data); err != nil {
+		return nil, fmt.Errorf("decrypt credentials: %w", err)
+	}
+	jumpHosts, err := parseJumpHosts(data)
+	if err != nil {
+		return nil, err
+	}
+	rawTargets, ok := data["targets"]
+	if !ok {
+		return nil, errors.New("targets is required")
+	}
+	targets, err := parseTargets(rawTargets)
+	if err != nil {
+		return nil, err
+	}
+	rawPaths, ok := data["remote_paths"].([]interface{})
+	if !ok {
+		return nil, errors.New("remote_paths must be an array")
+	}
+	remotePaths := make([]string, 0, len(rawPaths))
+	for idx, item := range rawPaths {
+		p := stringValue(item)
+		// 与 API 使用同一份校验，防止绕过 API 直接投递的消息
+		if err := download.ValidatePattern(p); err != nil {
+			return nil, fmt.Errorf("remote_paths[%d]: %w", idx, err)
+		}
+		remotePaths = append(remotePaths, p)
+	}
+
+	task := &downloadTaskPayload{
+		JumpHosts:   jumpHosts,
+		Targets:     targets,
+		RemotePaths: remotePaths,
+		Timeout:     intValue(data["timeout"]),
+		SubmittedBy: stringValue(data["submitted_by"]),
+	}
+	if len(task.Targets) == 0 {
+		return nil, errors.New("targets cannot be empty")
+	}
+	if len(task.RemotePaths) == 0 {
+		return nil, errors.New("remote_paths cannot be empty")
+	}
+	return task, nil
+}
+
+func (r *Runner) executeDownload(payload map[string]interface{}) (interface{}, error) {
+	logx.Infow("[WORKER] received download task, parsing payload...")
+	task, err := parseDownloadPayload(payload, r.sealer)
+	if err != nil {
+		logx.Errorw("[WORKER] failed to parse download payload", logx.Field("error", err))
+		return nil, err
+	}
+
+	logx.Infow("[WORKER] download task parsed",
+		logx.Field("submitted_by", task.SubmittedBy),
+		logx.Field("jump_hosts", jumpHostAddrs(task.JumpHosts)),
+		logx.Field("targets", len(task.Targets)),
+		logx.Field("remote_paths", task.RemotePaths))
+
+	event := newAuditEvent(payload, audit.KindDownload, task.SubmittedBy, task.Targets)
+	event.RemotePaths = task.RemotePaths
+
+	if err := r.checkAuth(task.JumpHosts, task.Targets); err != nil {
+		logx.Errorw("[WORKER] invalid credentials", logx.Field("error", err))
+		r.recordFinished(event, taskstate.StatusFailure, err, nil)
+		return nil, err
+	}
+
+	timeout := normalizeTimeout(task.Timeout, r.timeout)
+	logx.Infow("[WORKER] using timeout and concurrency",
+		logx.Field("timeout", timeout),
+		logx.Field("concurrency", r.concurrency))
+
+	run, err := r.startRun(payload)
+	if err != nil {
+		r.recordFinished(event, taskstate.StatusRevoked, err, nil)
+		return nil, err
+	}
+	if run.id == "" {
+		run.cancel()
+		err := errors.New("download task has no task id")
+		r.recordFinished(event, taskstate.StatusFailure, err, nil)
+		return nil, err
+	}
+
+	if err := r.prepareAuth(run, timeout, task.JumpHosts, task.Targets); err != nil {
+		if revokeErr := r.finishRun(run, nil); revokeErr != nil {
+			r.recordFinished(event, taskstate.StatusRevoked, revokeErr, nil)
+			return nil, revokeErr
+		}
+		logx.Errorw("[WORKER] failed to prepare credentials", logx.Field("error", err))
+		r.recordFinished(event, taskstate.StatusFailure, err, nil)
+		return nil, err
+	}
+
+	// 每个任务一个目录，每台主机一个子目录
+	taskDir := filepath.Join(download.Dir(r.cfg.Downloads), run.id)
+	names := make([]string, len(task.Targets))
+	for i, target := range task.Targets {
+		names[i] = target.Name
+		if names[i] == "" {
+			names[i] = target.Host
+		}
+	}
+	hostDirs := download.HostDirs(names)
+	task.LocalDirs = make([]string, len(hostDirs))
+	for i, dir := range hostDirs {
+		task.LocalDirs[i] = filepath.Join(taskDir, dir)
+		logx.Infow("[WORKER] download target info",
+			logx.Field("index", i),
+			logx.Field("name", task.Targets[i].Name),
+			logx.Field("host", fmt.Sprintf("%s:%d", task.Targets[i].Host, task.Targets[i].Port)),
+			logx.Field("local_dir", task.LocalDirs[i]))
+	}
+	task.MaxBytes = download.MaxSize(r.cfg.Downloads)
+
+	event.Type = audit.EventTaskStarted
+	r.audit.Record(event)
+
+	queued := make([]DownloadResult, len(task.Targets))
+	for i, target := range task.Targets {
+		queued[i] = newDownloadResult(target)
+		queued[i].Dir = path.Join(run.id, hostDirs[i])
+	}
+	progress := newTaskProgress(r.state, run.id, queued)
+	reportDir := func(idx int, result DownloadResult) {
+		result.Dir = queued[idx].Dir
+		progress.update(idx, result)
+	}
+
+	logx.Infow("[WORKER] executing download task", logx.Field("backend", r.executorBackend))
+	results, err := r.executor.Download(run.ctx, task, timeout, reportDir)
+	progress.close()
+	for i := range results {
+		if i < len(queued) {
+			results[i].Dir = queued[i].Dir
+		}
+	}
+	if err == nil && r.cfg.Downloads.Store == download.StoreArtifact {
+		r.storeDownloads(results, task.LocalDirs, task.SubmittedBy)
+		os.RemoveAll(taskDir)
+	}
+	if revokeErr := r.finishRun(run, results); revokeErr != nil {
+		r.recordFinished(event, taskstate.StatusRevoked, revokeErr, downloadOutcomes(results))
+		return nil, revokeErr
+	}
+	if err == nil && len(results) == 0 {
+		logx.Errorw("[WORKER] download executor returned empty result")
+		err = errors.New("download executor returned empty result")
+	}
+	if err != nil {
+		r.markFailed(run.id, results)
+		r.recordFinished(event, taskstate.StatusFailure, err, downloadOutcomes(results))
+		return nil, err
+	}
+	r.recordFinished(event, taskstate.StatusSuccess, nil, downloadOutcomes(results))
+
+	for i, res := range results {
+		logx.Infow("[WORKER] download result",
+			logx.Field("index", i),
+			logx.Field("name", res.Name),
+			logx.Field("host", res.Host),
+			logx.Field("success", res.Success),
+			logx.Field("dir", res.Dir),
+			logx.Field("file_count", len(res.Files)),
+			logx.Field("bytes", res.Bytes),
+			logx.Field("failed_count", len(res.FailedFiles)))
+		if !res.Success && res.Error != "" {
+			logx.Errorw("[WORKER] download result error",
+				logx.Field("index", i),
+				logx.Field("error", res.Error))
+		}
+	}
+
+	return results, nil
+}
+
+// storeDownloads 把取回的文件逐个写入 artifact 存储并记录 artifact_id；写入失败的文件移到 failed_files
+func (r *Runner) storeDownloads(results []DownloadResult, localDirs []string, owner string) {
+	for i := range results {
+		res := &results[i]
+		files := res.Files
+		res.Files = []DownloadedFile{}
+		res.Bytes = 0
+		for _, f := range files {
+			id, err := r.storeDownload(filepath.Join(localDirs[i], filepath.FromSlash(f.Path)), f, owner)
+			if err != nil {
+				f.Error = fmt.Sprintf("store artifact: %v", err)
+			}
+			f.ArtifactID = id
+			res.record(f)
+		}
+		if len(res.FailedFiles) > 0 && res.Error == "" {
+			res.Error = fmt.Sprintf("%d file(s) failed to download", len(res.FailedFiles))
+		}
+	}
+}
+
+func (r *Runner) storeDownload(localFile string, f DownloadedFile, owner string) (string, error) {
+	if r.artifacts == nil {
+		return "", errors.New("Artifacts is not configured on this worker")
+	}
+	id, err := artifact.NewID()
+	if err != nil {
+		return "", err
+	}
+	src, err := os.Open(localFile)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+	meta := artifact.Meta{
+		ID:        id,
+		Name:      path.Base(f.Path),
+		Size:      f.Bytes,
+		SHA256:    f.SHA256,
+		Owner:     owner,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := r.artifacts.Put(context.Background(), meta, io.LimitReader(src, f.Bytes)); err != nil {
+		return "", err
+	}
+	return id, nil
+}
@@ -0,0 +1,263 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"gocerery/internal/config"
+	"gocerery/internal/taskstate"
+)
+
+const (
+	// BackendGo 使用 golang.org/x/crypto/ssh 原生实现
+	BackendGo = "go"
+	// BackendPython 调用 scripts/ 下的 Paramiko 脚本
+	BackendPython = "python"
+)
+
+const (
+	// OnErrorStop 命令失败后停止执行剩余命令（默认）
+	OnErrorStop = "stop"
+	// OnErrorContinue 命令失败后继续执行剩余命令，主机结果仍记为失败
+	OnErrorContinue = "continue"
+	// OnErrorIgnore 继续执行剩余命令，且不因退出码将主机结果记为失败
+	OnErrorIgnore = "ignore"
+)
+
+// Executor 是 SSH 任务执行器接口，不同后端需返回相同结构的结果
+type Executor interface {
+	// Execute 经跳板机连接每台目标主机并顺序执行命令
+	Execute(ctx context.Context, task *taskPayload, timeout int, report hostReporter) ([]HostResult, error)
+	// Upload 经跳板机把本地文件或目录上传到每台目标主机
+	Upload(ctx context.Context, task *uploadTaskPayload, timeout int, report uploadReporter) ([]UploadResult, error)
+	// Download 经跳板机把每台目标主机上匹配的文件取回到 task.LocalDirs 中对应的目录
+	Download(ctx context.Context, task *downloadTaskPayload, timeout int, report downloadReporter) ([]DownloadResult, error)
+}
+
+// hostReporter 执行过程中上报第 idx 台主机的最新结果与实时输出，字段均可为 nil
+type hostReporter struct {
+	onResult func(idx int, result HostResult)
+	onOutput func(idx int, line outputLine)
+}
+
+func (r hostReporter) report(idx int, result HostResult) {
+	if r.onResult == nil {
+		return
+	}
+	result.Commands = slices.Clone(result.Commands)
+	r.onResult(idx, result)
+}
+
+func (r hostReporter) output(idx int, line outputLine) {
+	if r.onOutput != nil {
+		r.onOutput(idx, line)
+	}
+}
+
+// uploadReporter 上传过程中上报第 idx 台主机的最新结果，可为 nil
+type uploadReporter func(idx int, result UploadResult)
+
+func (f uploadReporter) report(idx int, result UploadResult) {
+	if f == nil {
+		return
+	}
+	result.UploadedFiles = slices.Clone(result.UploadedFiles)
+	result.FailedFiles = slices.Clone(result.FailedFiles)
+	f(idx, result)
+}
+
+// downloadReporter 下载过程中上报第 idx 台主机的最新结果，可为 nil
+type downloadReporter func(idx int, result DownloadResult)
+
+func (f downloadReporter) report(idx int, result DownloadResult) {
+	if f == nil {
+		return
+	}
+	result.Files = slices.Clone(result.Files)
+	result.FailedFiles = slices.Clone(result.FailedFiles)
+	f(idx, result)
+}
+
+// HostResult 单台目标主机的命令执行结果，字段与 ssh_executor.py 输出保持一致
+type HostResult struct {
+	Name      string `json:"name"`
+	Host      string `json:"host"`
+	Success   bool   `json:"success"`
+	Stdout    string `json:"stdout"`
+	Stderr    string `json:"stderr"`
+	ExitCode  int    `json:"exit_code"`
+	Error     string `json:"error"`
+	ErrorCode string `json:"error_code,omitempty"` // 如 host_key_mismatch
+	// State 执行进度：queued, connecting, running, done；CurrentCommand 为正在执行的命令序号（从 1 开始）
+	State          string `json:"state,omitempty"`
+	CurrentCommand int    `json:"current_command,omitempty"`
+	// Commands 每条命令各自的输出与耗时；Stdout/Stderr/ExitCode 保留为汇总结果
+	Commands []CommandResult `json:"commands"`
+}
+
+// CommandResult 单条命令的执行结果
+type CommandResult struct {
+	Command    string    `json:"command"`
+	Stdout     string    `json:"stdout"`
+	Stderr     string    `json:"stderr"`
+	ExitCode   int       `json:"exit_code"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	DurationMs int64     `json:"duration_ms"`
+	Success    bool      `json:"success"` // 未出错且退出码在 allowed_exit_codes 内
+	Error      string    `json:"error,omitempty"`
+}
+
+func newHostResult(target targetPayload) HostResult {
+	return HostResult{
+		Name:     target.Name,
+		Host:     target.Host,
+		Success:  true,
+		Commands: []CommandResult{},
+		State:    taskstate.HostQueued,
+	}
+}
+
+// UploadResult 单台目标主机的上传结果，字段与 ssh_uploader.py 输出保持一致
+type UploadResult struct {
+	Name          string         `json:"name"`
+	Host          string         `json:"host"`
+	Success       bool           `json:"success"`
+	UploadedFiles []FileTransfer `json:"uploaded_files"`
+	FailedFiles   []FileTransfer `json:"failed_files"`
+	Transferred   int            `json:"transferred"` // 实际传输成功的文件数
+	Skipped       int            `json:"skipped"`     // 按 upload_mode 判断无需传输的文件数
+	Verified      int            `json:"verified"`    // sync 模式下传输后 sha256 校验一致的文件数
+	Filtered      int            `json:"filtered"`    // 被 include/exclude 过滤掉的文件数，被排除的目录按 1 计
+	Error         string         `json:"error"`
+	ErrorCode     string         `json:"error_code,omitempty"`
+	State         string         `json:"state,omitempty"`
+}
+
+// FileTransfer 单个文件的传输记录
+type FileTransfer struct {
+	Local      string `json:"local"`
+	Remote     string `json:"remote"`
+	Bytes      int64  `json:"bytes"`
+	DurationMs int64  `json:"duration_ms"`
+	SHA256     string `json:"sha256,omitempty"` // 传输内容的 sha256
+	Mode       string `json:"mode,omitempty"`   // 设置后的权限（八进制）
+	Owner      string `json:"owner,omitempty"`
+	Group      string `json:"group,omitempty"`
+	Atomic     bool   `json:"atomic,omitempty"` // 是否经临时文件原子替换
+	Backup     string `json:"backup,omitempty"` // 旧版本的备份路径
+	Error      string `json:"error,omitempty"`
+}
+
+func newUploadResult(target targetPayload) UploadResult {
+	return UploadResult{
+		Name:          target.Name,
+		Host:          target.Host,
+		Success:       true,
+		UploadedFiles: []FileTransfer{},
+		FailedFiles:   []FileTransfer{},
+		State:         taskstate.HostQueued,
+	}
+}
+
+// DownloadResult 单台目标主机的下载结果，字段与 ssh_downloader.py 输出保持一致
+type DownloadResult struct {
+	Name        string           `json:"name"`
+	Host        string           `json:"host"`
+	Success     bool             `json:"success"`
+	Dir         string           `json:"dir"` // 主机目录，相对 Downloads.Dir：<task_id>/<主机>
+	Files       []DownloadedFile `json:"files"`
+	FailedFiles []DownloadedFile `json:"failed_files"`
+	Bytes       int64            `json:"bytes"` // 取回的总字节数
+	Error       string           `json:"error"`
+	ErrorCode   string           `json:"error_code,omitempty"`
+	State       string           `json:"state,omitempty"`
+}
+
+// DownloadedFile 单个文件的下载记录
+type DownloadedFile struct {
+	Remote     string `json:"remote"`
+	Path       string `json:"path"` // 相对主机目录的路径，即去掉开头 / 的远程路径
+	Bytes      int64  `json:"bytes"`
+	DurationMs int64  `json:"duration_ms"`
+	SHA256     string `json:"sha256,omitempty"`
+	ArtifactID string `json:"artifact_id,omitempty"` // Downloads.Store=artifact 时文件所在的 artifact
+	Error      string `json:"error,omitempty"`
+}
+
+func newDownloadResult(target targetPayload) DownloadResult {
+	return DownloadResult{
+		Name:        target.Name,
+		Host:        target.Host,
+		Success:     true,
+		Files:       []DownloadedFile{},
+		FailedFiles: []DownloadedFile{},
+		State:       taskstate.HostQueued,
+	}
+}
+
+// record 按是否出错把下载记录归入 files 或 failed_files
+func (r *DownloadResult) record(f DownloadedFile) {
+	if f.Error != "" {
+		r.FailedFiles = append(r.FailedFiles, f)
+		r.Success = false
+		return
+	}
+	r.Files = append(r.Files, f)
+	r.Bytes += f.Bytes
+}
+
+// newExecutor 根据 Executor.Backend 配置创建执行器
+func newExecutor(cfg *config.Config) (Executor, error) {
+	hostKeys, err := newHostKeyStore(cfg.Executor.HostKeyPolicy, cfg.Executor.KnownHostsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	backend := strings.ToLower(strings.TrimSpace(cfg.Executor.Backend))
+	switch backend {
+	case "", BackendGo:
+		return &nativeExecutor{
+			concurrency:  cfg.Executor.Concurrency,
+			jumpPoolSize: cfg.Executor.JumpPoolSize,
+			dialer:       sshDialer{useAgent: cfg.Executor.UseAgent, hostKeys: hostKeys},
+		}, nil
+	case BackendPython:
+		uploadScript := cfg.Executor.UploadScript
+		if uploadScript == "" {
+			uploadScript = "./scripts/ssh_uploader.py"
+		}
+		downloadScript := cfg.Executor.DownloadScript
+		if downloadScript == "" {
+			downloadScript = "./scripts/ssh_downloader.py"
+		}
+		return &pythonExecutor{
+			scriptPath:         filepath.Clean(cfg.Executor.Script),
+			uploadScriptPath:   filepath.Clean(uploadScript),
+			downloadScriptPath: filepath.Clean(downloadScript),
+			concurrency:        cfg.Executor.Concurrency,
+			jumpPoolSize:       cfg.Executor.JumpPoolSize,
+			useAgent:           cfg.Executor.UseAgent,
+			hostKeyPolicy:      hostKeys.policy,
+			knownHostsFile:     hostKeys.path,
+			logCfg:             &cfg.WorkerLog,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown executor backend: %s", cfg.Executor.Backend)
+	}
+}
+
+// effectiveConcurrency 计算实际并发数，不超过目标主机数量
+func effectiveConcurrency(concurrency, targets int) int {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	if concurrency > targets && targets > 0 {
+		concurrency = targets
+	}
+	return concurrency
+}
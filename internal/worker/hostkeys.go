@@ -0,0 +1,226 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const (
+	// HostKeyStrict 只接受 known_hosts 中已存在（或与 host_key_fingerprint 一致）的主机密钥
+	HostKeyStrict = "strict"
+	// HostKeyAcceptNew 首次连接时记录主机密钥，之后密钥变化视为不匹配（默认）
+	HostKeyAcceptNew = "accept-new"
+	// HostKeyInsecure 不校验主机密钥，等同 Paramiko AutoAddPolicy
+	HostKeyInsecure = "insecure"
+
+	defaultKnownHostsFile = "data/known_hosts"
+)
+
+const (
+	// ErrCodeHostKeyMismatch 主机密钥与 known_hosts 或 host_key_fingerprint 不一致
+	ErrCodeHostKeyMismatch = "host_key_mismatch"
+	// ErrCodeHostKeyUnknown strict 策略下主机不在 known_hosts 中
+	ErrCodeHostKeyUnknown = "host_key_unknown"
+)
+
+// hostKeyError 主机密钥校验失败，Code 会写入结果的 error_code 字段
+type hostKeyError struct {
+	Code        string
+	Host        string
+	Fingerprint string
+	Reason      string
+}
+
+func (e *hostKeyError) Error() string {
+	return fmt.Sprintf("%s: %s presented %s (%s)", e.Code, e.Host, e.Fingerprint, e.Reason)
+}
+
+// errorCode 从错误链中提取结果使用的 error_code
+func errorCode(err error) string {
+	var hkErr *hostKeyError
+	if errors.As(err, &hkErr) {
+		return hkErr.Code
+	}
+	return ""
+}
+
+// normalizeHostKeyPolicy 校验并返回主机密钥策略，空值使用 accept-new
+func normalizeHostKeyPolicy(policy string) (string, error) {
+	switch p := strings.ToLower(strings.TrimSpace(policy)); p {
+	case "":
+		return HostKeyAcceptNew, nil
+	case HostKeyStrict, HostKeyAcceptNew, HostKeyInsecure:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown host key policy: %s", policy)
+	}
+}
+
+// hostKeyStore Worker 本地的 known_hosts 存储，accept-new 策略下会追加新主机
+type hostKeyStore struct {
+	policy string
+	path   string
+
+	mu       sync.Mutex
+	callback ssh.HostKeyCallback
+}
+
+func newHostKeyStore(policy, path string) (*hostKeyStore, error) {
+	policy, err := normalizeHostKeyPolicy(policy)
+	if err != nil {
+		return nil, err
+	}
+	if path == "" {
+		path = defaultKnownHostsFile
+	}
+	return &hostKeyStore{policy: policy, path: path}, nil
+}
+
+// callbackFor 返回校验指定端点的 HostKeyCallback；设置了 host_key_fingerprint 时以指纹为准
+func (s *hostKeyStore) callbackFor(ep sshEndpoint) ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		fingerprint := ssh.FingerprintSHA256(key)
+		if pinned := ep.HostKeyFingerprint; pinned != "" {
+			if !fingerprintEqual(pinned, fingerprint) {
+				return &hostKeyError{Code: ErrCodeHostKeyMismatch, Host: hostname, Fingerprint: fingerprint,
+					Reason: "does not match host_key_fingerprint " + pinned}
+			}
+			return nil
+		}
+		if s.policy == HostKeyInsecure {
+			return nil
+		}
+		return s.check(hostname, remote, key, fingerprint)
+	}
+}
+
+func (s *hostKeyStore) check(hostname string, remote net.Addr, key ssh.PublicKey, fingerprint string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.callback == nil {
+		if err := s.load(); err != nil {
+			return err
+		}
+	}
+
+	err := s.callback(hostname, remote, key)
+	var keyErr *knownhosts.KeyError
+	var revokedErr *knownhosts.RevokedError
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &revokedErr):
+		return &hostKeyError{Code: ErrCodeHostKeyMismatch, Host: hostname, Fingerprint: fingerprint, Reason: "key is revoked"}
+	case errors.As(err, &keyErr) && len(keyErr.Want) > 0:
+		return &hostKeyError{Code: ErrCodeHostKeyMismatch, Host: hostname, Fingerprint: fingerprint,
+			Reason: "known_hosts expects " + ssh.FingerprintSHA256(keyErr.Want[0].Key)}
+	case errors.As(err, &keyErr):
+		if s.policy != HostKeyAcceptNew {
+			return &hostKeyError{Code: ErrCodeHostKeyUnknown, Host: hostname, Fingerprint: fingerprint, Reason: "not found in " + s.path}
+		}
+		return s.add(hostname, key)
+	default:
+		return err
+	}
+}
+
+// algorithmsFor 返回 known_hosts 中该端点已记录密钥的算法，作为握手时的 HostKeyAlgorithms，
+// 与 OpenSSH 一样优先协商已知类型的密钥，避免服务端另有 ed25519 等密钥时被误判为 host_key_mismatch；
+// 未记录、设置了 host_key_fingerprint 或 insecure 策略时返回 nil，使用默认算法
+func (s *hostKeyStore) algorithmsFor(ep sshEndpoint) []string {
+	if s.policy == HostKeyInsecure || ep.HostKeyFingerprint != "" {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.callback == nil {
+		if err := s.load(); err != nil {
+			return nil
+		}
+	}
+
+	// 用不可能匹配的密钥查询，KeyError.Want 即为该主机已记录的全部密钥
+	var keyErr *knownhosts.KeyError
+	if err := s.callback(ep.addr(), &net.TCPAddr{IP: net.IPv4zero}, probeKey{}); !errors.As(err, &keyErr) {
+		return nil
+	}
+	var algorithms []string
+	for _, known := range keyErr.Want {
+		for _, algo := range hostKeyAlgorithms(known.Key.Type()) {
+			if !slices.Contains(algorithms, algo) {
+				algorithms = append(algorithms, algo)
+			}
+		}
+	}
+	return algorithms
+}
+
+// hostKeyAlgorithms 密钥类型对应的签名算法，RSA 密钥可用 SHA-2 签名
+func hostKeyAlgorithms(keyType string) []string {
+	if keyType == ssh.KeyAlgoRSA {
+		return []string{ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA}
+	}
+	return []string{keyType}
+}
+
+// probeKey 只用于查询 known_hosts 的占位公钥
+type probeKey struct{}
+
+func (probeKey) Type() string                            { return "probe" }
+func (probeKey) Marshal() []byte                         { return []byte("gocerery-known-hosts-probe") }
+func (probeKey) Verify(_ []byte, _ *ssh.Signature) error { return errors.New("probe key") }
+
+// load 读取 known_hosts，文件不存在时先创建空文件
+func (s *hostKeyStore) load() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return fmt.Errorf("create known_hosts dir: %w", err)
+	}
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_RDONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("open known_hosts: %w", err)
+	}
+	f.Close()
+
+	callback, err := knownhosts.New(s.path)
+	if err != nil {
+		return fmt.Errorf("load known_hosts: %w", err)
+	}
+	s.callback = callback
+	return nil
+}
+
+// add 追加新主机密钥并重新加载，调用方需持有锁
+func (s *hostKeyStore) add(hostname string, key ssh.PublicKey) error {
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("open known_hosts: %w", err)
+	}
+	_, err = fmt.Fprintln(f, knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("write known_hosts: %w", err)
+	}
+	return s.load()
+}
+
+// fingerprintEqual 比较 SHA256 指纹，允许省略 "SHA256:" 前缀与末尾的 "="
+func fingerprintEqual(pinned, actual string) bool {
+	trim := func(fp string) string {
+		fp = strings.TrimSpace(fp)
+		fp = strings.TrimPrefix(fp, "SHA256:")
+		return strings.TrimRight(fp, "=")
+	}
+	return trim(pinned) == trim(actual)
+}
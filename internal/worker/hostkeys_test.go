@@ -0,0 +1,140 @@
+package worker
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func newHostSigner(t *testing.T, keyType string) ssh.Signer {
+	t.Helper()
+	var key any
+	var err error
+	switch keyType {
+	case ssh.KeyAlgoRSA:
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+	case ssh.KeyAlgoECDSA256:
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	default:
+		_, key, err = ed25519.GenerateKey(rand.Reader)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+func writeKnownHosts(t *testing.T, lines ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	var data []byte
+	for _, line := range lines {
+		data = append(data, line+"\n"...)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestAlgorithmsFor(t *testing.T) {
+	rsaKey := newHostSigner(t, ssh.KeyAlgoRSA).PublicKey()
+	edKey := newHostSigner(t, ssh.KeyAlgoED25519).PublicKey()
+	path := writeKnownHosts(t,
+		knownhosts.Line([]string{"rsa.example"}, rsaKey),
+		knownhosts.Line([]string{"[both.example]:2222"}, edKey),
+		knownhosts.Line([]string{"[both.example]:2222"}, rsaKey),
+	)
+	store, err := newHostKeyStore(HostKeyStrict, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		ep   sshEndpoint
+		want []string
+	}{
+		{sshEndpoint{Host: "rsa.example", Port: 22}, []string{ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA}},
+		{sshEndpoint{Host: "both.example", Port: 2222}, []string{ssh.KeyAlgoED25519, ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA}},
+		{sshEndpoint{Host: "both.example", Port: 22}, nil},
+		{sshEndpoint{Host: "unknown.example", Port: 22}, nil},
+		{sshEndpoint{Host: "rsa.example", Port: 22, HostKeyFingerprint: ssh.FingerprintSHA256(rsaKey)}, nil},
+	}
+	for _, tt := range tests {
+		if got := store.algorithmsFor(tt.ep); !slices.Equal(got, tt.want) {
+			t.Errorf("algorithmsFor(%s, fingerprint=%q) = %q, want %q", tt.ep.addr(), tt.ep.HostKeyFingerprint, got, tt.want)
+		}
+	}
+
+	insecure, err := newHostKeyStore(HostKeyInsecure, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := insecure.algorithmsFor(sshEndpoint{Host: "rsa.example", Port: 22}); got != nil {
+		t.Errorf("insecure algorithmsFor = %q, want nil", got)
+	}
+}
+
+// 服务端同时有 ECDSA 与 ed25519 密钥、known_hosts 只记录了 ed25519 时，
+// 默认会优先协商 ECDSA 而误报 mismatch，应改为协商已记录的类型
+func TestHostKeyNegotiatesKnownType(t *testing.T) {
+	edSigner := newHostSigner(t, ssh.KeyAlgoED25519)
+	serverConfig := &ssh.ServerConfig{NoClientAuth: true}
+	serverConfig.AddHostKey(newHostSigner(t, ssh.KeyAlgoECDSA256))
+	serverConfig.AddHostKey(edSigner)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				if _, chans, reqs, err := ssh.NewServerConn(conn, serverConfig); err == nil {
+					go ssh.DiscardRequests(reqs)
+					for ch := range chans {
+						ch.Reject(ssh.Prohibited, "no channels")
+					}
+				}
+			}()
+		}
+	}()
+
+	addr := ln.Addr().(*net.TCPAddr)
+	ep := sshEndpoint{Host: "127.0.0.1", Port: addr.Port, User: "test"}
+	path := writeKnownHosts(t, knownhosts.Line([]string{knownhosts.Normalize(ep.addr())}, edSigner.PublicKey()))
+	store, err := newHostKeyStore(HostKeyStrict, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := ssh.Dial("tcp", ep.addr(), &ssh.ClientConfig{
+		User:              ep.User,
+		HostKeyCallback:   store.callbackFor(ep),
+		HostKeyAlgorithms: store.algorithmsFor(ep),
+	})
+	if err != nil {
+		t.Fatalf("dial %s: %v", ep.addr(), err)
+	}
+	client.Close()
+}
@@ -0,0 +1,102 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// jumpPool 任务内共享的跳板机链连接池：最多建立 size 条跳板机链，各目标主机轮流在其上
+// 打开 direct-tcpip 通道，避免每台目标主机都重新与跳板机握手。没有跳板机时直连目标主机
+type jumpPool struct {
+	dialer  sshDialer
+	jumps   []sshEndpoint
+	timeout time.Duration
+	next    atomic.Uint64
+	slots   []*jumpSlot
+}
+
+// jumpSlot 一条跳板机链；建立失败时记录错误，后续分配到该链的目标主机直接返回，
+// 避免对已知不可用的跳板机反复握手
+type jumpSlot struct {
+	mu      sync.Mutex
+	clients []*ssh.Client
+	err     error
+	closed  bool
+}
+
+func newJumpPool(dialer sshDialer, jumps []sshEndpoint, size int, timeout time.Duration) *jumpPool {
+	if size <= 0 {
+		size = 1
+	}
+	p := &jumpPool{dialer: dialer, jumps: jumps, timeout: timeout}
+	if len(jumps) > 0 {
+		p.slots = make([]*jumpSlot, size)
+		for i := range p.slots {
+			p.slots[i] = &jumpSlot{}
+		}
+	}
+	return p
+}
+
+// dial 登录目标主机，返回的客户端由调用方关闭；跳板机链由连接池在 Close 时统一关闭
+func (p *jumpPool) dial(ctx context.Context, target sshEndpoint) (*ssh.Client, error) {
+	var last *ssh.Client
+	if len(p.slots) > 0 {
+		slot := p.slots[(p.next.Add(1)-1)%uint64(len(p.slots))]
+		var err error
+		if last, err = slot.get(ctx, p); err != nil {
+			return nil, err
+		}
+	}
+	return p.dialer.hop(ctx, last, target, "target", p.timeout)
+}
+
+// get 返回链上最后一级跳板机的客户端，尚未建立或连接已断开时重新建立
+func (s *jumpSlot) get(ctx context.Context, p *jumpPool) (*ssh.Client, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return nil, s.err
+	}
+	if s.clients != nil {
+		return s.clients[len(s.clients)-1], nil
+	}
+	if s.closed {
+		return nil, errors.New("jump host pool is closed")
+	}
+
+	clients, err := p.dialer.dialJumps(ctx, p.jumps, p.timeout)
+	if err != nil {
+		s.err = err
+		return nil, err
+	}
+	s.clients = clients
+	last := clients[len(clients)-1]
+	// 链上任一级断开都会导致最后一级连接关闭，此时丢弃该链，下一台目标主机重新建立
+	go func() {
+		last.Wait()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if len(s.clients) > 0 && s.clients[len(s.clients)-1] == last {
+			closeClients(s.clients)
+			s.clients = nil
+		}
+	}()
+	return last, nil
+}
+
+// Close 关闭所有跳板机链，此后的 dial 返回错误
+func (p *jumpPool) Close() {
+	for _, s := range p.slots {
+		s.mu.Lock()
+		closeClients(s.clients)
+		s.clients = nil
+		s.closed = true
+		s.mu.Unlock()
+	}
+}
@@ -0,0 +1,173 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"gocerery/internal/config"
+	"gocerery/internal/secrets"
+)
+
+// keyStore 按 key_ref 读取 Worker 本地保存的私钥，目录由 Executor.KeyDir 指定
+type keyStore struct {
+	dir string
+}
+
+// resolve 在未直接提供私钥时，把 key_ref 替换为对应文件内容
+func (s keyStore) resolve(auth *authPayload) error {
+	if auth.PrivateKey != "" || auth.KeyRef == "" {
+		return nil
+	}
+	if s.dir == "" {
+		return fmt.Errorf("key_ref %q cannot be resolved: Executor.KeyDir is not configured", auth.KeyRef)
+	}
+	// 只允许引用 KeyDir 内的文件，防止通过 ../ 读取任意路径
+	if !filepath.IsLocal(auth.KeyRef) {
+		return fmt.Errorf("invalid key_ref %q", auth.KeyRef)
+	}
+	data, err := os.ReadFile(filepath.Join(s.dir, auth.KeyRef))
+	if err != nil {
+		return fmt.Errorf("read key_ref %q: %w", auth.KeyRef, err)
+	}
+	auth.PrivateKey = string(data)
+	return nil
+}
+
+// refBindings 密钥引用与主机的绑定：清单主机与跳板机只能使用配置中为其指定的引用，
+// 请求中直接给出的主机只有地址命中 Secrets.AllowedHosts 时才能使用引用，
+// 防止调用方把密钥发给自己控制的 sshd
+type refBindings struct {
+	bound        map[refBinding]bool
+	allowedHosts []string
+}
+
+type refBinding struct {
+	host string
+	port int
+	user string
+	ref  string
+}
+
+func newRefBindings(cfg *config.Config) refBindings {
+	b := refBindings{bound: make(map[refBinding]bool), allowedHosts: cfg.Secrets.AllowedHosts}
+	add := func(host string, port int, user string, refs ...string) {
+		for _, ref := range refs {
+			if ref != "" {
+				b.bound[refBinding{host: host, port: normalizePort(port), user: user, ref: ref}] = true
+			}
+		}
+	}
+	for _, t := range cfg.Targets {
+		add(t.Host, t.Port, t.User, t.PasswordRef, t.KeyRef)
+	}
+	for _, bastion := range cfg.Bastions {
+		add(bastion.Host, bastion.Port, bastion.User, bastion.PasswordRef, bastion.KeyRef)
+	}
+	add(cfg.Bastion.Host, cfg.Bastion.Port, cfg.Bastion.User, cfg.Bastion.PasswordRef, cfg.Bastion.KeyRef)
+	return b
+}
+
+// check 校验端点使用的 password_ref 与带前缀的 key_ref；KeyDir 中的私钥只用于公钥认证，不会泄露给服务端
+func (b refBindings) check(host string, port int, user string, auth authPayload) error {
+	refs := map[string]string{"password_ref": auth.PasswordRef}
+	if secrets.IsRef(auth.KeyRef) {
+		refs["key_ref"] = auth.KeyRef
+	}
+	for field, ref := range refs {
+		if ref == "" || b.allowed(host, port, user, ref) {
+			continue
+		}
+		return fmt.Errorf("%s %q is not bound to %s@%s:%d, use an inventory target or bastion, or add the host to Secrets.AllowedHosts",
+			field, ref, user, host, normalizePort(port))
+	}
+	return nil
+}
+
+func (b refBindings) allowed(host string, port int, user, ref string) bool {
+	if b.bound[refBinding{host: host, port: normalizePort(port), user: user, ref: ref}] {
+		return true
+	}
+	for _, pattern := range b.allowedHosts {
+		if ok, _ := path.Match(pattern, host); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// checkAuth 校验每个端点都有可用的认证方式，且使用的密钥引用已绑定到该主机；
+// 只检查请求内容，不读取密钥，在任务开始前调用
+func (r *Runner) checkAuth(jumps []sshEndpoint, targets []targetPayload) error {
+	for idx, hop := range jumps {
+		if !hop.hasCredential() && !r.useAgent {
+			return fmt.Errorf("jump_hosts[%d] password/password_ref/private_key/key_ref is required", idx)
+		}
+		if err := r.refs.check(hop.Host, hop.Port, hop.User, hop.authPayload); err != nil {
+			return fmt.Errorf("jump_hosts[%d]: %w", idx, err)
+		}
+	}
+	for idx, target := range targets {
+		if !target.hasCredential() && !r.useAgent {
+			return fmt.Errorf("target[%d] password/password_ref/private_key/key_ref is required", idx)
+		}
+		if err := r.refs.check(target.Host, target.Port, target.User, target.authPayload); err != nil {
+			return fmt.Errorf("target[%d]: %w", idx, err)
+		}
+	}
+	return nil
+}
+
+// prepareAuth 解析跳板机与目标主机的 password_ref/key_ref；
+// 在任务的执行上下文中调用，超时为单次操作超时，Vault 请求随任务撤销而取消
+func (r *Runner) prepareAuth(run *taskRun, timeout int, jumps []sshEndpoint, targets []targetPayload) error {
+	ctx, cancel := context.WithTimeout(run.ctx, time.Duration(timeout)*time.Second)
+	defer cancel()
+	cache := make(map[string]string)
+	for idx := range jumps {
+		if err := r.resolveAuth(ctx, cache, &jumps[idx].authPayload); err != nil {
+			return fmt.Errorf("jump_hosts[%d]: %w", idx, err)
+		}
+	}
+	for idx := range targets {
+		if err := r.resolveAuth(ctx, cache, &targets[idx].authPayload); err != nil {
+			return fmt.Errorf("target[%d]: %w", idx, err)
+		}
+	}
+	return nil
+}
+
+// resolveAuth 把 password_ref 与带前缀的 key_ref 替换为密钥内容，不带前缀的 key_ref 仍从 KeyDir 读取；
+// 同一任务内相同的引用只读取一次
+func (r *Runner) resolveAuth(ctx context.Context, cache map[string]string, auth *authPayload) error {
+	lookup := func(ref string) (string, error) {
+		if v, ok := cache[ref]; ok {
+			return v, nil
+		}
+		v, err := r.secrets.Resolve(ctx, ref)
+		if err != nil {
+			return "", err
+		}
+		cache[ref] = v
+		return v, nil
+	}
+	if auth.Password == "" && auth.PasswordRef != "" {
+		v, err := lookup(auth.PasswordRef)
+		if err != nil {
+			return fmt.Errorf("password_ref: %w", err)
+		}
+		auth.Password = v
+	}
+	if auth.PrivateKey == "" && secrets.IsRef(auth.KeyRef) {
+		v, err := lookup(auth.KeyRef)
+		if err != nil {
+			return fmt.Errorf("key_ref: %w", err)
+		}
+		auth.PrivateKey = v
+		return nil
+	}
+	return r.keys.resolve(auth)
+}
@@ -0,0 +1,62 @@
+package worker
+
+import (
+	"testing"
+
+	"gocerery/internal/config"
+)
+
+func TestRefBindingsCheck(t *testing.T) {
+	refs := newRefBindings(&config.Config{
+		Targets: []config.TargetConfig{
+			{Name: "db-1", Host: "10.0.0.21", User: "dba", PasswordRef: "vault:secret/data/ssh/db-1#password"},
+			{Name: "web-1", Host: "10.0.0.31", Port: 2222, User: "deploy", KeyRef: "file:/etc/gocerery/secrets/deploy"},
+		},
+		Bastions: []config.BastionConfig{
+			{Name: "jump", Host: "111.200.213.14", Port: 63525, User: "h3c", PasswordRef: "env:JUMP_PASS"},
+		},
+		Secrets: config.SecretsConfig{AllowedHosts: []string{"192.168.8.*"}},
+	})
+
+	tests := []struct {
+		name    string
+		host    string
+		port    int
+		user    string
+		auth    authPayload
+		wantErr bool
+	}{
+		{name: "inventory target", host: "10.0.0.21", port: 22, user: "dba",
+			auth: authPayload{PasswordRef: "vault:secret/data/ssh/db-1#password"}},
+		{name: "inventory target default port", host: "10.0.0.21", user: "dba",
+			auth: authPayload{PasswordRef: "vault:secret/data/ssh/db-1#password"}},
+		{name: "inventory key ref", host: "10.0.0.31", port: 2222, user: "deploy",
+			auth: authPayload{KeyRef: "file:/etc/gocerery/secrets/deploy"}},
+		{name: "bastion", host: "111.200.213.14", port: 63525, user: "h3c",
+			auth: authPayload{PasswordRef: "env:JUMP_PASS"}},
+		{name: "ad-hoc host", host: "203.0.113.5", port: 22, user: "dba",
+			auth: authPayload{PasswordRef: "vault:secret/data/ssh/db-1#password"}, wantErr: true},
+		{name: "other port", host: "10.0.0.21", port: 2200, user: "dba",
+			auth: authPayload{PasswordRef: "vault:secret/data/ssh/db-1#password"}, wantErr: true},
+		{name: "other user", host: "10.0.0.21", port: 22, user: "root",
+			auth: authPayload{PasswordRef: "vault:secret/data/ssh/db-1#password"}, wantErr: true},
+		{name: "ref of another host", host: "10.0.0.21", port: 22, user: "dba",
+			auth: authPayload{PasswordRef: "env:JUMP_PASS"}, wantErr: true},
+		{name: "prefixed key ref on ad-hoc host", host: "203.0.113.5", port: 22, user: "deploy",
+			auth: authPayload{KeyRef: "file:/etc/gocerery/secrets/deploy"}, wantErr: true},
+		{name: "allowed host", host: "192.168.8.10", port: 22, user: "ops",
+			auth: authPayload{PasswordRef: "env:JUMP_PASS"}},
+		{name: "key dir ref", host: "203.0.113.5", port: 22, user: "deploy",
+			auth: authPayload{KeyRef: "deploy_ed25519"}},
+		{name: "inline password", host: "203.0.113.5", port: 22, user: "deploy",
+			auth: authPayload{Password: "secret"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := refs.check(tt.host, tt.port, tt.user, tt.auth)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package worker
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gocerery/internal/taskstate"
+
+	"github.com/pkg/sftp"
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// errDownloadLimit 单台主机取回的总大小超过 Downloads.MaxSizeMB
+var errDownloadLimit = errors.New("download size limit exceeded (Downloads.MaxSizeMB)")
+
+func (e *nativeExecutor) Download(ctx context.Context, task *downloadTaskPayload, timeout int, report downloadReporter) ([]DownloadResult, error) {
+	if len(task.LocalDirs) != len(task.Targets) {
+		return nil, errors.New("local dirs do not match targets")
+	}
+	pool := e.newJumpPool(task.JumpHosts, len(task.Targets), time.Duration(timeout)*time.Second)
+	defer pool.Close()
+
+	results := make([]DownloadResult, len(task.Targets))
+	forEachTarget(len(task.Targets), e.concurrency, func(idx int) {
+		results[idx] = e.downloadFiles(ctx, pool, task.Targets[idx], task, task.LocalDirs[idx],
+			func(result DownloadResult) { report.report(idx, result) })
+	})
+	return results, nil
+}
+
+// downloadFiles 对应 ssh_downloader.py 中的 download_files：按 remote_paths 依次展开通配，
+// 文件直接下载，目录递归下载；本地按远程绝对路径保存在 localDir 下
+func (e *nativeExecutor) downloadFiles(ctx context.Context, pool *jumpPool, target targetPayload, task *downloadTaskPayload, localDir string, report func(DownloadResult)) (result DownloadResult) {
+	result = newDownloadResult(target)
+	targetName := target.Name
+	if targetName == "" {
+		targetName = target.Host
+	}
+	// 返回前标记为 done 并上报最终结果
+	defer func() {
+		result.State = taskstate.HostDone
+		report(result)
+	}()
+	result.State = taskstate.HostConnecting
+	report(result)
+
+	logx.Infow("[WORKER] starting file download",
+		logx.Field("target", targetName),
+		logx.Field("host", target.Host),
+		logx.Field("remote_paths", task.RemotePaths),
+		logx.Field("local_dir", localDir))
+
+	conn, err := pool.dial(ctx, targetEndpoint(target))
+	if err != nil {
+		result.Success = false
+		result.Error = err.Error()
+		result.ErrorCode = errorCode(err)
+		logx.Errorw("[WORKER] connect target failed",
+			logx.Field("target", targetName),
+			logx.Field("error", err))
+		return result
+	}
+	defer conn.Close()
+	// 传输过程中任务被取消时直接断开连接
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	client, err := sftp.NewClient(conn)
+	if err != nil {
+		result.Success = false
+		result.Error = fmt.Sprintf("open sftp: %v", err)
+		return result
+	}
+	defer client.Close()
+
+	result.State = taskstate.HostRunning
+	report(result)
+
+	// 多个通配可能匹配到同一文件，只下载一次
+	seen := make(map[string]bool)
+	lastReport := time.Now()
+	fetch := func(remote string, size int64) error {
+		if seen[remote] {
+			return nil
+		}
+		seen[remote] = true
+		remaining := task.MaxBytes - result.Bytes
+		if size > remaining {
+			result.record(DownloadedFile{Remote: remote, Path: localRel(remote), Error: errDownloadLimit.Error()})
+			return errDownloadLimit
+		}
+		logx.Debugw("[WORKER] downloading file",
+			logx.Field("target", targetName),
+			logx.Field("remote", remote))
+		// 文件在 Stat 之后可能继续增长，复制时仍按剩余额度截断
+		f := getFile(client, remote, localDir, remaining)
+		result.record(f)
+		if f.Error == errDownloadLimit.Error() {
+			return errDownloadLimit
+		}
+		// 文件较多时按间隔上报，避免每个文件都复制一次结果
+		if time.Since(lastReport) >= progressInterval {
+			lastReport = time.Now()
+			report(result)
+		}
+		return nil
+	}
+
+	var walkErr error
+patterns:
+	for _, pattern := range task.RemotePaths {
+		matches, err := client.Glob(pattern)
+		if err != nil {
+			result.record(DownloadedFile{Remote: pattern, Error: err.Error()})
+			continue
+		}
+		if len(matches) == 0 {
+			result.record(DownloadedFile{Remote: pattern, Error: "no such file or directory"})
+			continue
+		}
+		for _, match := range matches {
+			if walkErr = ctx.Err(); walkErr != nil {
+				break patterns
+			}
+			// 不跟随符号链接：通配匹配到的链接与目录内的链接一样跳过，直接指定的链接报错
+			info, err := client.Lstat(match)
+			if err != nil {
+				result.record(DownloadedFile{Remote: match, Path: localRel(match), Error: err.Error()})
+				continue
+			}
+			if info.Mode()&os.ModeSymlink != 0 {
+				if match == pattern {
+					result.record(DownloadedFile{Remote: match, Path: localRel(match), Error: "symbolic link is not followed"})
+				}
+				continue
+			}
+			if info.Mode().IsRegular() {
+				if walkErr = fetch(match, info.Size()); walkErr != nil {
+					break patterns
+				}
+				continue
+			}
+			if !info.IsDir() {
+				continue
+			}
+			walker := client.Walk(match)
+			for walker.Step() {
+				if walkErr = ctx.Err(); walkErr != nil {
+					break patterns
+				}
+				if err := walker.Err(); err != nil {
+					result.record(DownloadedFile{Remote: walker.Path(), Path: localRel(walker.Path()), Error: err.Error()})
+					continue
+				}
+				if !walker.Stat().Mode().IsRegular() {
+					continue
+				}
+				if walkErr = fetch(walker.Path(), walker.Stat().Size()); walkErr != nil {
+					break patterns
+				}
+			}
+		}
+	}
+	if walkErr != nil {
+		result.Success = false
+		result.Error = walkErr.Error()
+	}
+
+	if len(result.FailedFiles) > 0 {
+		result.Success = false
+		if result.Error == "" {
+			result.Error = fmt.Sprintf("%d file(s) failed to download", len(result.FailedFiles))
+		}
+	}
+
+	logx.Infow("[WORKER] file download completed",
+		logx.Field("target", targetName),
+		logx.Field("success", result.Success),
+		logx.Field("files", len(result.Files)),
+		logx.Field("bytes", result.Bytes),
+		logx.Field("failed", len(result.FailedFiles)))
+	return result
+}
+
+// localRel 远程路径在主机目录下的相对路径；先 Clean 为绝对路径，保证不会越出主机目录
+func localRel(remote string) string {
+	return strings.TrimPrefix(path.Clean("/"+remote), "/")
+}
+
+// getFile 把单个远程文件保存到 localDir 下，同时计算内容的 sha256，并保留远程的修改时间；
+// 内容超过 limit 字节时删除已写入的部分并返回 errDownloadLimit
+func getFile(client *sftp.Client, remoteFile, localDir string, limit int64) (f DownloadedFile) {
+	f = DownloadedFile{Remote: remoteFile, Path: localRel(remoteFile)}
+	start := time.Now()
+	defer func() {
+		f.DurationMs = time.Since(start).Milliseconds()
+	}()
+
+	src, err := client.Open(remoteFile)
+	if err != nil {
+		f.Error = err.Error()
+		return f
+	}
+	defer src.Close()
+
+	localFile := filepath.Join(localDir, filepath.FromSlash(f.Path))
+	if err := os.MkdirAll(filepath.Dir(localFile), 0o700); err != nil {
+		f.Error = err.Error()
+		return f
+	}
+	dst, err := os.OpenFile(localFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		f.Error = err.Error()
+		return f
+	}
+	hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(dst, hash), io.LimitReader(src, limit+1))
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && n > limit {
+		err = errDownloadLimit
+	}
+	if err != nil {
+		os.Remove(localFile)
+		f.Error = err.Error()
+		return f
+	}
+	f.Bytes = n
+	f.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	if info, err := src.Stat(); err == nil {
+		os.Chtimes(localFile, info.ModTime(), info.ModTime())
+	}
+	return f
+}
@@ -0,0 +1,131 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"gocerery/internal/taskstate"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// nativeExecutor 基于 golang.org/x/crypto/ssh 的执行器，无需 Python 运行时
+type nativeExecutor struct {
+	concurrency  int
+	jumpPoolSize int
+	dialer       sshDialer
+}
+
+func (e *nativeExecutor) Execute(ctx context.Context, task *taskPayload, timeout int, report hostReporter) ([]HostResult, error) {
+	pool := e.newJumpPool(task.JumpHosts, len(task.Targets), time.Duration(timeout)*time.Second)
+	defer pool.Close()
+
+	results := make([]HostResult, len(task.Targets))
+	forEachTarget(len(task.Targets), e.concurrency, func(idx int) {
+		results[idx] = e.runCommands(ctx, pool, task.Targets[idx], task, time.Duration(timeout)*time.Second,
+			func(result HostResult) { report.report(idx, result) },
+			func(line outputLine) { report.output(idx, line) })
+	})
+	return results, nil
+}
+
+// newJumpPool 按 Executor.JumpPoolSize 创建任务内共享的跳板机连接池，连接数不超过实际并发数
+func (e *nativeExecutor) newJumpPool(jumps []sshEndpoint, targets int, timeout time.Duration) *jumpPool {
+	size := min(e.jumpPoolSize, effectiveConcurrency(e.concurrency, targets))
+	return newJumpPool(e.dialer, jumps, size, timeout)
+}
+
+// runCommands 对应 ssh_executor.py 中的 run_commands：命令失败后按 on_error 决定是否继续
+func (e *nativeExecutor) runCommands(ctx context.Context, pool *jumpPool, target targetPayload, task *taskPayload, timeout time.Duration, report func(HostResult), output func(outputLine)) (result HostResult) {
+	result = newHostResult(target)
+	targetName := target.Name
+	if targetName == "" {
+		targetName = target.Host
+	}
+	// 返回前标记为 done 并上报最终结果
+	defer func() {
+		result.State = taskstate.HostDone
+		result.CurrentCommand = 0
+		report(result)
+	}()
+	result.State = taskstate.HostConnecting
+	report(result)
+
+	logx.Infow("[WORKER] starting command execution",
+		logx.Field("target", targetName),
+		logx.Field("host", target.Host),
+		logx.Field("on_error", task.OnError))
+
+	client, err := pool.dial(ctx, targetEndpoint(target))
+	if err != nil {
+		result.Success = false
+		result.Error = err.Error()
+		result.ErrorCode = errorCode(err)
+		logx.Errorw("[WORKER] connect target failed",
+			logx.Field("target", targetName),
+			logx.Field("error", err))
+		return result
+	}
+	defer client.Close()
+
+	stopOnError := task.OnError != OnErrorContinue && task.OnError != OnErrorIgnore
+	for i, command := range task.Commands {
+		logx.Infow("[WORKER] executing command",
+			logx.Field("target", targetName),
+			logx.Field("index", i+1),
+			logx.Field("total", len(task.Commands)))
+
+		result.State = taskstate.HostRunning
+		result.CurrentCommand = i + 1
+		report(result)
+
+		cmd := CommandResult{Command: command, StartedAt: time.Now().UTC()}
+		stdout, stderr, exitCode, err := runCommand(ctx, client, command, timeout, func(stream, line string) {
+			output(outputLine{Command: i + 1, Stream: stream, Line: line})
+		})
+		cmd.FinishedAt = time.Now().UTC()
+		cmd.DurationMs = cmd.FinishedAt.Sub(cmd.StartedAt).Milliseconds()
+		cmd.Stdout, cmd.Stderr, cmd.ExitCode = stdout, stderr, exitCode
+		cmd.Success = err == nil && task.exitCodeAllowed(i, exitCode)
+		if err != nil {
+			cmd.Error = err.Error()
+		}
+		result.Commands = append(result.Commands, cmd)
+
+		result.Stdout += stdout
+		result.Stderr += stderr
+		result.ExitCode = exitCode
+		if err != nil {
+			// 超时、会话异常等错误不受 ignore 影响
+			result.Success = false
+			if result.Error == "" {
+				result.Error = err.Error()
+			}
+			logx.Errorw("[WORKER] command error",
+				logx.Field("target", targetName),
+				logx.Field("index", i+1),
+				logx.Field("error", err))
+			if stopOnError || ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		if !cmd.Success {
+			logx.Errorw("[WORKER] command failed",
+				logx.Field("target", targetName),
+				logx.Field("index", i+1),
+				logx.Field("exit_code", exitCode))
+			if task.OnError != OnErrorIgnore {
+				result.Success = false
+			}
+			if stopOnError {
+				break
+			}
+		}
+	}
+
+	logx.Infow("[WORKER] command execution completed",
+		logx.Field("target", targetName),
+		logx.Field("success", result.Success))
+	return result
+}
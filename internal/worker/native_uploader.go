@@ -0,0 +1,247 @@
+package worker
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"gocerery/internal/pathfilter"
+	"gocerery/internal/taskstate"
+
+	"github.com/pkg/sftp"
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+func (e *nativeExecutor) Upload(ctx context.Context, task *uploadTaskPayload, timeout int, report uploadReporter) ([]UploadResult, error) {
+	info, err := os.Stat(task.LocalPath)
+	if err != nil {
+		return nil, fmt.Errorf("local path does not exist: %s", task.LocalPath)
+	}
+
+	pool := e.newJumpPool(task.JumpHosts, len(task.Targets), time.Duration(timeout)*time.Second)
+	defer pool.Close()
+
+	results := make([]UploadResult, len(task.Targets))
+	forEachTarget(len(task.Targets), e.concurrency, func(idx int) {
+		results[idx] = e.uploadFiles(ctx, pool, task.Targets[idx], task, info.IsDir(), time.Duration(timeout)*time.Second,
+			func(result UploadResult) { report.report(idx, result) })
+	})
+	return results, nil
+}
+
+// uploadFiles 对应 ssh_uploader.py 中的 upload_files：单文件直接上传，目录递归上传并保持结构；
+// 每个文件按 upload_mode 决定跳过、覆盖或同步
+func (e *nativeExecutor) uploadFiles(ctx context.Context, pool *jumpPool, target targetPayload, task *uploadTaskPayload, isDir bool, timeout time.Duration, report func(UploadResult)) (result UploadResult) {
+	localPath, remotePath := task.LocalPath, task.RemotePath
+	result = newUploadResult(target)
+	targetName := target.Name
+	if targetName == "" {
+		targetName = target.Host
+	}
+	// 返回前标记为 done 并上报最终结果
+	defer func() {
+		result.State = taskstate.HostDone
+		report(result)
+	}()
+	result.State = taskstate.HostConnecting
+	report(result)
+
+	logx.Infow("[WORKER] starting file upload",
+		logx.Field("target", targetName),
+		logx.Field("host", target.Host),
+		logx.Field("local_path", localPath),
+		logx.Field("remote_path", remotePath),
+		logx.Field("upload_mode", task.UploadMode))
+
+	conn, err := pool.dial(ctx, targetEndpoint(target))
+	if err != nil {
+		result.Success = false
+		result.Error = err.Error()
+		result.ErrorCode = errorCode(err)
+		logx.Errorw("[WORKER] connect target failed",
+			logx.Field("target", targetName),
+			logx.Field("error", err))
+		return result
+	}
+	defer conn.Close()
+	// 传输过程中任务被取消时直接断开连接
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	client, err := sftp.NewClient(conn)
+	if err != nil {
+		result.Success = false
+		result.Error = fmt.Sprintf("open sftp: %v", err)
+		return result
+	}
+	defer client.Close()
+
+	result.State = taskstate.HostRunning
+	report(result)
+	syncer := fileSyncer{ctx: ctx, timeout: timeout, conn: conn, client: client, mode: task.UploadMode, compare: task.SyncCompare, placement: task.filePlacement}
+
+	if err := client.MkdirAll(remotePath); err != nil {
+		result.Success = false
+		result.Error = fmt.Sprintf("failed to create remote directory: %v", err)
+		return result
+	}
+
+	if !isDir {
+		remoteFile := path.Join(remotePath, filepath.Base(localPath))
+		syncer.upload(&result, localPath, remoteFile)
+	} else {
+		// 参数已在解析任务时校验，这里不会出错
+		filter, err := pathfilter.New(task.Include, task.Exclude)
+		if err != nil {
+			result.Success = false
+			result.Error = err.Error()
+			return result
+		}
+		// 配置了 include 时目录在上传第一个文件前才创建，避免留下只含被过滤文件的空目录
+		made := map[string]bool{remotePath: true}
+		mkdir := func(dir string) error {
+			if made[dir] {
+				return nil
+			}
+			if err := client.MkdirAll(dir); err != nil {
+				return err
+			}
+			made[dir] = true
+			return nil
+		}
+		lastReport := time.Now()
+		walkErr := filepath.WalkDir(localPath, func(p string, d fs.DirEntry, err error) error {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			rel, _ := filepath.Rel(localPath, p)
+			remote := path.Join(remotePath, filepath.ToSlash(rel))
+			if err != nil {
+				result.record(FileTransfer{Local: p, Remote: remote, Error: err.Error()})
+				if d != nil && d.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			if d.IsDir() {
+				if rel == "." {
+					return nil
+				}
+				if filter.Skip(filepath.ToSlash(rel), true) {
+					result.Filtered++
+					return fs.SkipDir
+				}
+				if len(task.Include) > 0 {
+					return nil
+				}
+				if err := mkdir(remote); err != nil {
+					result.record(FileTransfer{Local: p, Remote: remote, Error: fmt.Sprintf("failed to create directory: %v", err)})
+					return fs.SkipDir
+				}
+				return nil
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+			if filter.Skip(filepath.ToSlash(rel), false) {
+				result.Filtered++
+				return nil
+			}
+			if err := mkdir(path.Dir(remote)); err != nil {
+				result.record(FileTransfer{Local: p, Remote: remote, Error: fmt.Sprintf("failed to create directory: %v", err)})
+				return nil
+			}
+			logx.Debugw("[WORKER] uploading file",
+				logx.Field("target", targetName),
+				logx.Field("local", p),
+				logx.Field("remote", remote))
+			syncer.upload(&result, p, remote)
+			// 文件较多时按间隔上报，避免每个文件都复制一次结果
+			if time.Since(lastReport) >= progressInterval {
+				lastReport = time.Now()
+				report(result)
+			}
+			return nil
+		})
+		if walkErr != nil {
+			result.Success = false
+			result.Error = walkErr.Error()
+		}
+	}
+
+	if len(result.FailedFiles) > 0 {
+		result.Success = false
+		if result.Error == "" {
+			result.Error = fmt.Sprintf("%d file(s) failed to upload", len(result.FailedFiles))
+		}
+	}
+
+	logx.Infow("[WORKER] file upload completed",
+		logx.Field("target", targetName),
+		logx.Field("success", result.Success),
+		logx.Field("transferred", result.Transferred),
+		logx.Field("skipped", result.Skipped),
+		logx.Field("filtered", result.Filtered),
+		logx.Field("verified", result.Verified),
+		logx.Field("failed", len(result.FailedFiles)))
+	return result
+}
+
+// record 按是否出错把传输记录归入 uploaded_files 或 failed_files
+func (r *UploadResult) record(ft FileTransfer) {
+	if ft.Error != "" {
+		r.FailedFiles = append(r.FailedFiles, ft)
+		r.Success = false
+		return
+	}
+	r.UploadedFiles = append(r.UploadedFiles, ft)
+	r.Transferred++
+}
+
+// putFile 把单个本地文件写入远端路径（存在则覆盖），同时计算内容的 sha256；
+// prepare 在打开远端文件后、写入内容前调用，用于先收紧权限
+func putFile(client *sftp.Client, localFile, remoteFile string, prepare func(dst *sftp.File) error) (ft FileTransfer) {
+	ft = FileTransfer{Local: localFile, Remote: remoteFile}
+	start := time.Now()
+	defer func() {
+		ft.DurationMs = time.Since(start).Milliseconds()
+	}()
+
+	src, err := os.Open(localFile)
+	if err != nil {
+		ft.Error = err.Error()
+		return ft
+	}
+	defer src.Close()
+
+	dst, err := client.OpenFile(remoteFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		ft.Error = err.Error()
+		return ft
+	}
+	if prepare != nil {
+		if err := prepare(dst); err != nil {
+			dst.Close()
+			ft.Error = err.Error()
+			return ft
+		}
+	}
+	hash := sha256.New()
+	n, err := io.Copy(dst, io.TeeReader(src, hash))
+	ft.Bytes = n
+	ft.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		ft.Error = err.Error()
+	}
+	return ft
+}
@@ -0,0 +1,97 @@
+package worker
+
+import (
+	"sync"
+	"time"
+
+	"gocerery/internal/taskstate"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// progressInterval 进度写入 Backend 的最小间隔
+const progressInterval = 500 * time.Millisecond
+
+// taskProgress 汇总各主机的最新结果，按间隔以 PROGRESS 状态写入 Backend，
+// 查询接口据此返回部分结果
+type taskProgress[T any] struct {
+	state  *taskstate.Store
+	taskID string
+
+	mu      sync.Mutex
+	results []T
+	dirty   bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// newTaskProgress 写入 STARTED 状态（所有主机为 queued）并启动后台写入
+func newTaskProgress[T any](state *taskstate.Store, taskID string, initial []T) *taskProgress[T] {
+	p := &taskProgress[T]{
+		state:   state,
+		taskID:  taskID,
+		results: initial,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	if state == nil || taskID == "" {
+		close(p.done)
+		return p
+	}
+	p.store(taskstate.StatusStarted, initial)
+	go p.loop()
+	return p
+}
+
+// update 记录第 idx 台主机的最新结果，实际写入由后台按间隔完成
+func (p *taskProgress[T]) update(idx int, result T) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if idx >= 0 && idx < len(p.results) {
+		p.results[idx] = result
+		p.dirty = true
+	}
+}
+
+// close 停止后台写入；最终结果由 gocelery 以 SUCCESS 写入，必须在其之前调用
+func (p *taskProgress[T]) close() {
+	select {
+	case <-p.done:
+		return
+	default:
+	}
+	close(p.stop)
+	<-p.done
+}
+
+func (p *taskProgress[T]) loop() {
+	defer close(p.done)
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			p.mu.Lock()
+			if !p.dirty {
+				p.mu.Unlock()
+				continue
+			}
+			snapshot := append([]T(nil), p.results...)
+			p.dirty = false
+			p.mu.Unlock()
+			p.store(taskstate.StatusProgress, snapshot)
+		}
+	}
+}
+
+func (p *taskProgress[T]) store(status string, results []T) {
+	if err := p.state.SetStatus(p.taskID, status, results); err != nil {
+		logx.Errorw("[WORKER] failed to store task progress",
+			logx.Field("task_id", p.taskID),
+			logx.Field("status", status),
+			logx.Field("error", err))
+	}
+}
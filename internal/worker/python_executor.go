@@ -0,0 +1,351 @@
+package worker
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gocerery/internal/config"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// pythonExecutor 通过 python3 子进程调用 Paramiko 脚本，作为原生实现的兜底方案
+type pythonExecutor struct {
+	scriptPath         string
+	uploadScriptPath   string
+	downloadScriptPath string
+	concurrency        int
+	jumpPoolSize       int
+	useAgent           bool
+	hostKeyPolicy      string
+	knownHostsFile     string
+	logCfg             *config.LogConfig
+}
+
+func (e *pythonExecutor) Execute(ctx context.Context, task *taskPayload, timeout int, report hostReporter) ([]HostResult, error) {
+	if e.scriptPath == "" {
+		logx.Errorw("[WORKER] executor script path is empty")
+		return nil, errors.New("executor script path is empty")
+	}
+
+	input, err := json.Marshal(scriptInput{
+		JumpHosts:        scriptJumpHosts(task.JumpHosts),
+		Targets:          scriptTargets(task.Targets),
+		Commands:         task.Commands,
+		AllowedExitCodes: task.AllowedExitCodes,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("encode executor input: %w", err)
+	}
+
+	args := []string{
+		e.scriptPath,
+		"--payload-stdin",
+		"--on-error", task.OnError,
+	}
+	args = append(args, e.commonArgs(len(task.Targets), timeout, "ssh_executor.log")...)
+
+	logx.Infow("[WORKER] executing script", logx.Field("script", e.scriptPath))
+	stdout, err := e.run(ctx, args, input, "executor", func(ev scriptEvent) {
+		if ev.Stream != "" {
+			report.output(ev.Index, outputLine{Command: ev.Command, Stream: ev.Stream, Line: ev.Line})
+			return
+		}
+		var result HostResult
+		if err := json.Unmarshal(ev.Result, &result); err == nil {
+			report.report(ev.Index, result)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var results []HostResult
+	if err := json.Unmarshal(stdout, &results); err != nil {
+		logx.Errorw("[WORKER] failed to decode executor output",
+			logx.Field("error", err),
+			logx.Field("raw_stdout", string(stdout)))
+		return nil, fmt.Errorf("decode executor output: %w", err)
+	}
+	return results, nil
+}
+
+func (e *pythonExecutor) Upload(ctx context.Context, task *uploadTaskPayload, timeout int, report uploadReporter) ([]UploadResult, error) {
+	if e.uploadScriptPath == "" {
+		logx.Errorw("[WORKER] upload script path is empty")
+		return nil, errors.New("upload script path is empty")
+	}
+
+	input, err := json.Marshal(scriptInput{
+		JumpHosts: scriptJumpHosts(task.JumpHosts),
+		Targets:   scriptTargets(task.Targets),
+		Include:   task.Include,
+		Exclude:   task.Exclude,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("encode upload executor input: %w", err)
+	}
+
+	args := []string{
+		e.uploadScriptPath,
+		"--payload-stdin",
+		"--local-path", task.LocalPath,
+		"--remote-path", task.RemotePath,
+		"--upload-mode", task.UploadMode,
+	}
+	if task.SyncCompare != "" {
+		args = append(args, "--sync-compare", task.SyncCompare)
+	}
+	if task.Mode != "" {
+		args = append(args, "--mode", task.Mode)
+	}
+	if task.Owner != "" {
+		args = append(args, "--owner", task.Owner)
+	}
+	if task.Group != "" {
+		args = append(args, "--group", task.Group)
+	}
+	if task.Atomic {
+		args = append(args, "--atomic")
+	}
+	if task.BackupExisting {
+		args = append(args, "--backup-existing")
+	}
+	args = append(args, e.commonArgs(len(task.Targets), timeout, "ssh_uploader.log")...)
+
+	logx.Infow("[WORKER] executing upload script", logx.Field("script", e.uploadScriptPath))
+	stdout, err := e.run(ctx, args, input, "upload", func(ev scriptEvent) {
+		var result UploadResult
+		if err := json.Unmarshal(ev.Result, &result); err == nil {
+			report.report(ev.Index, result)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var results []UploadResult
+	if err := json.Unmarshal(stdout, &results); err != nil {
+		logx.Errorw("[WORKER] failed to decode upload executor output",
+			logx.Field("error", err),
+			logx.Field("raw_stdout", string(stdout)))
+		return nil, fmt.Errorf("decode upload executor output: %w", err)
+	}
+	return results, nil
+}
+
+func (e *pythonExecutor) Download(ctx context.Context, task *downloadTaskPayload, timeout int, report downloadReporter) ([]DownloadResult, error) {
+	if e.downloadScriptPath == "" {
+		logx.Errorw("[WORKER] download script path is empty")
+		return nil, errors.New("download script path is empty")
+	}
+	if len(task.LocalDirs) != len(task.Targets) {
+		return nil, errors.New("local dirs do not match targets")
+	}
+
+	targets := scriptTargets(task.Targets)
+	for i := range targets {
+		targets[i]["local_dir"] = task.LocalDirs[i]
+	}
+	input, err := json.Marshal(scriptInput{
+		JumpHosts:   scriptJumpHosts(task.JumpHosts),
+		Targets:     targets,
+		RemotePaths: task.RemotePaths,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("encode download executor input: %w", err)
+	}
+
+	args := []string{
+		e.downloadScriptPath,
+		"--payload-stdin",
+		"--max-bytes", strconv.FormatInt(task.MaxBytes, 10),
+	}
+	args = append(args, e.commonArgs(len(task.Targets), timeout, "ssh_downloader.log")...)
+
+	logx.Infow("[WORKER] executing download script", logx.Field("script", e.downloadScriptPath))
+	stdout, err := e.run(ctx, args, input, "download", func(ev scriptEvent) {
+		var result DownloadResult
+		if err := json.Unmarshal(ev.Result, &result); err == nil {
+			report.report(ev.Index, result)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var results []DownloadResult
+	if err := json.Unmarshal(stdout, &results); err != nil {
+		logx.Errorw("[WORKER] failed to decode download executor output",
+			logx.Field("error", err),
+			logx.Field("raw_stdout", string(stdout)))
+		return nil, fmt.Errorf("decode download executor output: %w", err)
+	}
+	return results, nil
+}
+
+// commonArgs 构建各脚本共用的并发、跳板机连接池、超时与日志参数
+func (e *pythonExecutor) commonArgs(targets, timeout int, logName string) []string {
+	logLevel := "INFO"
+	if e.logCfg != nil && e.logCfg.Level != "" {
+		logLevel = strings.ToUpper(e.logCfg.Level)
+	}
+	concurrency := effectiveConcurrency(e.concurrency, targets)
+	args := []string{
+		"--concurrency", strconv.Itoa(concurrency),
+		"--jump-pool-size", strconv.Itoa(max(1, min(e.jumpPoolSize, concurrency))),
+		"--timeout", strconv.Itoa(timeout),
+		"--log-level", logLevel,
+		"--events-fd", strconv.Itoa(eventsFD),
+		"--host-key-policy", e.hostKeyPolicy,
+		"--known-hosts", e.knownHostsFile,
+	}
+	if e.useAgent {
+		args = append(args, "--allow-agent")
+	}
+	if e.logCfg != nil && e.logCfg.Mode == "file" && e.logCfg.Path != "" {
+		args = append(args, "--log-file", filepath.Join(e.logCfg.Path, logName))
+	}
+	return args
+}
+
+// eventsFD 子进程中进度事件管道的文件描述符（ExtraFiles[0]）
+const eventsFD = 3
+
+// maxEventSize 单条进度事件的最大长度（包含累计的输出）
+const maxEventSize = 16 << 20
+
+// scriptEvent 脚本通过 --events-fd 逐行输出的事件：
+// 进度事件带 state/result，输出事件带 command/stream/line
+type scriptEvent struct {
+	Index   int             `json:"index"`
+	State   string          `json:"state"`
+	Result  json.RawMessage `json:"result"`
+	Command int             `json:"command"`
+	Stream  string          `json:"stream"`
+	Line    string          `json:"line"`
+}
+
+// scriptInput 经 stdin 传给脚本的跳板机、目标主机与命令（或下载路径）。
+// 凭据与命令不放在 argv 中，避免同机其他用户通过 ps 或 /proc/<pid>/cmdline 看到
+type scriptInput struct {
+	JumpHosts        []map[string]interface{} `json:"jump_hosts"`
+	Targets          []map[string]interface{} `json:"targets"`
+	Commands         []string                 `json:"commands,omitempty"`
+	AllowedExitCodes [][]int                  `json:"allowed_exit_codes,omitempty"`
+	RemotePaths      []string                 `json:"remote_paths,omitempty"`
+	Include          []string                 `json:"include,omitempty"`
+	Exclude          []string                 `json:"exclude,omitempty"`
+}
+
+// run 执行脚本并返回 stdout，input 写入脚本的 stdin，kind 仅用于日志区分 executor/upload/download；
+// 执行期间从事件管道读取进度并回调 onEvent
+func (e *pythonExecutor) run(ctx context.Context, args []string, input []byte, kind string, onEvent func(scriptEvent)) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "python3", args...)
+	cmd.Stdin = bytes.NewReader(input)
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	eventsR, eventsW, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("create events pipe: %w", err)
+	}
+	defer eventsR.Close()
+	cmd.ExtraFiles = []*os.File{eventsW}
+
+	logx.Infow("[WORKER] starting script execution...", logx.Field("kind", kind))
+	if err := cmd.Start(); err != nil {
+		eventsW.Close()
+		return nil, fmt.Errorf("%s script failed: %w", kind, err)
+	}
+	// 子进程持有写端，父进程关闭后脚本退出时读端才能收到 EOF
+	eventsW.Close()
+	eventsDone := make(chan struct{})
+	go func() {
+		defer close(eventsDone)
+		scanner := bufio.NewScanner(eventsR)
+		scanner.Buffer(make([]byte, 64*1024), maxEventSize)
+		for scanner.Scan() {
+			var ev scriptEvent
+			if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+				logx.Errorw("[WORKER] invalid script event", logx.Field("kind", kind), logx.Field("error", err))
+				continue
+			}
+			onEvent(ev)
+		}
+		if err := scanner.Err(); err != nil {
+			logx.Errorw("[WORKER] read script events failed", logx.Field("kind", kind), logx.Field("error", err))
+			// 继续读完管道，避免脚本写事件时阻塞
+			io.Copy(io.Discard, eventsR)
+		}
+	}()
+
+	err = cmd.Wait()
+	<-eventsDone
+	if err != nil {
+		logx.Errorw("[WORKER] script execution failed",
+			logx.Field("kind", kind),
+			logx.Field("error", err),
+			logx.Field("stderr", stderr.String()))
+		return nil, fmt.Errorf("%s script failed: %w", kind, err)
+	}
+	logx.Infow("[WORKER] script execution completed",
+		logx.Field("kind", kind),
+		logx.Field("stdout_length", stdout.Len()))
+	if stdout.Len() > 0 {
+		// 打印原始输出（前 500 个字符）用于调试
+		stdoutStr := stdout.String()
+		if len(stdoutStr) > 500 {
+			logx.Debugw("[WORKER] raw stdout (truncated)", logx.Field("stdout", stdoutStr[:500]))
+		} else {
+			logx.Debugw("[WORKER] raw stdout", logx.Field("stdout", stdoutStr))
+		}
+	}
+	if stderr.Len() > 0 {
+		logx.Errorw("[WORKER] script stderr", logx.Field("stderr", stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// scriptEndpoint 转为脚本使用的 JSON 结构，key_ref 已在 Runner 中解析为 private_key
+func scriptEndpoint(ep sshEndpoint) map[string]interface{} {
+	return map[string]interface{}{
+		"host":                   ep.Host,
+		"port":                   ep.Port,
+		"user":                   ep.User,
+		"password":               ep.Password,
+		"private_key":            ep.PrivateKey,
+		"private_key_passphrase": ep.PrivateKeyPassphrase,
+		"host_key_fingerprint":   ep.HostKeyFingerprint,
+	}
+}
+
+func scriptJumpHosts(jumps []sshEndpoint) []map[string]interface{} {
+	result := make([]map[string]interface{}, 0, len(jumps))
+	for _, ep := range jumps {
+		result = append(result, scriptEndpoint(ep))
+	}
+	return result
+}
+
+func scriptTargets(targets []targetPayload) []map[string]interface{} {
+	result := make([]map[string]interface{}, 0, len(targets))
+	for _, t := range targets {
+		target := scriptEndpoint(targetEndpoint(t))
+		target["name"] = t.Name
+		result = append(result, target)
+	}
+	return result
+}
@@ -0,0 +1,113 @@
+package worker
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"gocerery/internal/taskstate"
+
+	"github.com/gocelery/gocelery"
+	"github.com/gomodule/redigo/redis"
+)
+
+// fakeRedis 只实现 taskstate 用到的 GET/SETEX/EXISTS/PUBLISH，供测试读写任务状态
+type fakeRedis struct {
+	mu   sync.Mutex
+	data map[string]string
+}
+
+func startFakeRedis(t *testing.T) (*fakeRedis, *gocelery.RedisCeleryBackend) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	f := &fakeRedis{data: map[string]string{}}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	pool := &redis.Pool{Dial: func() (redis.Conn, error) { return redis.Dial("tcp", ln.Addr().String()) }}
+	t.Cleanup(func() { pool.Close() })
+	return f, gocelery.NewRedisBackend(pool)
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		var reply string
+		switch strings.ToUpper(args[0]) {
+		case "GET":
+			if v, ok := f.data[args[1]]; ok {
+				reply = fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)
+			} else {
+				reply = "$-1\r\n"
+			}
+		case "SETEX":
+			f.data[args[1]] = args[3]
+			reply = "+OK\r\n"
+		case "EXISTS":
+			_, ok := f.data[args[1]]
+			reply = ":" + strconv.Itoa(map[bool]int{false: 0, true: 1}[ok]) + "\r\n"
+		case "PUBLISH":
+			reply = ":0\r\n"
+		default:
+			reply = "-ERR unknown command " + args[0] + "\r\n"
+		}
+		f.mu.Unlock()
+		if _, err := io.WriteString(conn, reply); err != nil {
+			return
+		}
+	}
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("bad command header %q", line)
+	}
+	args := make([]string, n)
+	for i := range args {
+		if line, err = r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "$")))
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func newTestStateStore(t *testing.T) (*taskstate.Store, *gocelery.RedisCeleryBackend) {
+	t.Helper()
+	_, backend := startFakeRedis(t)
+	return taskstate.New(backend), backend
+}
@@ -0,0 +1,128 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"gocerery/internal/taskstate"
+
+	"github.com/gocelery/gocelery"
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// taskIDKwarg Worker 从 Celery 消息注入到 kwargs 中的任务 ID
+const taskIDKwarg = "task_id"
+
+// revokePollInterval 执行过程中检查撤销标记的间隔
+const revokePollInterval = time.Second
+
+// errTaskRevoked 任务被撤销；返回错误可阻止 gocelery 以 SUCCESS 覆盖 REVOKED 状态
+var errTaskRevoked = errors.New("task revoked")
+
+// taskIDBroker 取出任务消息时把消息 ID 写入 kwargs，
+// gocelery 的 CeleryTask 接口本身拿不到任务 ID
+type taskIDBroker struct {
+	gocelery.CeleryBroker
+}
+
+func (b taskIDBroker) GetTaskMessage() (*gocelery.TaskMessage, error) {
+	msg, err := b.CeleryBroker.GetTaskMessage()
+	if err != nil || msg == nil {
+		return msg, err
+	}
+	if msg.Kwargs == nil {
+		msg.Kwargs = map[string]interface{}{}
+	}
+	msg.Kwargs[taskIDKwarg] = msg.ID
+	return msg, nil
+}
+
+// taskRun 单次任务执行的上下文，任务被撤销时取消 ctx 以终止执行器
+type taskRun struct {
+	id      string
+	ctx     context.Context
+	cancel  context.CancelFunc
+	revoked atomic.Bool
+}
+
+// startRun 检查撤销标记并在后台轮询；任务开始前已被撤销时直接返回 errTaskRevoked
+func (r *Runner) startRun(payload map[string]interface{}) (*taskRun, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	run := &taskRun{id: stringValue(payload[taskIDKwarg]), ctx: ctx, cancel: cancel}
+	if r.state == nil || run.id == "" {
+		return run, nil
+	}
+
+	revoked, err := r.state.IsRevoked(run.id)
+	if err != nil {
+		logx.Errorw("[WORKER] failed to check revoke flag", logx.Field("task_id", run.id), logx.Field("error", err))
+	}
+	if revoked {
+		cancel()
+		logx.Infow("[WORKER] task revoked before start, skipping", logx.Field("task_id", run.id))
+		return nil, r.markRevoked(run.id, nil)
+	}
+
+	go r.watchRevoke(run)
+	return run, nil
+}
+
+func (r *Runner) watchRevoke(run *taskRun) {
+	ticker := time.NewTicker(revokePollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-run.ctx.Done():
+			return
+		case <-ticker.C:
+			revoked, err := r.state.IsRevoked(run.id)
+			if err != nil {
+				logx.Errorw("[WORKER] failed to check revoke flag", logx.Field("task_id", run.id), logx.Field("error", err))
+				continue
+			}
+			if revoked {
+				logx.Infow("[WORKER] task revoked, stopping executor", logx.Field("task_id", run.id))
+				run.revoked.Store(true)
+				run.cancel()
+				return
+			}
+		}
+	}
+}
+
+// finishRun 停止轮询；任务已被撤销时写入 REVOKED 状态及已有的部分结果
+func (r *Runner) finishRun(run *taskRun, results interface{}) error {
+	run.cancel()
+	if r.state == nil || run.id == "" {
+		return nil
+	}
+	revoked := run.revoked.Load()
+	if !revoked {
+		// 执行结束与下一次轮询之间被撤销
+		revoked, _ = r.state.IsRevoked(run.id)
+	}
+	if !revoked {
+		return nil
+	}
+	return r.markRevoked(run.id, results)
+}
+
+func (r *Runner) markRevoked(taskID string, results interface{}) error {
+	if err := r.state.SetStatus(taskID, taskstate.StatusRevoked, results); err != nil {
+		logx.Errorw("[WORKER] failed to store revoked status", logx.Field("task_id", taskID), logx.Field("error", err))
+	}
+	return errTaskRevoked
+}
+
+// markFailed 执行失败时写入 FAILURE 状态及已有的部分结果；
+// gocelery 在任务返回错误时不写结果，否则状态会一直停留在 STARTED/PROGRESS
+func (r *Runner) markFailed(taskID string, results interface{}) {
+	if r.state == nil || taskID == "" {
+		return
+	}
+	if err := r.state.SetStatus(taskID, taskstate.StatusFailure, results); err != nil {
+		logx.Errorw("[WORKER] failed to store failure status", logx.Field("task_id", taskID), logx.Field("error", err))
+	}
+}
@@ -0,0 +1,313 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// sshEndpoint SSH 登录端点（跳板机或目标主机）
+type sshEndpoint struct {
+	Host               string
+	Port               int
+	User               string
+	HostKeyFingerprint string
+	authPayload
+}
+
+func (e sshEndpoint) addr() string {
+	return net.JoinHostPort(e.Host, strconv.Itoa(e.Port))
+}
+
+func targetEndpoint(t targetPayload) sshEndpoint {
+	return sshEndpoint{Host: t.Host, Port: t.Port, User: t.User,
+		HostKeyFingerprint: t.HostKeyFingerprint, authPayload: t.authPayload}
+}
+
+// sshDialer 负责生成各端点的客户端配置
+type sshDialer struct {
+	useAgent bool
+	hostKeys *hostKeyStore
+}
+
+// clientConfig 按 私钥/ssh-agent → 密码 → keyboard-interactive 的顺序组装认证方式；
+// 返回的 io.Closer 用于在握手结束后释放 agent 连接
+func (d sshDialer) clientConfig(ep sshEndpoint) (*ssh.ClientConfig, io.Closer, error) {
+	var signers []ssh.Signer
+	if ep.PrivateKey != "" {
+		signer, err := parsePrivateKey(ep.PrivateKey, ep.PrivateKeyPassphrase)
+		if err != nil {
+			return nil, nil, err
+		}
+		signers = append(signers, signer)
+	}
+
+	var closer io.Closer = nopCloser{}
+	var agentClient agent.ExtendedAgent
+	if d.useAgent {
+		if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+			conn, err := net.Dial("unix", sock)
+			if err != nil {
+				return nil, nil, fmt.Errorf("connect ssh-agent: %w", err)
+			}
+			closer = conn
+			agentClient = agent.NewClient(conn)
+		}
+	}
+
+	var methods []ssh.AuthMethod
+	if len(signers) > 0 || agentClient != nil {
+		methods = append(methods, ssh.PublicKeysCallback(func() ([]ssh.Signer, error) {
+			if agentClient == nil {
+				return signers, nil
+			}
+			agentSigners, err := agentClient.Signers()
+			if err != nil {
+				return signers, nil
+			}
+			return append(signers, agentSigners...), nil
+		}))
+	}
+	if password := ep.Password; password != "" {
+		methods = append(methods,
+			ssh.Password(password),
+			// 部分 sshd 只开放 keyboard-interactive，用同一密码应答所有提问
+			ssh.KeyboardInteractive(func(_, _ string, questions []string, _ []bool) ([]string, error) {
+				answers := make([]string, len(questions))
+				for i := range answers {
+					answers[i] = password
+				}
+				return answers, nil
+			}))
+	}
+
+	hostKeyCallback := ssh.InsecureIgnoreHostKey()
+	var hostKeyAlgorithms []string
+	if d.hostKeys != nil {
+		hostKeyCallback = d.hostKeys.callbackFor(ep)
+		hostKeyAlgorithms = d.hostKeys.algorithmsFor(ep)
+	}
+	return &ssh.ClientConfig{
+		User:              ep.User,
+		Auth:              methods,
+		HostKeyCallback:   hostKeyCallback,
+		HostKeyAlgorithms: hostKeyAlgorithms,
+	}, closer, nil
+}
+
+// parsePrivateKey 解析 PEM/OpenSSH 格式私钥，加密私钥需提供口令
+func parsePrivateKey(key, passphrase string) (ssh.Signer, error) {
+	if passphrase != "" {
+		signer, err := ssh.ParsePrivateKeyWithPassphrase([]byte(key), []byte(passphrase))
+		if err != nil {
+			return nil, fmt.Errorf("parse private key: %w", err)
+		}
+		return signer, nil
+	}
+	signer, err := ssh.ParsePrivateKey([]byte(key))
+	if err != nil {
+		var missing *ssh.PassphraseMissingError
+		if errors.As(err, &missing) {
+			return nil, errors.New("private key is encrypted but private_key_passphrase is empty")
+		}
+		return nil, fmt.Errorf("parse private key: %w", err)
+	}
+	return signer, nil
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
+
+// hop 经 prev 登录端点 ep（prev 为 nil 时直连），role 用于错误信息
+func (d sshDialer) hop(ctx context.Context, prev *ssh.Client, ep sshEndpoint, role string, timeout time.Duration) (*ssh.Client, error) {
+	netConn, err := d.open(ctx, prev, ep, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("connect %s %s: %w", role, ep.addr(), err)
+	}
+	client, err := d.login(ctx, netConn, ep, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("login %s %s: %w", role, ep.addr(), err)
+	}
+	return client, nil
+}
+
+// dialJumps 依次登录各级跳板机，后一级经前一级的 direct-tcpip 通道连接；
+// 任一级失败时关闭已建立的连接
+func (d sshDialer) dialJumps(ctx context.Context, jumps []sshEndpoint, timeout time.Duration) ([]*ssh.Client, error) {
+	clients := make([]*ssh.Client, 0, len(jumps))
+	var prev *ssh.Client
+	for _, ep := range jumps {
+		client, err := d.hop(ctx, prev, ep, "jump host", timeout)
+		if err != nil {
+			closeClients(clients)
+			return nil, err
+		}
+		clients = append(clients, client)
+		prev = client
+	}
+	return clients, nil
+}
+
+// closeClients 按与建立相反的顺序关闭跳板机链
+func closeClients(clients []*ssh.Client) {
+	for i := len(clients) - 1; i >= 0; i-- {
+		clients[i].Close()
+	}
+}
+
+// open 第一跳直接建立 TCP 连接，之后经上一级跳板机打开 direct-tcpip 通道
+func (d sshDialer) open(ctx context.Context, prev *ssh.Client, ep sshEndpoint, timeout time.Duration) (net.Conn, error) {
+	if prev == nil {
+		dialer := net.Dialer{Timeout: timeout}
+		return dialer.DialContext(ctx, "tcp", ep.addr())
+	}
+	channel, err := prev.DialContext(ctx, "tcp", ep.addr())
+	if err != nil {
+		return nil, fmt.Errorf("open channel: %w", err)
+	}
+	return channel, nil
+}
+
+// login 在已建立的连接上以端点凭据完成 SSH 登录
+func (d sshDialer) login(ctx context.Context, conn net.Conn, ep sshEndpoint, timeout time.Duration) (*ssh.Client, error) {
+	cfg, closer, err := d.clientConfig(ep)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	defer closer.Close()
+	return newClient(ctx, conn, ep.addr(), cfg, timeout)
+}
+
+// newClient 在已建立的连接上完成 SSH 握手；direct-tcpip 通道不支持 deadline，
+// 因此超时或取消时直接关闭底层连接
+func newClient(ctx context.Context, conn net.Conn, addr string, cfg *ssh.ClientConfig, timeout time.Duration) (*ssh.Client, error) {
+	type handshake struct {
+		client *ssh.Client
+		err    error
+	}
+	done := make(chan handshake, 1)
+	go func() {
+		c, chans, reqs, err := ssh.NewClientConn(conn, addr, cfg)
+		if err != nil {
+			done <- handshake{err: err}
+			return
+		}
+		done <- handshake{client: ssh.NewClient(c, chans, reqs)}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case h := <-done:
+		if h.err != nil {
+			conn.Close()
+		}
+		return h.client, h.err
+	case <-timer.C:
+		conn.Close()
+		return nil, fmt.Errorf("ssh handshake timed out after %s", timeout)
+	case <-ctx.Done():
+		conn.Close()
+		return nil, ctx.Err()
+	}
+}
+
+// runCommand 在新会话中执行一条命令，返回 stdout/stderr 与退出码；
+// 执行期间每读到一行输出即回调 onLine(stream, line)
+func runCommand(ctx context.Context, client *ssh.Client, command string, timeout time.Duration, onLine func(stream, line string)) (string, string, int, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return "", "", 0, fmt.Errorf("open session: %w", err)
+	}
+	defer session.Close()
+
+	// 超时返回时远端输出可能仍在写入，使用带锁的缓冲区
+	var stdout, stderr lockedBuffer
+	stdoutLines := &lineWriter{emit: func(line string) { onLine("stdout", line) }}
+	stderrLines := &lineWriter{emit: func(line string) { onLine("stderr", line) }}
+	session.Stdout = io.MultiWriter(&stdout, stdoutLines)
+	session.Stderr = io.MultiWriter(&stderr, stderrLines)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- session.Run(command)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case err = <-done:
+	case <-timer.C:
+		session.Close()
+		return decodeOutput(&stdout), decodeOutput(&stderr), 0, fmt.Errorf("command timed out after %s", timeout)
+	case <-ctx.Done():
+		session.Close()
+		return decodeOutput(&stdout), decodeOutput(&stderr), 0, ctx.Err()
+	}
+
+	// Run 返回时输出已全部读取完毕
+	stdoutLines.flush()
+	stderrLines.flush()
+
+	var exitErr *ssh.ExitError
+	if errors.As(err, &exitErr) {
+		return decodeOutput(&stdout), decodeOutput(&stderr), exitErr.ExitStatus(), nil
+	}
+	return decodeOutput(&stdout), decodeOutput(&stderr), 0, err
+}
+
+// lockedBuffer 并发安全的 bytes.Buffer
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// decodeOutput 丢弃非法 UTF-8 字节，与脚本中 decode(errors="ignore") 一致
+func decodeOutput(buf *lockedBuffer) string {
+	return strings.ToValidUTF8(buf.String(), "")
+}
+
+// forEachTarget 以给定并发数对每个目标下标调用 fn
+func forEachTarget(n, concurrency int, fn func(idx int)) {
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < effectiveConcurrency(concurrency, n); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range indexes {
+				fn(idx)
+			}
+		}()
+	}
+	for idx := 0; idx < n; idx++ {
+		indexes <- idx
+	}
+	close(indexes)
+	wg.Wait()
+}
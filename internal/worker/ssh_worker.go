@@ -1,22 +1,26 @@
 package worker
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
-	"os/exec"
 	"os/signal"
-	"path/filepath"
+	"slices"
 	"strconv"
-	"strings"
 	"sync"
 	"syscall"
 
+	"gocerery/internal/artifact"
+	"gocerery/internal/audit"
 	"gocerery/internal/config"
+	"gocerery/internal/download"
+	"gocerery/internal/guard"
 	"gocerery/internal/logger"
+	"gocerery/internal/pathfilter"
+	"gocerery/internal/sealer"
+	"gocerery/internal/secrets"
+	"gocerery/internal/taskstate"
 
 	"github.com/gocelery/gocelery"
 	"github.com/zeromicro/go-zero/core/logx"
@@ -26,8 +30,18 @@ type Runner struct {
 	client           *gocelery.CeleryClient
 	taskName         string
 	uploadTaskName   string
-	scriptPath       string
-	uploadScriptPath string
+	downloadTaskName string
+	executorBackend  string
+	executor         Executor
+	guard            *guard.Guard
+	audit            *audit.Recorder
+	sealer           *sealer.Sealer
+	keys             keyStore
+	refs             refBindings
+	secrets          *secrets.Resolver
+	artifacts        artifact.Store
+	state            *taskstate.Store
+	useAgent         bool
 	timeout          int
 	concurrency      int
 	cfg              *config.Config
@@ -100,7 +114,7 @@ func Run(cfg *config.Config) error {
 	}
 
 	logx.Infow("[WORKER] creating Celery client", logx.Field("workers", workers))
-	client, err := gocelery.NewCeleryClient(broker, backend, workers)
+	client, err := gocelery.NewCeleryClient(taskIDBroker{broker}, backend, workers)
 	if err != nil {
 		logx.Errorw("[WORKER] failed to create celery client", logx.Field("error", err))
 		return fmt.Errorf("create celery client: %w", err)
@@ -111,23 +125,75 @@ func Run(cfg *config.Config) error {
 		taskName = "tasks.execute_ssh"
 	}
 
-	scriptPath := filepath.Clean(cfg.Executor.Script)
-	uploadScriptPath := filepath.Clean(cfg.Executor.UploadScript)
-	if uploadScriptPath == "" {
-		uploadScriptPath = "./scripts/ssh_uploader.py"
+	executor, err := newExecutor(cfg)
+	if err != nil {
+		logx.Errorw("[WORKER] failed to create executor", logx.Field("error", err))
+		return err
+	}
+	executorBackend := cfg.Executor.Backend
+	if executorBackend == "" {
+		executorBackend = BackendGo
+	}
+
+	commandGuard, err := guard.New(cfg.CommandGuard)
+	if err != nil {
+		logx.Errorw("[WORKER] failed to load command guard", logx.Field("error", err))
+		return err
+	}
+
+	recorder, err := audit.New(cfg.Audit, backend)
+	if err != nil {
+		logx.Errorw("[WORKER] failed to init audit log", logx.Field("error", err))
+		return err
+	}
+
+	credentialSealer, err := sealer.New(cfg.CredentialEncryption)
+	if err != nil {
+		logx.Errorw("[WORKER] failed to load credential keys", logx.Field("error", err))
+		return err
+	}
+
+	secretResolver, err := secrets.New(cfg.Secrets)
+	if err != nil {
+		logx.Errorw("[WORKER] failed to init secret providers", logx.Field("error", err))
+		return err
+	}
+
+	artifacts, err := artifact.New(cfg.Artifacts)
+	if err != nil {
+		logx.Errorw("[WORKER] failed to init artifact store", logx.Field("error", err))
+		return err
 	}
 
 	uploadTaskName := cfg.Celery.UploadTaskName
 	if uploadTaskName == "" {
 		uploadTaskName = "tasks.upload_file"
 	}
+	downloadTaskName := cfg.Celery.DownloadTaskName
+	if downloadTaskName == "" {
+		downloadTaskName = "tasks.download_file"
+	}
+	if err := download.CheckStore(cfg.Downloads, artifacts != nil); err != nil {
+		logx.Errorw("[WORKER] invalid download config", logx.Field("error", err))
+		return err
+	}
 
 	runner := &Runner{
 		client:           client,
 		taskName:         taskName,
 		uploadTaskName:   uploadTaskName,
-		scriptPath:       scriptPath,
-		uploadScriptPath: uploadScriptPath,
+		downloadTaskName: downloadTaskName,
+		executorBackend:  executorBackend,
+		executor:         executor,
+		guard:            commandGuard,
+		audit:            recorder,
+		sealer:           credentialSealer,
+		keys:             keyStore{dir: cfg.Executor.KeyDir},
+		refs:             newRefBindings(cfg),
+		secrets:          secretResolver,
+		artifacts:        artifacts,
+		state:            taskstate.New(backend),
+		useAgent:         cfg.Executor.UseAgent,
 		timeout:          cfg.Executor.TimeoutSeconds,
 		concurrency:      cfg.Executor.Concurrency,
 		cfg:              cfg,
@@ -142,11 +208,15 @@ func Run(cfg *config.Config) error {
 	uploadTask := &UploadTask{runner: runner}
 	client.Register(uploadTaskName, uploadTask)
 
+	logx.Infow("[WORKER] registering download task", logx.Field("task", downloadTaskName))
+	downloadTask := &DownloadTask{runner: runner}
+	client.Register(downloadTaskName, downloadTask)
+
 	logx.Infow("[WORKER] celery worker ready",
 		logx.Field("task", taskName),
-		logx.Field("script", scriptPath),
+		logx.Field("executor_backend", executorBackend),
 		logx.Field("upload_task", uploadTaskName),
-		logx.Field("upload_script", uploadScriptPath),
+		logx.Field("download_task", downloadTaskName),
 		logx.Field("timeout", runner.timeout),
 		logx.Field("concurrency", runner.concurrency))
 
@@ -180,80 +250,48 @@ func Run(cfg *config.Config) error {
 
 func (r *Runner) execute(payload map[string]interface{}) (interface{}, error) {
 	logx.Infow("[WORKER] received task, parsing payload...")
-	task, err := parsePayload(payload)
+	task, err := parsePayload(payload, r.sealer)
 	if err != nil {
 		logx.Errorw("[WORKER] failed to parse payload", logx.Field("error", err))
 		return nil, err
 	}
 
 	logx.Infow("[WORKER] task parsed",
-		logx.Field("proxy", fmt.Sprintf("%s:%d", task.ProxyHost, task.ProxyPort)),
+		logx.Field("submitted_by", task.SubmittedBy),
+		logx.Field("jump_hosts", jumpHostAddrs(task.JumpHosts)),
 		logx.Field("targets", len(task.Targets)),
 		logx.Field("commands", len(task.Commands)))
 
-	if r.scriptPath == "" {
-		logx.Errorw("[WORKER] executor script path is empty")
-		return nil, errors.New("executor script path is empty")
-	}
-
-	timeout := normalizeTimeout(task.Timeout, r.timeout)
-	logx.Infow("[WORKER] using timeout and concurrency",
-		logx.Field("timeout", timeout),
-		logx.Field("concurrency", r.concurrency))
-
-	bastion := map[string]interface{}{
-		"host":     task.ProxyHost,
-		"port":     task.ProxyPort,
-		"user":     task.ProxyUser,
-		"password": task.ProxyPassword,
-	}
-	targets := make([]map[string]interface{}, 0, len(task.Targets))
-	for _, t := range task.Targets {
-		targets = append(targets, map[string]interface{}{
-			"name":     t.Name,
-			"host":     t.Host,
-			"port":     t.Port,
-			"user":     t.User,
-			"password": t.Password,
-		})
-	}
-
-	bastionJSON, _ := json.Marshal(bastion)
-	targetsJSON, _ := json.Marshal(targets)
-	commandsJSON, _ := json.Marshal(task.Commands)
+	event := newAuditEvent(payload, audit.KindSSH, task.SubmittedBy, task.Targets)
+	event.Commands = task.Commands
+	event.DangerousAck = task.DangerousAck
 
-	concurrency := r.concurrency
-	if concurrency <= 0 {
-		concurrency = 1
+	// 与 API 使用同一份规则再检查一次，防止绕过 API 直接投递的消息
+	acked, err := r.guard.Check(task.Commands, task.DangerousAck)
+	if err != nil {
+		logx.Errorw("[WORKER] task rejected by command guard", logx.Field("error", err))
+		event.Type = audit.EventTaskRejected
+		event.Error = err.Error()
+		r.audit.Record(event)
+		return nil, err
 	}
-	if concurrency > len(targets) && len(targets) > 0 {
-		concurrency = len(targets)
+	for _, v := range acked {
+		logx.Infow("[WORKER] dangerous command acknowledged",
+			logx.Field("command", v.Command),
+			logx.Field("rule", v.Rule))
 	}
 
-	// 构建日志参数
-	logLevel := "INFO"
-	if r.cfg != nil && r.cfg.WorkerLog.Level != "" {
-		logLevel = strings.ToUpper(r.cfg.WorkerLog.Level)
-	}
-	logFile := ""
-	if r.cfg != nil && r.cfg.WorkerLog.Mode == "file" && r.cfg.WorkerLog.Path != "" {
-		logFile = filepath.Join(r.cfg.WorkerLog.Path, "ssh_executor.log")
+	if err := r.checkAuth(task.JumpHosts, task.Targets); err != nil {
+		logx.Errorw("[WORKER] invalid credentials", logx.Field("error", err))
+		r.recordFinished(event, taskstate.StatusFailure, err, nil)
+		return nil, err
 	}
 
-	args := []string{
-		r.scriptPath,
-		"--bastion", string(bastionJSON),
-		"--targets", string(targetsJSON),
-		"--commands", string(commandsJSON),
-		"--concurrency", strconv.Itoa(concurrency),
-		"--timeout", strconv.Itoa(timeout),
-		"--log-level", logLevel,
-	}
-	if logFile != "" {
-		args = append(args, "--log-file", logFile)
-	}
+	timeout := normalizeTimeout(task.Timeout, r.timeout)
+	logx.Infow("[WORKER] using timeout and concurrency",
+		logx.Field("timeout", timeout),
+		logx.Field("concurrency", r.concurrency))
 
-	logx.Infow("[WORKER] executing script", logx.Field("script", r.scriptPath))
 	for i, target := range task.Targets {
 		logx.Infow("[WORKER] target info",
 			logx.Field("index", i),
@@ -266,108 +304,93 @@ func (r *Runner) execute(payload map[string]interface{}) (interface{}, error) {
 			logx.Field("command", cmd))
 	}
 
-	cmd := exec.Command("python3", args...)
-	var stdout bytes.Buffer
-	var stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	logx.Infow("[WORKER] starting script execution...")
-	if err := cmd.Run(); err != nil {
-		logx.Errorw("[WORKER] script execution failed",
-			logx.Field("error", err),
-			logx.Field("stderr", stderr.String()))
-		return nil, fmt.Errorf("executor script failed: %w", err)
-	}
-	logx.Infow("[WORKER] script execution completed", logx.Field("stdout_length", stdout.Len()))
-	if stdout.Len() > 0 {
-		// 打印原始输出（前 500 个字符）用于调试
-		stdoutStr := stdout.String()
-		if len(stdoutStr) > 500 {
-			logx.Debugw("[WORKER] raw stdout (truncated)", logx.Field("stdout", stdoutStr[:500]))
-		} else {
-			logx.Debugw("[WORKER] raw stdout", logx.Field("stdout", stdoutStr))
-		}
-	}
-	if stderr.Len() > 0 {
-		logx.Errorw("[WORKER] script stderr", logx.Field("stderr", stderr.String()))
+	run, err := r.startRun(payload)
+	if err != nil {
+		r.recordFinished(event, taskstate.StatusRevoked, err, nil)
+		return nil, err
 	}
 
-	var results []map[string]interface{}
-	if err := json.Unmarshal(stdout.Bytes(), &results); err != nil {
-		logx.Errorw("[WORKER] failed to decode executor output",
-			logx.Field("error", err),
-			logx.Field("raw_stdout", stdout.String()))
-		return nil, fmt.Errorf("decode executor output: %w", err)
+	if err := r.prepareAuth(run, timeout, task.JumpHosts, task.Targets); err != nil {
+		if revokeErr := r.finishRun(run, nil); revokeErr != nil {
+			r.recordFinished(event, taskstate.StatusRevoked, revokeErr, nil)
+			return nil, revokeErr
+		}
+		logx.Errorw("[WORKER] failed to prepare credentials", logx.Field("error", err))
+		r.recordFinished(event, taskstate.StatusFailure, err, nil)
+		return nil, err
 	}
-	if len(results) == 0 {
+	event.Type = audit.EventTaskStarted
+	r.audit.Record(event)
+
+	queued := make([]HostResult, len(task.Targets))
+	for i, target := range task.Targets {
+		queued[i] = newHostResult(target)
+	}
+	progress := newTaskProgress(r.state, run.id, queued)
+	stream := newTaskStream(r.state, run.id, len(task.Targets))
+	report := hostReporter{
+		onResult: func(idx int, result HostResult) {
+			progress.update(idx, result)
+			stream.hostState(idx, result)
+		},
+		onOutput: stream.output,
+	}
+
+	logx.Infow("[WORKER] executing task", logx.Field("backend", r.executorBackend))
+	results, err := r.executor.Execute(run.ctx, task, timeout, report)
+	progress.close()
+	if revokeErr := r.finishRun(run, results); revokeErr != nil {
+		stream.close(taskstate.StatusRevoked)
+		r.recordFinished(event, taskstate.StatusRevoked, revokeErr, hostOutcomes(results))
+		return nil, revokeErr
+	}
+	if err == nil && len(results) == 0 {
 		logx.Errorw("[WORKER] executor returned empty result")
-		return nil, errors.New("executor returned empty result")
+		err = errors.New("executor returned empty result")
 	}
+	if err != nil {
+		r.markFailed(run.id, results)
+		stream.close(taskstate.StatusFailure)
+		r.recordFinished(event, taskstate.StatusFailure, err, hostOutcomes(results))
+		return nil, err
+	}
+	// 最终结果随后由 gocelery 写入 Backend
+	stream.close(taskstate.StatusSuccess)
+	r.recordFinished(event, taskstate.StatusSuccess, nil, hostOutcomes(results))
 
 	successCount := 0
-	for _, r := range results {
-		if success, ok := r["success"].(bool); ok && success {
+	for _, res := range results {
+		if res.Success {
 			successCount++
 		}
 	}
 	logx.Infow("[WORKER] task completed",
 		logx.Field("success_count", successCount),
 		logx.Field("total_count", len(results)))
-	for i, r := range results {
-		name := "unknown"
-		if n, ok := r["name"].(string); ok {
-			name = n
-		}
-		host := "unknown"
-		if h, ok := r["host"].(string); ok {
-			host = h
-		}
-		success := false
-		if s, ok := r["success"].(bool); ok {
-			success = s
-		}
-		exitCode := 0
-		if ec, ok := r["exit_code"].(float64); ok {
-			exitCode = int(ec)
-		} else if ec, ok := r["exit_code"].(int); ok {
-			exitCode = ec
-		}
-		stdout := ""
-		if so, ok := r["stdout"].(string); ok {
-			stdout = so
-		}
-		stderr := ""
-		if se, ok := r["stderr"].(string); ok {
-			stderr = se
-		}
-		errMsg := ""
-		if e, ok := r["error"].(string); ok {
-			errMsg = e
-		}
+	for i, res := range results {
 		logx.Infow("[WORKER] result",
 			logx.Field("index", i),
-			logx.Field("name", name),
-			logx.Field("host", host),
-			logx.Field("success", success),
-			logx.Field("exit_code", exitCode))
-		if !success {
-			if errMsg != "" {
+			logx.Field("name", res.Name),
+			logx.Field("host", res.Host),
+			logx.Field("success", res.Success),
+			logx.Field("exit_code", res.ExitCode))
+		if !res.Success {
+			if res.Error != "" {
 				logx.Errorw("[WORKER] result error",
 					logx.Field("index", i),
-					logx.Field("error", errMsg))
+					logx.Field("error", res.Error))
 			}
-			if stderr != "" {
+			if res.Stderr != "" {
 				logx.Errorw("[WORKER] result stderr",
 					logx.Field("index", i),
-					logx.Field("stderr", stderr))
+					logx.Field("stderr", res.Stderr))
 			}
 		}
-		if stdout != "" {
+		if res.Stdout != "" {
 			// 只显示前 200 个字符，避免日志过长
-			stdoutPreview := stdout
-			if len(stdout) > 200 {
-				stdoutPreview = stdout[:200] + "..."
+			stdoutPreview := res.Stdout
+			if len(stdoutPreview) > 200 {
+				stdoutPreview = stdoutPreview[:200] + "..."
 			}
 			logx.Debugw("[WORKER] result stdout",
 				logx.Field("index", i),
@@ -380,27 +403,50 @@ func (r *Runner) execute(payload map[string]interface{}) (interface{}, error) {
 
 func (r *Runner) executeUpload(payload map[string]interface{}) (interface{}, error) {
 	logx.Infow("[WORKER] received upload task, parsing payload...")
-	task, err := parseUploadPayload(payload)
+	task, err := parseUploadPayload(payload, r.sealer)
 	if err != nil {
 		logx.Errorw("[WORKER] failed to parse upload payload", logx.Field("error", err))
 		return nil, err
 	}
 
 	logx.Infow("[WORKER] upload task parsed",
-		logx.Field("proxy", fmt.Sprintf("%s:%d", task.ProxyHost, task.ProxyPort)),
+		logx.Field("submitted_by", task.SubmittedBy),
+		logx.Field("jump_hosts", jumpHostAddrs(task.JumpHosts)),
 		logx.Field("targets", len(task.Targets)),
 		logx.Field("local_path", task.LocalPath),
+		logx.Field("artifact_id", task.ArtifactID),
 		logx.Field("remote_path", task.RemotePath))
 
-	if r.uploadScriptPath == "" {
-		logx.Errorw("[WORKER] upload script path is empty")
-		return nil, errors.New("upload script path is empty")
+	event := newAuditEvent(payload, audit.KindUpload, task.SubmittedBy, task.Targets)
+	event.LocalPath = task.LocalPath
+	event.ArtifactID = task.ArtifactID
+	event.RemotePath = task.RemotePath
+
+	if err := r.checkAuth(task.JumpHosts, task.Targets); err != nil {
+		logx.Errorw("[WORKER] invalid credentials", logx.Field("error", err))
+		r.recordFinished(event, taskstate.StatusFailure, err, nil)
+		return nil, err
+	}
+
+	if task.ArtifactID != "" {
+		localPath, cleanup, err := r.fetchArtifact(task.ArtifactID)
+		if err != nil {
+			logx.Errorw("[WORKER] failed to fetch artifact",
+				logx.Field("artifact_id", task.ArtifactID),
+				logx.Field("error", err))
+			r.recordFinished(event, taskstate.StatusFailure, err, nil)
+			return nil, err
+		}
+		defer cleanup()
+		task.LocalPath = localPath
 	}
 
 	// 检查本地路径是否存在
 	if _, err := os.Stat(task.LocalPath); os.IsNotExist(err) {
 		logx.Errorw("[WORKER] local path does not exist", logx.Field("path", task.LocalPath))
-		return nil, fmt.Errorf("local path does not exist: %s", task.LocalPath)
+		err = fmt.Errorf("local path does not exist: %s", task.LocalPath)
+		r.recordFinished(event, taskstate.StatusFailure, err, nil)
+		return nil, err
 	}
 
 	timeout := normalizeTimeout(task.Timeout, r.timeout)
@@ -408,166 +454,81 @@ func (r *Runner) executeUpload(payload map[string]interface{}) (interface{}, err
 		logx.Field("timeout", timeout),
 		logx.Field("concurrency", r.concurrency))
 
-	bastion := map[string]interface{}{
-		"host":     task.ProxyHost,
-		"port":     task.ProxyPort,
-		"user":     task.ProxyUser,
-		"password": task.ProxyPassword,
-	}
-	targets := make([]map[string]interface{}, 0, len(task.Targets))
-	for _, t := range task.Targets {
-		targets = append(targets, map[string]interface{}{
-			"name":     t.Name,
-			"host":     t.Host,
-			"port":     t.Port,
-			"user":     t.User,
-			"password": t.Password,
-		})
-	}
-
-	bastionJSON, _ := json.Marshal(bastion)
-	targetsJSON, _ := json.Marshal(targets)
-
-	concurrency := r.concurrency
-	if concurrency <= 0 {
-		concurrency = 1
-	}
-	if concurrency > len(targets) && len(targets) > 0 {
-		concurrency = len(targets)
-	}
-
-	// 构建日志参数
-	logLevel := "INFO"
-	if r.cfg != nil && r.cfg.WorkerLog.Level != "" {
-		logLevel = strings.ToUpper(r.cfg.WorkerLog.Level)
-	}
-	logFile := ""
-	if r.cfg != nil && r.cfg.WorkerLog.Mode == "file" && r.cfg.WorkerLog.Path != "" {
-		logFile = filepath.Join(r.cfg.WorkerLog.Path, "ssh_uploader.log")
-	}
-
-	args := []string{
-		r.uploadScriptPath,
-		"--bastion", string(bastionJSON),
-		"--targets", string(targetsJSON),
-		"--local-path", task.LocalPath,
-		"--remote-path", task.RemotePath,
-		"--concurrency", strconv.Itoa(concurrency),
-		"--timeout", strconv.Itoa(timeout),
-		"--log-level", logLevel,
-	}
-	if logFile != "" {
-		args = append(args, "--log-file", logFile)
-	}
-
-	logx.Infow("[WORKER] executing upload script", logx.Field("script", r.uploadScriptPath))
 	for i, target := range task.Targets {
 		logx.Infow("[WORKER] upload target info",
 			logx.Field("index", i),
 			logx.Field("name", target.Name),
 			logx.Field("host", fmt.Sprintf("%s:%d", target.Host, target.Port)))
 	}
-	logx.Infow("[WORKER] upload paths",
-		logx.Field("local_path", task.LocalPath),
-		logx.Field("remote_path", task.RemotePath))
 
-	cmd := exec.Command("python3", args...)
-	var stdout bytes.Buffer
-	var stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	logx.Infow("[WORKER] starting upload script execution...")
-	if err := cmd.Run(); err != nil {
-		logx.Errorw("[WORKER] upload script execution failed",
-			logx.Field("error", err),
-			logx.Field("stderr", stderr.String()))
-		return nil, fmt.Errorf("upload script failed: %w", err)
-	}
-	logx.Infow("[WORKER] upload script execution completed", logx.Field("stdout_length", stdout.Len()))
-	if stdout.Len() > 0 {
-		stdoutStr := stdout.String()
-		if len(stdoutStr) > 500 {
-			logx.Debugw("[WORKER] raw stdout (truncated)", logx.Field("stdout", stdoutStr[:500]))
-		} else {
-			logx.Debugw("[WORKER] raw stdout", logx.Field("stdout", stdoutStr))
+	run, err := r.startRun(payload)
+	if err != nil {
+		r.recordFinished(event, taskstate.StatusRevoked, err, nil)
+		return nil, err
+	}
+
+	if err := r.prepareAuth(run, timeout, task.JumpHosts, task.Targets); err != nil {
+		if revokeErr := r.finishRun(run, nil); revokeErr != nil {
+			r.recordFinished(event, taskstate.StatusRevoked, revokeErr, nil)
+			return nil, revokeErr
 		}
+		logx.Errorw("[WORKER] failed to prepare credentials", logx.Field("error", err))
+		r.recordFinished(event, taskstate.StatusFailure, err, nil)
+		return nil, err
 	}
-	if stderr.Len() > 0 {
-		logx.Errorw("[WORKER] upload script stderr", logx.Field("stderr", stderr.String()))
+	event.Type = audit.EventTaskStarted
+	r.audit.Record(event)
+
+	queued := make([]UploadResult, len(task.Targets))
+	for i, target := range task.Targets {
+		queued[i] = newUploadResult(target)
 	}
+	progress := newTaskProgress(r.state, run.id, queued)
 
-	var results []map[string]interface{}
-	if err := json.Unmarshal(stdout.Bytes(), &results); err != nil {
-		logx.Errorw("[WORKER] failed to decode upload executor output",
-			logx.Field("error", err),
-			logx.Field("raw_stdout", stdout.String()))
-		return nil, fmt.Errorf("decode upload executor output: %w", err)
+	logx.Infow("[WORKER] executing upload task", logx.Field("backend", r.executorBackend))
+	results, err := r.executor.Upload(run.ctx, task, timeout, progress.update)
+	progress.close()
+	if revokeErr := r.finishRun(run, results); revokeErr != nil {
+		r.recordFinished(event, taskstate.StatusRevoked, revokeErr, uploadOutcomes(results))
+		return nil, revokeErr
 	}
-	if len(results) == 0 {
+	if err == nil && len(results) == 0 {
 		logx.Errorw("[WORKER] upload executor returned empty result")
-		return nil, errors.New("upload executor returned empty result")
+		err = errors.New("upload executor returned empty result")
+	}
+	if err != nil {
+		r.markFailed(run.id, results)
+		r.recordFinished(event, taskstate.StatusFailure, err, uploadOutcomes(results))
+		return nil, err
 	}
+	r.recordFinished(event, taskstate.StatusSuccess, nil, uploadOutcomes(results))
 
 	successCount := 0
-	for _, r := range results {
-		if success, ok := r["success"].(bool); ok && success {
+	for _, res := range results {
+		if res.Success {
 			successCount++
 		}
 	}
 	logx.Infow("[WORKER] upload task completed",
 		logx.Field("success_count", successCount),
 		logx.Field("total_count", len(results)))
-	for i, r := range results {
-		name := "unknown"
-		if n, ok := r["name"].(string); ok {
-			name = n
-		}
-		host := "unknown"
-		if h, ok := r["host"].(string); ok {
-			host = h
-		}
-		success := false
-		if s, ok := r["success"].(bool); ok {
-			success = s
-		}
-		uploadedFiles := []string{}
-		if uf, ok := r["uploaded_files"].([]interface{}); ok {
-			for _, f := range uf {
-				if fileInfo, ok := f.(map[string]interface{}); ok {
-					if remote, ok := fileInfo["remote"].(string); ok {
-						uploadedFiles = append(uploadedFiles, remote)
-					}
-				}
-			}
-		}
-		failedFiles := []string{}
-		if ff, ok := r["failed_files"].([]interface{}); ok {
-			for _, f := range ff {
-				if fileInfo, ok := f.(map[string]interface{}); ok {
-					if remote, ok := fileInfo["remote"].(string); ok {
-						failedFiles = append(failedFiles, remote)
-					}
-				}
-			}
-		}
-		errMsg := ""
-		if e, ok := r["error"].(string); ok {
-			errMsg = e
+	for i, res := range results {
+		var uploadedBytes int64
+		for _, f := range res.UploadedFiles {
+			uploadedBytes += f.Bytes
 		}
 		logx.Infow("[WORKER] upload result",
 			logx.Field("index", i),
-			logx.Field("name", name),
-			logx.Field("host", host),
-			logx.Field("success", success),
-			logx.Field("uploaded_count", len(uploadedFiles)),
-			logx.Field("failed_count", len(failedFiles)))
-		if !success {
-			if errMsg != "" {
-				logx.Errorw("[WORKER] upload result error",
-					logx.Field("index", i),
-					logx.Field("error", errMsg))
-			}
+			logx.Field("name", res.Name),
+			logx.Field("host", res.Host),
+			logx.Field("success", res.Success),
+			logx.Field("uploaded_count", len(res.UploadedFiles)),
+			logx.Field("uploaded_bytes", uploadedBytes),
+			logx.Field("failed_count", len(res.FailedFiles)))
+		if !res.Success && res.Error != "" {
+			logx.Errorw("[WORKER] upload result error",
+				logx.Field("index", i),
+				logx.Field("error", res.Error))
 		}
 	}
 
@@ -575,48 +536,158 @@ func (r *Runner) executeUpload(payload map[string]interface{}) (interface{}, err
 }
 
 type taskPayload struct {
-	ProxyHost     string
-	ProxyPort     int
-	ProxyUser     string
-	ProxyPassword string
-	Targets       []targetPayload
-	Commands      []string
-	Timeout       int
-	SaveLog       bool
+	JumpHosts        []sshEndpoint // 按顺序经过的跳板机，为空时直连目标主机
+	Targets          []targetPayload
+	Commands         []string
+	AllowedExitCodes [][]int // 与 Commands 下标对应，为空时只允许 0
+	OnError          string
+	DangerousAck     bool // 放行命中软规则的命令
+	Timeout          int
+	SaveLog          bool
+	SubmittedBy      string // 提交任务的调用方身份，仅用于日志
+}
+
+// exitCodeAllowed 判断第 idx 条命令的退出码是否视为成功
+func (t *taskPayload) exitCodeAllowed(idx, exitCode int) bool {
+	if idx >= len(t.AllowedExitCodes) || len(t.AllowedExitCodes[idx]) == 0 {
+		return exitCode == 0
+	}
+	return slices.Contains(t.AllowedExitCodes[idx], exitCode)
 }
 
 type targetPayload struct {
-	Name     string
-	Host     string
-	Port     int
-	User     string
-	Password string
+	Name               string
+	Host               string
+	Port               int
+	User               string
+	HostKeyFingerprint string
+	authPayload
+}
+
+// authPayload SSH 认证信息：密码、password_ref、私钥、key_ref 至少提供一种（启用 ssh-agent 时可全部为空）
+type authPayload struct {
+	Password             string
+	PasswordRef          string
+	PrivateKey           string
+	PrivateKeyPassphrase string
+	KeyRef               string
+}
+
+func (a authPayload) hasCredential() bool {
+	return a.Password != "" || a.PasswordRef != "" || a.PrivateKey != "" || a.KeyRef != ""
+}
+
+// parseJumpHosts 读取 jump_hosts 跳板机列表；旧版消息只带 proxy_* 字段，视为一级跳板机
+func parseJumpHosts(data map[string]interface{}) ([]sshEndpoint, error) {
+	raw, ok := data["jump_hosts"]
+	if !ok || raw == nil {
+		if stringValue(data["proxy_host"]) == "" {
+			return nil, nil
+		}
+		raw = []interface{}{map[string]interface{}{
+			"host":                   data["proxy_host"],
+			"port":                   data["proxy_port"],
+			"user":                   data["proxy_user"],
+			"password":               data["proxy_password"],
+			"password_ref":           data["proxy_password_ref"],
+			"private_key":            data["proxy_private_key"],
+			"private_key_passphrase": data["proxy_private_key_passphrase"],
+			"key_ref":                data["proxy_key_ref"],
+			"host_key_fingerprint":   data["proxy_host_key_fingerprint"],
+		}}
+	}
+	list, ok := raw.([]interface{})
+	if !ok {
+		return nil, errors.New("jump_hosts must be an array")
+	}
+	hops := make([]sshEndpoint, 0, len(list))
+	for idx, item := range list {
+		obj, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("jump_hosts[%d] must be object", idx)
+		}
+		hop := sshEndpoint{
+			Host:               stringValue(obj["host"]),
+			Port:               normalizePort(intValue(obj["port"])),
+			User:               stringValue(obj["user"]),
+			HostKeyFingerprint: stringValue(obj["host_key_fingerprint"]),
+			authPayload: authPayload{
+				Password:             stringValue(obj["password"]),
+				PasswordRef:          stringValue(obj["password_ref"]),
+				PrivateKey:           stringValue(obj["private_key"]),
+				PrivateKeyPassphrase: stringValue(obj["private_key_passphrase"]),
+				KeyRef:               stringValue(obj["key_ref"]),
+			},
+		}
+		if hop.Host == "" || hop.User == "" {
+			return nil, fmt.Errorf("jump_hosts[%d] host/user are required", idx)
+		}
+		hops = append(hops, hop)
+	}
+	return hops, nil
 }
 
-func parsePayload(data map[string]interface{}) (*taskPayload, error) {
-	getString := func(key string) string {
-		if v, ok := data[key]; ok {
-			return fmt.Sprintf("%v", v)
+// jumpHostAddrs 跳板机地址列表，用于日志
+func jumpHostAddrs(hops []sshEndpoint) []string {
+	addrs := make([]string, 0, len(hops))
+	for _, hop := range hops {
+		addrs = append(addrs, hop.addr())
+	}
+	return addrs
+}
+
+// intValue 将可选的数字字段转为 int，缺失或格式错误时返回 0
+func intValue(v interface{}) int {
+	switch val := v.(type) {
+	case float64:
+		return int(val)
+	case int:
+		return val
+	case string:
+		if parsed, err := strconv.Atoi(val); err == nil {
+			return parsed
 		}
+	}
+	return 0
+}
+
+// stringValue 将可选字段转为字符串，缺失或为 null 时返回空串
+func stringValue(v interface{}) string {
+	if v == nil {
 		return ""
 	}
+	return fmt.Sprintf("%v", v)
+}
 
-	getInt := func(key string) int {
-		if v, ok := data[key]; ok {
-			switch val := v.(type) {
-			case float64:
-				return int(val)
-			case int:
-				return val
-			case string:
-				if parsed, err := strconv.Atoi(val); err == nil {
-					return parsed
-				}
-			}
+// stringList 将可选的字符串数组字段转为 []string，缺失或为 null 时返回 nil
+func stringList(v interface{}, field string) ([]string, error) {
+	if v == nil {
+		return nil, nil
+	}
+	list, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s must be an array", field)
+	}
+	out := make([]string, 0, len(list))
+	for idx, item := range list {
+		str, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s[%d] must be string", field, idx)
 		}
-		return 0
+		out = append(out, str)
 	}
+	return out, nil
+}
 
+// parsePayload 先解密凭据再解析；加密的凭据被篡改时返回错误
+func parsePayload(data map[string]interface{}, s *sealer.Sealer) (*taskPayload, error) {
+	if err := s.Open(data); err != nil {
+		return nil, fmt.Errorf("decrypt credentials: %w", err)
+	}
+	jumpHosts, err := parseJumpHosts(data)
+	if err != nil {
+		return nil, err
+	}
 	rawTargets, ok := data["targets"]
 	if !ok {
 		return nil, errors.New("targets is required")
@@ -633,20 +704,26 @@ func parsePayload(data map[string]interface{}) (*taskPayload, error) {
 	if err != nil {
 		return nil, err
 	}
+	allowedExitCodes, err := parseAllowedExitCodes(data["allowed_exit_codes"], len(commands))
+	if err != nil {
+		return nil, err
+	}
+	onError, err := parseOnError(stringValue(data["on_error"]))
+	if err != nil {
+		return nil, err
+	}
 
 	task := &taskPayload{
-		ProxyHost:     getString("proxy_host"),
-		ProxyPort:     normalizePort(getInt("proxy_port")),
-		ProxyUser:     getString("proxy_user"),
-		ProxyPassword: getString("proxy_password"),
-		Targets:       targets,
-		Commands:      commands,
-		Timeout:       getInt("timeout"),
+		JumpHosts:        jumpHosts,
+		Targets:          targets,
+		Commands:         commands,
+		AllowedExitCodes: allowedExitCodes,
+		OnError:          onError,
+		DangerousAck:     data["dangerous_ack"] == true,
+		Timeout:          intValue(data["timeout"]),
+		SubmittedBy:      stringValue(data["submitted_by"]),
 	}
 
-	if task.ProxyHost == "" || task.ProxyUser == "" || task.ProxyPassword == "" {
-		return nil, errors.New("proxy credentials are required")
-	}
 	if len(task.Targets) == 0 {
 		return nil, errors.New("targets cannot be empty")
 	}
@@ -668,33 +745,26 @@ func parseTargets(raw interface{}) ([]targetPayload, error) {
 		if !ok {
 			return nil, fmt.Errorf("target[%d] must be object", idx)
 		}
-		name := fmt.Sprintf("%v", obj["name"])
-		host := fmt.Sprintf("%v", obj["host"])
-		user := fmt.Sprintf("%v", obj["user"])
-		password := fmt.Sprintf("%v", obj["password"])
-		port := 0
-		if v, ok := obj["port"]; ok {
-			switch p := v.(type) {
-			case float64:
-				port = int(p)
-			case int:
-				port = p
-			case string:
-				if parsed, err := strconv.Atoi(p); err == nil {
-					port = parsed
-				}
-			}
-		}
-		port = normalizePort(port)
-		if host == "" || user == "" || password == "" {
-			return nil, fmt.Errorf("target[%d] host/user/password are required", idx)
+		name := stringValue(obj["name"])
+		host := stringValue(obj["host"])
+		user := stringValue(obj["user"])
+		port := normalizePort(intValue(obj["port"]))
+		if host == "" || user == "" {
+			return nil, fmt.Errorf("target[%d] host/user are required", idx)
 		}
 		result = append(result, targetPayload{
-			Name:     name,
-			Host:     host,
-			Port:     port,
-			User:     user,
-			Password: password,
+			Name:               name,
+			Host:               host,
+			Port:               port,
+			User:               user,
+			HostKeyFingerprint: stringValue(obj["host_key_fingerprint"]),
+			authPayload: authPayload{
+				Password:             stringValue(obj["password"]),
+				PasswordRef:          stringValue(obj["password_ref"]),
+				PrivateKey:           stringValue(obj["private_key"]),
+				PrivateKeyPassphrase: stringValue(obj["private_key_passphrase"]),
+				KeyRef:               stringValue(obj["key_ref"]),
+			},
 		})
 	}
 	return result, nil
@@ -705,7 +775,7 @@ func parseCommands(raw interface{}) ([]string, error) {
 	case []interface{}:
 		cmds := make([]string, 0, len(val))
 		for idx, item := range val {
-			str := fmt.Sprintf("%v", item)
+			str := stringValue(item)
 			if str == "" {
 				return nil, fmt.Errorf("commands[%d] cannot be empty", idx)
 			}
@@ -719,6 +789,49 @@ func parseCommands(raw interface{}) ([]string, error) {
 	}
 }
 
+// parseAllowedExitCodes 解析与 commands 一一对应的 allowed_exit_codes（二维数组，可省略）
+func parseAllowedExitCodes(raw interface{}, commands int) ([][]int, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	list, ok := raw.([]interface{})
+	if !ok {
+		return nil, errors.New("allowed_exit_codes must be an array")
+	}
+	if len(list) > commands {
+		return nil, fmt.Errorf("allowed_exit_codes has %d entries but only %d commands", len(list), commands)
+	}
+	result := make([][]int, len(list))
+	for idx, item := range list {
+		if item == nil {
+			continue
+		}
+		codes, ok := item.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("allowed_exit_codes[%d] must be an array", idx)
+		}
+		for _, code := range codes {
+			val, ok := code.(float64)
+			if !ok {
+				return nil, fmt.Errorf("allowed_exit_codes[%d] must contain integers", idx)
+			}
+			result[idx] = append(result[idx], int(val))
+		}
+	}
+	return result, nil
+}
+
+func parseOnError(onError string) (string, error) {
+	switch onError {
+	case "":
+		return OnErrorStop, nil
+	case OnErrorStop, OnErrorContinue, OnErrorIgnore:
+		return onError, nil
+	default:
+		return "", fmt.Errorf("unknown on_error: %s", onError)
+	}
+}
+
 func normalizePort(port int) int {
 	if port <= 0 {
 		return 22
@@ -737,41 +850,29 @@ func normalizeTimeout(requestTimeout, defaultTimeout int) int {
 }
 
 type uploadTaskPayload struct {
-	ProxyHost     string
-	ProxyPort     int
-	ProxyUser     string
-	ProxyPassword string
+	JumpHosts     []sshEndpoint // 按顺序经过的跳板机，为空时直连目标主机
 	Targets       []targetPayload
 	LocalPath     string
+	ArtifactID    string // 通过 API 上传的内容，执行前取回到临时目录并替换 LocalPath
 	RemotePath    string
+	UploadMode    string   // overwrite（默认）, skip_existing, sync
+	SyncCompare   string   // sync 模式的比较方式：size_mtime（默认）, sha256
+	filePlacement          // mode、owner、group、atomic、backup_existing
+	Include       []string // 目录上传时只上传匹配的文件，gitignore 风格
+	Exclude       []string // 目录上传时跳过匹配的文件与目录，gitignore 风格
 	Timeout       int
 	SaveLog       bool
+	SubmittedBy   string // 提交任务的调用方身份，仅用于日志
 }
 
-func parseUploadPayload(data map[string]interface{}) (*uploadTaskPayload, error) {
-	getString := func(key string) string {
-		if v, ok := data[key]; ok {
-			return fmt.Sprintf("%v", v)
-		}
-		return ""
+func parseUploadPayload(data map[string]interface{}, s *sealer.Sealer) (*uploadTaskPayload, error) {
+	if err := s.Open(data); err != nil {
+		return nil, fmt.Errorf("decrypt credentials: %w", err)
 	}
-
-	getInt := func(key string) int {
-		if v, ok := data[key]; ok {
-			switch val := v.(type) {
-			case float64:
-				return int(val)
-			case int:
-				return val
-			case string:
-				if parsed, err := strconv.Atoi(val); err == nil {
-					return parsed
-				}
-			}
-		}
-		return 0
+	jumpHosts, err := parseJumpHosts(data)
+	if err != nil {
+		return nil, err
 	}
-
 	rawTargets, ok := data["targets"]
 	if !ok {
 		return nil, errors.New("targets is required")
@@ -781,25 +882,52 @@ func parseUploadPayload(data map[string]interface{}) (*uploadTaskPayload, error)
 		return nil, err
 	}
 
+	uploadMode, syncCompare, err := parseUploadMode(stringValue(data["upload_mode"]), stringValue(data["sync_compare"]))
+	if err != nil {
+		return nil, err
+	}
+
+	placement, err := parseFilePlacement(data)
+	if err != nil {
+		return nil, err
+	}
+
+	include, err := stringList(data["include"], "include")
+	if err != nil {
+		return nil, err
+	}
+	exclude, err := stringList(data["exclude"], "exclude")
+	if err != nil {
+		return nil, err
+	}
+	// 与 API 使用同一份校验，Worker 执行时再按同样的规则解析
+	if _, err := pathfilter.New(include, exclude); err != nil {
+		return nil, err
+	}
+
 	task := &uploadTaskPayload{
-		ProxyHost:     getString("proxy_host"),
-		ProxyPort:     normalizePort(getInt("proxy_port")),
-		ProxyUser:     getString("proxy_user"),
-		ProxyPassword: getString("proxy_password"),
+		JumpHosts:     jumpHosts,
 		Targets:       targets,
-		LocalPath:     getString("local_path"),
-		RemotePath:    getString("remote_path"),
-		Timeout:       getInt("timeout"),
+		LocalPath:     stringValue(data["local_path"]),
+		ArtifactID:    stringValue(data["artifact_id"]),
+		RemotePath:    stringValue(data["remote_path"]),
+		UploadMode:    uploadMode,
+		SyncCompare:   syncCompare,
+		filePlacement: placement,
+		Include:       include,
+		Exclude:       exclude,
+		Timeout:       intValue(data["timeout"]),
+		SubmittedBy:   stringValue(data["submitted_by"]),
 	}
 
-	if task.ProxyHost == "" || task.ProxyUser == "" || task.ProxyPassword == "" {
-		return nil, errors.New("proxy credentials are required")
-	}
 	if len(task.Targets) == 0 {
 		return nil, errors.New("targets cannot be empty")
 	}
-	if task.LocalPath == "" {
-		return nil, errors.New("local_path is required")
+	if task.LocalPath == "" && task.ArtifactID == "" {
+		return nil, errors.New("local_path or artifact_id is required")
+	}
+	if task.LocalPath != "" && task.ArtifactID != "" {
+		return nil, errors.New("local_path and artifact_id cannot be used together")
 	}
 	if task.RemotePath == "" {
 		return nil, errors.New("remote_path is required")
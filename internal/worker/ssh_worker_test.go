@@ -0,0 +1,75 @@
+package worker
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTargets(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     interface{}
+		want    []targetPayload
+		wantErr bool
+	}{
+		{name: "full",
+			raw:  []interface{}{map[string]interface{}{"name": "web-1", "host": "10.0.0.31", "port": float64(2222), "user": "deploy", "password": "pw"}},
+			want: []targetPayload{{Name: "web-1", Host: "10.0.0.31", Port: 2222, User: "deploy", authPayload: authPayload{Password: "pw"}}}},
+		{name: "null name and default port",
+			raw:  []interface{}{map[string]interface{}{"name": nil, "host": "10.0.0.31", "user": "deploy"}},
+			want: []targetPayload{{Host: "10.0.0.31", Port: 22, User: "deploy"}}},
+		{name: "null host", raw: []interface{}{map[string]interface{}{"host": nil, "user": "deploy"}}, wantErr: true},
+		{name: "missing user", raw: []interface{}{map[string]interface{}{"host": "10.0.0.31"}}, wantErr: true},
+		{name: "not an object", raw: []interface{}{"10.0.0.31"}, wantErr: true},
+		{name: "not an array", raw: map[string]interface{}{}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTargets(tt.raw)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseTargets() = %+v, want error", got)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("parseTargets() = %+v, %v, want %+v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCommandsRejectsNull(t *testing.T) {
+	if got, err := parseCommands([]interface{}{"uptime", nil}); err == nil {
+		t.Fatalf("parseCommands() = %q, want error for null command", got)
+	}
+}
+
+func TestParsePayloadOptionalFields(t *testing.T) {
+	task, err := parsePayload(map[string]interface{}{
+		"targets":  []interface{}{map[string]interface{}{"host": "10.0.0.31", "user": "deploy", "password": "pw"}},
+		"commands": []interface{}{"uptime"},
+		"on_error": nil,
+		"timeout":  "30",
+	}, nil)
+	if err != nil {
+		t.Fatalf("parsePayload() error = %v", err)
+	}
+	if task.Timeout != 30 || task.SubmittedBy != "" {
+		t.Fatalf("parsePayload() timeout = %d, submitted_by = %q, want 30 and empty", task.Timeout, task.SubmittedBy)
+	}
+
+	upload, err := parseUploadPayload(map[string]interface{}{
+		"targets":     []interface{}{map[string]interface{}{"host": "10.0.0.31", "user": "deploy", "password": "pw"}},
+		"local_path":  nil,
+		"artifact_id": "a1",
+		"remote_path": "/srv/app",
+		"timeout":     float64(60),
+	}, nil)
+	if err != nil {
+		t.Fatalf("parseUploadPayload() error = %v", err)
+	}
+	if upload.LocalPath != "" || upload.Timeout != 60 {
+		t.Fatalf("parseUploadPayload() local_path = %q, timeout = %d, want empty and 60", upload.LocalPath, upload.Timeout)
+	}
+}
@@ -0,0 +1,69 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"gocerery/internal/config"
+	"gocerery/internal/taskstate"
+)
+
+// failingExecutor 返回部分结果与错误，模拟执行中途失败
+type failingExecutor struct{}
+
+func (failingExecutor) Execute(context.Context, *taskPayload, int, hostReporter) ([]HostResult, error) {
+	return []HostResult{{Host: "10.0.0.31", Error: "connection reset"}}, errors.New("executor crashed")
+}
+
+func (failingExecutor) Upload(context.Context, *uploadTaskPayload, int, uploadReporter) ([]UploadResult, error) {
+	return []UploadResult{{Host: "10.0.0.31", Error: "connection reset"}}, errors.New("executor crashed")
+}
+
+func (failingExecutor) Download(context.Context, *downloadTaskPayload, int, downloadReporter) ([]DownloadResult, error) {
+	return []DownloadResult{{Host: "10.0.0.31", Error: "connection reset"}}, errors.New("executor crashed")
+}
+
+func TestExecutorFailureStoresFailureStatus(t *testing.T) {
+	state, backend := newTestStateStore(t)
+	r := &Runner{executor: failingExecutor{}, state: state, timeout: 10, concurrency: 1, cfg: &config.Config{Downloads: config.DownloadConfig{Dir: t.TempDir()}}}
+	target := map[string]interface{}{"host": "10.0.0.31", "user": "deploy", "password": "pw"}
+
+	tests := []struct {
+		name string
+		run  func(payload map[string]interface{}) (interface{}, error)
+		data map[string]interface{}
+	}{
+		{name: "ssh", run: r.execute, data: map[string]interface{}{"commands": []interface{}{"uptime"}}},
+		{name: "upload", run: r.executeUpload, data: map[string]interface{}{"local_path": t.TempDir(), "remote_path": "/srv/app"}},
+		{name: "download", run: r.executeDownload, data: map[string]interface{}{"remote_paths": []interface{}{"/var/log/app.log"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			taskID := "task-" + tt.name
+			payload := map[string]interface{}{taskIDKwarg: taskID, "targets": []interface{}{target}}
+			for k, v := range tt.data {
+				payload[k] = v
+			}
+			if _, err := tt.run(payload); err == nil {
+				t.Fatal("task succeeded, want executor error")
+			}
+			msg, err := backend.GetResult(taskID)
+			if err != nil {
+				t.Fatalf("GetResult() error = %v", err)
+			}
+			if msg.Status != taskstate.StatusFailure {
+				t.Fatalf("status = %s, want %s", msg.Status, taskstate.StatusFailure)
+			}
+			raw, _ := json.Marshal(msg.Result)
+			var results []struct {
+				Host  string `json:"host"`
+				Error string `json:"error"`
+			}
+			if err := json.Unmarshal(raw, &results); err != nil || len(results) != 1 || results[0].Error != "connection reset" {
+				t.Fatalf("stored results = %s, want the partial result", raw)
+			}
+		})
+	}
+}
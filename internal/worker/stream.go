@@ -0,0 +1,169 @@
+package worker
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+
+	"gocerery/internal/taskstate"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// streamBuffer 待发布事件的缓冲数量，写满时执行器等待发布
+const streamBuffer = 1024
+
+// maxLineSize 单行输出的最大长度，超出部分作为新的一行发布
+const maxLineSize = 64 << 10
+
+// outputLine 命令输出的一行
+type outputLine struct {
+	Command int    // 命令序号（从 1 开始）
+	Stream  string // stdout 或 stderr
+	Line    string
+}
+
+// hostPosition 主机上次发布的状态，只在变化时发布 state 事件
+type hostPosition struct {
+	state   string
+	command int
+}
+
+// taskStream 把主机状态变化与命令输出按顺序发布到 Redis pub/sub，
+// 由 API 的 SSE 接口转发给客户端
+type taskStream struct {
+	state  *taskstate.Store
+	taskID string
+
+	mu     sync.Mutex
+	last   []hostPosition
+	closed bool
+
+	events chan taskstate.StreamEvent
+	done   chan struct{}
+}
+
+func newTaskStream(state *taskstate.Store, taskID string, hosts int) *taskStream {
+	s := &taskStream{
+		state:  state,
+		taskID: taskID,
+		last:   make([]hostPosition, hosts),
+		done:   make(chan struct{}),
+	}
+	if state == nil || taskID == "" {
+		s.closed = true
+		close(s.done)
+		return s
+	}
+	s.events = make(chan taskstate.StreamEvent, streamBuffer)
+	go s.loop()
+	return s
+}
+
+// hostState 主机状态或当前命令变化时发布 state 事件
+func (s *taskStream) hostState(idx int, result HostResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if idx < 0 || idx >= len(s.last) {
+		return
+	}
+	pos := hostPosition{state: result.State, command: result.CurrentCommand}
+	if s.last[idx] == pos {
+		return
+	}
+	s.last[idx] = pos
+	ev := taskstate.StreamEvent{
+		Type:    taskstate.EventState,
+		Index:   idx,
+		Name:    result.Name,
+		Host:    result.Host,
+		State:   result.State,
+		Command: result.CurrentCommand,
+	}
+	if result.State == taskstate.HostDone {
+		ev.Error = result.Error
+	}
+	s.send(ev)
+}
+
+// output 发布一行命令输出
+func (s *taskStream) output(idx int, line outputLine) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.send(taskstate.StreamEvent{
+		Type:    taskstate.EventOutput,
+		Index:   idx,
+		Command: line.Command,
+		Stream:  line.Stream,
+		Line:    line.Line,
+	})
+}
+
+// send 调用方需持有 mu；关闭后到达的输出（如超时后远端仍在写入）直接丢弃
+func (s *taskStream) send(ev taskstate.StreamEvent) {
+	if s.closed {
+		return
+	}
+	s.events <- ev
+}
+
+// close 发布 end 事件并等待缓冲中的事件发布完成
+func (s *taskStream) close(status string) {
+	s.mu.Lock()
+	if !s.closed {
+		s.send(taskstate.StreamEvent{Type: taskstate.EventEnd, Status: status})
+		s.closed = true
+		close(s.events)
+	}
+	s.mu.Unlock()
+	<-s.done
+}
+
+func (s *taskStream) loop() {
+	defer close(s.done)
+	failed := false
+	for ev := range s.events {
+		if err := s.state.Publish(s.taskID, ev); err != nil && !failed {
+			// 只记录第一次失败，避免每行输出都打印错误
+			failed = true
+			logx.Errorw("[WORKER] failed to publish stream event",
+				logx.Field("task_id", s.taskID),
+				logx.Field("error", err))
+		}
+	}
+}
+
+// lineWriter 把写入的数据按行切分后回调 emit，未结束的行留到下次写入或 flush；
+// 每个实例只能由一个 goroutine 写入
+type lineWriter struct {
+	emit func(line string)
+	buf  []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			if len(w.buf) >= maxLineSize {
+				w.emitLine(w.buf)
+				w.buf = w.buf[:0]
+			}
+			return len(p), nil
+		}
+		w.emitLine(w.buf[:i])
+		w.buf = w.buf[i+1:]
+	}
+}
+
+// flush 输出剩余的不完整行
+func (w *lineWriter) flush() {
+	if len(w.buf) > 0 {
+		w.emitLine(w.buf)
+		w.buf = nil
+	}
+}
+
+func (w *lineWriter) emitLine(line []byte) {
+	w.emit(strings.ToValidUTF8(strings.TrimSuffix(string(line), "\r"), ""))
+}
@@ -0,0 +1,174 @@
+package worker
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// UploadModeOverwrite 每次都上传并覆盖远端文件（默认）
+	UploadModeOverwrite = "overwrite"
+	// UploadModeSkipExisting 远端已存在同名文件时跳过
+	UploadModeSkipExisting = "skip_existing"
+	// UploadModeSync 只上传有变化的文件，上传后用 sha256sum 校验
+	UploadModeSync = "sync"
+)
+
+const (
+	// SyncCompareSizeMtime 按大小与修改时间判断文件是否变化（默认）
+	SyncCompareSizeMtime = "size_mtime"
+	// SyncCompareSHA256 按远端 sha256sum 的结果判断文件是否变化
+	SyncCompareSHA256 = "sha256"
+)
+
+// parseUploadMode 校验 upload_mode 与 sync_compare，返回填充默认值后的结果
+func parseUploadMode(mode, compare string) (string, string, error) {
+	switch mode {
+	case "":
+		mode = UploadModeOverwrite
+	case UploadModeOverwrite, UploadModeSkipExisting, UploadModeSync:
+	default:
+		return "", "", fmt.Errorf("unknown upload_mode: %s", mode)
+	}
+	switch compare {
+	case "":
+		if mode == UploadModeSync {
+			compare = SyncCompareSizeMtime
+		}
+	case SyncCompareSizeMtime, SyncCompareSHA256:
+		if mode != UploadModeSync {
+			return "", "", errors.New("sync_compare requires upload_mode sync")
+		}
+	default:
+		return "", "", fmt.Errorf("unknown sync_compare: %s", compare)
+	}
+	return mode, compare, nil
+}
+
+// fileSyncer 按上传模式处理单个文件，结果累计到 UploadResult
+type fileSyncer struct {
+	ctx       context.Context
+	timeout   time.Duration // 单条远端命令（sha256sum、chown）的超时
+	conn      *ssh.Client   // 在目标主机上执行 sha256sum
+	client    *sftp.Client
+	mode      string
+	compare   string
+	placement filePlacement
+}
+
+func (s fileSyncer) upload(result *UploadResult, localFile, remoteFile string) {
+	local, err := os.Stat(localFile)
+	if err != nil {
+		result.record(FileTransfer{Local: localFile, Remote: remoteFile, Error: err.Error()})
+		return
+	}
+
+	skip, err := s.unchanged(local, localFile, remoteFile)
+	if err != nil {
+		result.record(FileTransfer{Local: localFile, Remote: remoteFile, Error: err.Error()})
+		return
+	}
+	if skip {
+		result.Skipped++
+		return
+	}
+
+	var verify func(ft *FileTransfer, written string)
+	if s.mode == UploadModeSync {
+		verify = func(ft *FileTransfer, written string) { s.verify(result, ft, written, local) }
+	}
+	result.record(s.place(localFile, remoteFile, verify))
+}
+
+// unchanged 判断远端文件是否可以跳过：skip_existing 只看是否存在，sync 按 sync_compare 比较内容
+func (s fileSyncer) unchanged(local fs.FileInfo, localFile, remoteFile string) (bool, error) {
+	if s.mode == UploadModeOverwrite {
+		return false, nil
+	}
+	remote, err := s.client.Stat(remoteFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("stat remote file: %w", err)
+	}
+	if s.mode == UploadModeSkipExisting {
+		return true, nil
+	}
+	if !remote.Mode().IsRegular() || remote.Size() != local.Size() {
+		return false, nil
+	}
+	if s.compare == SyncCompareSizeMtime {
+		return remote.ModTime().Unix() == local.ModTime().Unix(), nil
+	}
+	localSum, err := fileSHA256(localFile)
+	if err != nil {
+		return false, err
+	}
+	remoteSum, err := s.remoteSHA256(remoteFile)
+	if err != nil {
+		return false, err
+	}
+	return localSum == remoteSum, nil
+}
+
+// verify 用远端 sha256sum 校验写入的文件，并把远端修改时间设为与本地一致，供下次 size_mtime 比较
+func (s fileSyncer) verify(result *UploadResult, ft *FileTransfer, written string, local fs.FileInfo) {
+	remoteSum, err := s.remoteSHA256(written)
+	if err != nil {
+		ft.Error = fmt.Sprintf("verify checksum: %v", err)
+		return
+	}
+	if remoteSum != ft.SHA256 {
+		ft.Error = fmt.Sprintf("checksum mismatch: local %s, remote %s", ft.SHA256, remoteSum)
+		return
+	}
+	result.Verified++
+	if err := s.client.Chtimes(written, local.ModTime(), local.ModTime()); err != nil {
+		ft.Error = fmt.Sprintf("set remote mtime: %v", err)
+	}
+}
+
+// remoteSHA256 在目标主机上执行 sha256sum 计算文件摘要
+func (s fileSyncer) remoteSHA256(remoteFile string) (string, error) {
+	out, err := runRemote(s.ctx, s.conn, "sha256sum -- "+shellQuote(remoteFile), s.timeout)
+	if err != nil {
+		return "", err
+	}
+	// 文件名含反斜杠或换行时 sha256sum 会在输出行首加 \
+	fields := strings.Fields(strings.TrimPrefix(string(out), "\\"))
+	if len(fields) == 0 || len(fields[0]) != 64 {
+		return "", fmt.Errorf("unexpected sha256sum output: %q", out)
+	}
+	return fields[0], nil
+}
+
+// shellQuote 用单引号包裹参数，供远端 shell 解析
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// fileSHA256 计算本地文件的 sha256
+func fileSHA256(name string) (string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,35 @@
+package worker
+
+import "testing"
+
+func TestParseUploadMode(t *testing.T) {
+	tests := []struct {
+		mode, compare         string
+		wantMode, wantCompare string
+		wantErr               bool
+	}{
+		{wantMode: UploadModeOverwrite},
+		{mode: UploadModeOverwrite, wantMode: UploadModeOverwrite},
+		{mode: UploadModeSkipExisting, wantMode: UploadModeSkipExisting},
+		{mode: UploadModeSync, wantMode: UploadModeSync, wantCompare: SyncCompareSizeMtime},
+		{mode: UploadModeSync, compare: SyncCompareSHA256, wantMode: UploadModeSync, wantCompare: SyncCompareSHA256},
+		{mode: UploadModeSync, compare: SyncCompareSizeMtime, wantMode: UploadModeSync, wantCompare: SyncCompareSizeMtime},
+		{mode: "mirror", wantErr: true},
+		{mode: UploadModeSync, compare: "md5", wantErr: true},
+		{compare: SyncCompareSHA256, wantErr: true},
+		{mode: UploadModeSkipExisting, compare: SyncCompareSizeMtime, wantErr: true},
+	}
+	for _, tt := range tests {
+		mode, compare, err := parseUploadMode(tt.mode, tt.compare)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseUploadMode(%q, %q) succeeded, want error", tt.mode, tt.compare)
+			}
+			continue
+		}
+		if err != nil || mode != tt.wantMode || compare != tt.wantCompare {
+			t.Errorf("parseUploadMode(%q, %q) = %q, %q, %v, want %q, %q",
+				tt.mode, tt.compare, mode, compare, err, tt.wantMode, tt.wantCompare)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package worker
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// backupTimeFormat 备份文件名中的时间戳格式：<name>.<时间戳>.bak
+const backupTimeFormat = "20060102150405"
+
+// accountNamePattern 远端用户名或组名，也可以是数字 ID
+var accountNamePattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,31}$`)
+
+// filePlacement 上传文件的落盘方式：权限、属主、原子替换与旧版本备份
+type filePlacement struct {
+	Mode           string // 八进制权限，如 0644；为空时使用远端默认权限
+	Owner          string
+	Group          string
+	Atomic         bool // 先写入同目录下的临时文件，设置好权限与属主后再 rename 到目标路径
+	BackupExisting bool // 覆盖前把远端已有文件保留为 <name>.<时间戳>.bak
+}
+
+func parseFilePlacement(data map[string]interface{}) (filePlacement, error) {
+	p := filePlacement{
+		Mode:  stringValue(data["mode"]),
+		Owner: stringValue(data["owner"]),
+		Group: stringValue(data["group"]),
+	}
+	p.Atomic, _ = data["atomic"].(bool)
+	p.BackupExisting, _ = data["backup_existing"].(bool)
+	if p.Mode != "" {
+		if _, err := parseFileMode(p.Mode); err != nil {
+			return filePlacement{}, err
+		}
+	}
+	if p.Owner != "" && !accountNamePattern.MatchString(p.Owner) {
+		return filePlacement{}, fmt.Errorf("invalid owner: %s", p.Owner)
+	}
+	if p.Group != "" && !accountNamePattern.MatchString(p.Group) {
+		return filePlacement{}, fmt.Errorf("invalid group: %s", p.Group)
+	}
+	return p, nil
+}
+
+func parseFileMode(s string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || mode > 0o7777 {
+		return 0, fmt.Errorf("mode must be an octal permission such as 0644: %s", s)
+	}
+	// sftp 的 Chmod 使用 os.FileMode，需要把 setuid 等位转换过去
+	perm := os.FileMode(mode & 0o777)
+	if mode&0o4000 != 0 {
+		perm |= os.ModeSetuid
+	}
+	if mode&0o2000 != 0 {
+		perm |= os.ModeSetgid
+	}
+	if mode&0o1000 != 0 {
+		perm |= os.ModeSticky
+	}
+	return perm, nil
+}
+
+// place 写入单个文件并按 filePlacement 设置权限、属主；atomic 时写入临时文件，
+// afterWrite（sync 模式的校验）通过后再替换目标文件
+func (s fileSyncer) place(localFile, remoteFile string, afterWrite func(ft *FileTransfer, written string)) FileTransfer {
+	p := s.placement
+	written := remoteFile
+	var existing fs.FileInfo
+	if p.Atomic || p.BackupExisting {
+		info, err := s.client.Stat(remoteFile)
+		switch {
+		case err == nil:
+			existing = info
+		case !errors.Is(err, fs.ErrNotExist):
+			return FileTransfer{Local: localFile, Remote: remoteFile, Error: fmt.Sprintf("stat remote file: %v", err)}
+		}
+	}
+
+	var backup string
+	if p.Atomic {
+		name, err := tempName(remoteFile)
+		if err != nil {
+			return FileTransfer{Local: localFile, Remote: remoteFile, Error: err.Error()}
+		}
+		written = name
+	} else if p.BackupExisting && existing != nil {
+		// 非原子上传时先把旧文件改名为备份，写入失败再改回
+		backup = backupName(remoteFile)
+		if err := s.client.Rename(remoteFile, backup); err != nil {
+			return FileTransfer{Local: localFile, Remote: remoteFile, Error: fmt.Sprintf("backup existing file: %v", err)}
+		}
+	}
+
+	// 权限与属主在写入内容前设置，上传过程中文件不会以服务端默认权限暴露
+	var attrs FileTransfer
+	opened := false
+	ft := putFile(s.client, localFile, written, func(dst *sftp.File) error {
+		opened = true
+		return s.applyAttrs(&attrs, dst, written, existing)
+	})
+	ft.Remote = remoteFile
+	ft.Atomic = p.Atomic
+	ft.Mode, ft.Owner, ft.Group = attrs.Mode, attrs.Owner, attrs.Group
+	if perm, ok := s.filePerm(existing); ok && ft.Error == "" && perm&(os.ModeSetuid|os.ModeSetgid) != 0 {
+		// 非 root 写入内容会清除 setuid/setgid，写完后再设置一次
+		if err := s.client.Chmod(written, perm); err != nil {
+			ft.Error = fmt.Sprintf("chmod: %v", err)
+		}
+	}
+	if ft.Error == "" && afterWrite != nil {
+		afterWrite(&ft, written)
+	}
+	if ft.Error == "" && p.Atomic {
+		if p.BackupExisting && existing != nil {
+			// 用硬链接保留旧版本，目标路径在替换前始终可用
+			backup = backupName(remoteFile)
+			if err := s.client.Link(remoteFile, backup); err != nil {
+				ft.Error = fmt.Sprintf("backup existing file: %v", err)
+				backup = ""
+			}
+		}
+		if ft.Error == "" {
+			if err := s.client.PosixRename(written, remoteFile); err != nil {
+				ft.Error = fmt.Sprintf("rename into place: %v", err)
+			}
+		}
+	}
+
+	if ft.Error != "" {
+		if p.Atomic {
+			s.client.Remove(written)
+		} else if backup != "" && s.client.PosixRename(backup, remoteFile) == nil {
+			backup = ""
+		} else if opened {
+			// 没有可恢复的备份时删除已截断、未写完或未通过校验的文件，不在目标路径留下内容不一致的文件
+			s.client.Remove(remoteFile)
+		}
+	}
+	ft.Backup = backup
+	return ft
+}
+
+// filePerm 要设置的权限；atomic 且未指定 mode 时沿用被替换文件的权限
+func (s fileSyncer) filePerm(existing fs.FileInfo) (os.FileMode, bool) {
+	p := s.placement
+	switch {
+	case p.Mode != "":
+		perm, _ := parseFileMode(p.Mode)
+		return perm, true
+	case p.Atomic && existing != nil:
+		return existing.Mode() & (fs.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky), true
+	}
+	return 0, false
+}
+
+// applyAttrs 在写入内容前设置属主与权限；chown 会清除 setuid/setgid，所以先 chown 再 chmod
+func (s fileSyncer) applyAttrs(ft *FileTransfer, dst *sftp.File, written string, existing fs.FileInfo) error {
+	p := s.placement
+	if p.Owner != "" || p.Group != "" {
+		// 远端用户名与组名只能由目标主机解析，交给 chown/chgrp 处理
+		cmd := "chown -- " + shellQuote(p.Owner+groupSuffix(p.Group)) + " " + shellQuote(written)
+		if p.Owner == "" {
+			cmd = "chgrp -- " + shellQuote(p.Group) + " " + shellQuote(written)
+		}
+		if _, err := runRemote(s.ctx, s.conn, cmd, s.timeout); err != nil {
+			return err
+		}
+		ft.Owner, ft.Group = p.Owner, p.Group
+	}
+
+	if perm, ok := s.filePerm(existing); ok {
+		if err := dst.Chmod(perm); err != nil {
+			return fmt.Errorf("chmod: %w", err)
+		}
+		ft.Mode = formatFileMode(perm)
+	}
+	return nil
+}
+
+func groupSuffix(group string) string {
+	if group == "" {
+		return ""
+	}
+	return ":" + group
+}
+
+func formatFileMode(perm os.FileMode) string {
+	mode := uint32(perm.Perm())
+	if perm&os.ModeSetuid != 0 {
+		mode |= 0o4000
+	}
+	if perm&os.ModeSetgid != 0 {
+		mode |= 0o2000
+	}
+	if perm&os.ModeSticky != 0 {
+		mode |= 0o1000
+	}
+	return fmt.Sprintf("%04o", mode)
+}
+
+// tempName 与目标文件位于同一目录的临时文件名，保证 rename 不跨文件系统
+func tempName(remoteFile string) (string, error) {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate temp file name: %w", err)
+	}
+	dir, name := path.Split(remoteFile)
+	return dir + "." + name + ".gocerery-" + hex.EncodeToString(b) + ".tmp", nil
+}
+
+func backupName(remoteFile string) string {
+	return remoteFile + "." + time.Now().Format(backupTimeFormat) + ".bak"
+}
+
+// runRemote 在目标主机上执行命令，失败时返回带 stderr 的错误；
+// 超时或任务取消时关闭会话，不等待卡住的远端命令
+func runRemote(ctx context.Context, conn *ssh.Client, cmd string, timeout time.Duration) ([]byte, error) {
+	session, err := conn.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("open session: %w", err)
+	}
+	defer session.Close()
+	var stderr lockedBuffer
+	session.Stderr = &stderr
+	name, _, _ := strings.Cut(cmd, " ")
+
+	type output struct {
+		out []byte
+		err error
+	}
+	done := make(chan output, 1)
+	go func() {
+		out, err := session.Output(cmd)
+		done <- output{out, err}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	var res output
+	select {
+	case res = <-done:
+	case <-timer.C:
+		session.Close()
+		return nil, fmt.Errorf("%s: timed out after %s", name, timeout)
+	case <-ctx.Done():
+		session.Close()
+		return nil, fmt.Errorf("%s: %w", name, ctx.Err())
+	}
+	if res.err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if strings.HasPrefix(msg, name+":") {
+			return nil, errors.New(msg)
+		}
+		if msg != "" {
+			return nil, fmt.Errorf("%s: %s", name, msg)
+		}
+		return nil, fmt.Errorf("%s: %w", name, res.err)
+	}
+	return res.out, nil
+}
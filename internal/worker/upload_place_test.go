@@ -0,0 +1,138 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestParseFilePlacement(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    map[string]interface{}
+		want    filePlacement
+		wantErr bool
+	}{
+		{name: "empty", data: map[string]interface{}{}},
+		{name: "all fields",
+			data: map[string]interface{}{"mode": "0640", "owner": "deploy", "group": "www-data", "atomic": true, "backup_existing": true},
+			want: filePlacement{Mode: "0640", Owner: "deploy", Group: "www-data", Atomic: true, BackupExisting: true}},
+		{name: "numeric ids", data: map[string]interface{}{"owner": "1000", "group": "1000"},
+			want: filePlacement{Owner: "1000", Group: "1000"}},
+		{name: "non-bool flags ignored", data: map[string]interface{}{"atomic": "true"}},
+		{name: "mode not octal", data: map[string]interface{}{"mode": "0o644"}, wantErr: true},
+		{name: "mode digit 8", data: map[string]interface{}{"mode": "0685"}, wantErr: true},
+		{name: "mode too large", data: map[string]interface{}{"mode": "17777"}, wantErr: true},
+		{name: "owner injection", data: map[string]interface{}{"owner": "root; rm -rf /"}, wantErr: true},
+		{name: "owner option", data: map[string]interface{}{"owner": "-R"}, wantErr: true},
+		{name: "group with colon", data: map[string]interface{}{"group": "a:b"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseFilePlacement(tt.data)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseFilePlacement(%v) = %+v, want error", tt.data, got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("parseFilePlacement(%v) = %+v, %v, want %+v", tt.data, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFileMode(t *testing.T) {
+	tests := []struct {
+		s    string
+		want os.FileMode
+	}{
+		{s: "644", want: 0o644},
+		{s: "0755", want: 0o755},
+		{s: "4755", want: 0o755 | os.ModeSetuid},
+		{s: "2750", want: 0o750 | os.ModeSetgid},
+		{s: "1777", want: 0o777 | os.ModeSticky},
+	}
+	for _, tt := range tests {
+		if got, err := parseFileMode(tt.s); err != nil || got != tt.want {
+			t.Errorf("parseFileMode(%q) = %v, %v, want %v", tt.s, got, err, tt.want)
+		}
+	}
+}
+
+// startHangingServer 接受 exec 请求但命令永不结束，模拟卡住的远端 shell
+func startHangingServer(t *testing.T) *ssh.Client {
+	t.Helper()
+	serverConfig := &ssh.ServerConfig{NoClientAuth: true}
+	serverConfig.AddHostKey(newHostSigner(t, ssh.KeyAlgoED25519))
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, chans, reqs, err := ssh.NewServerConn(conn, serverConfig)
+				if err != nil {
+					return
+				}
+				go ssh.DiscardRequests(reqs)
+				for newCh := range chans {
+					ch, chReqs, err := newCh.Accept()
+					if err != nil {
+						continue
+					}
+					go func() {
+						defer ch.Close()
+						for req := range chReqs {
+							req.Reply(req.Type == "exec", nil)
+						}
+					}()
+				}
+			}()
+		}
+	}()
+
+	client, err := ssh.Dial("tcp", ln.Addr().String(), &ssh.ClientConfig{
+		User:            "test",
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestRunRemoteDeadline(t *testing.T) {
+	conn := startHangingServer(t)
+
+	start := time.Now()
+	_, err := runRemote(context.Background(), conn, "sha256sum -- 'a'", 100*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "sha256sum: timed out") {
+		t.Errorf("timeout: err = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("timeout: returned after %s", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	_, err = runRemote(ctx, conn, "chown -- 'root' 'a'", time.Minute)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("canceled: err = %v", err)
+	}
+}
@@ -0,0 +1,632 @@
+#!/usr/bin/env python3
+"""
+File download script that connects to target servers, directly or through a
+chain of jump hosts, using Paramiko.
+The script fetches remote files matching glob patterns into a local directory
+per target, keeping their absolute remote paths.
+The Go worker passes jump hosts, targets (with their local_dir) and remote paths
+as a JSON object on stdin (--payload-stdin) so that credentials never appear in
+the process arguments.
+"""
+
+import argparse
+import base64
+import fnmatch
+import hashlib
+import io
+import itertools
+import json
+import logging
+import os
+import posixpath
+import queue
+import stat
+import sys
+import threading
+import time
+from typing import Any, Dict, List
+
+import paramiko
+
+# 配置日志
+def setup_logging(log_level: str = "INFO", log_file: str = None):
+    """设置日志配置"""
+    level = getattr(logging, log_level.upper(), logging.INFO)
+    
+    handlers = [logging.StreamHandler(sys.stderr)]  # 默认输出到 stderr（会被 Go 捕获）
+    
+    if log_file:
+        # 确保日志目录存在
+        log_dir = os.path.dirname(log_file)
+        if log_dir and not os.path.exists(log_dir):
+            os.makedirs(log_dir, exist_ok=True)
+        handlers.append(logging.FileHandler(log_file))
+    
+    logging.basicConfig(
+        level=level,
+        format='%(asctime)s [%(levelname)s] %(name)s: %(message)s',
+        datefmt='%Y-%m-%d %H:%M:%S',
+        handlers=handlers
+    )
+    
+    # 设置 Paramiko 日志级别（可选，用于调试）
+    paramiko_logger = logging.getLogger("paramiko")
+    paramiko_logger.setLevel(logging.WARNING)  # 默认只显示警告和错误
+    
+    return logging.getLogger(__name__)
+
+# 全局日志对象（在 main 中初始化）
+logger = None
+# 是否允许使用 ssh-agent（由 --allow-agent 控制）
+allow_agent = False
+# 主机密钥策略与 known_hosts 文件（由 --host-key-policy/--known-hosts 控制）
+host_key_policy = "accept-new"
+known_hosts_file = "data/known_hosts"
+# 单台主机取回的总大小上限（由 --max-bytes 控制）
+max_bytes = 1 << 30
+known_hosts_lock = threading.Lock()
+# 进度事件输出（由 --events-fd 指定，Go Worker 逐行读取）
+events_stream = None
+events_lock = threading.Lock()
+
+
+def build_result(target: Dict[str, Any]) -> Dict[str, Any]:
+    return {
+        "name": target.get("name"),
+        "host": target.get("host"),
+        "success": True,
+        "files": [],
+        "failed_files": [],
+        "bytes": 0,
+        "error": "",
+    }
+
+
+def emit_event(index: int, state: str, result: Dict[str, Any]) -> None:
+    """更新结果中的 state，并在指定 --events-fd 时输出一行 JSON 进度事件。"""
+    result["state"] = state
+    if events_stream is None:
+        return
+    line = json.dumps({"index": index, "state": state, "result": result}, ensure_ascii=False)
+    with events_lock:
+        try:
+            events_stream.write(line + "\n")
+            events_stream.flush()
+        except (BrokenPipeError, ValueError):
+            pass
+
+
+def load_private_key(key_data: str, passphrase: str = None) -> paramiko.PKey:
+    """Parse a PEM/OpenSSH private key of any supported type."""
+    last_error = None
+    for key_class in (paramiko.Ed25519Key, paramiko.ECDSAKey, paramiko.RSAKey):
+        try:
+            return key_class.from_private_key(io.StringIO(key_data), password=passphrase or None)
+        except paramiko.PasswordRequiredException:
+            raise
+        except paramiko.SSHException as exc:
+            last_error = exc
+    raise paramiko.SSHException(f"unsupported private key: {last_error}")
+
+
+def auth_kwargs(endpoint: Dict[str, Any]) -> Dict[str, Any]:
+    """Build paramiko connect() auth arguments from password/private_key fields."""
+    kwargs: Dict[str, Any] = {
+        "username": endpoint["user"],
+        "allow_agent": allow_agent,
+        "look_for_keys": False,
+    }
+    if endpoint.get("password"):
+        kwargs["password"] = endpoint["password"]
+    if endpoint.get("private_key"):
+        kwargs["pkey"] = load_private_key(endpoint["private_key"], endpoint.get("private_key_passphrase"))
+    return kwargs
+
+
+class HostKeyError(paramiko.SSHException):
+    """主机密钥校验失败，code 写入结果的 error_code 字段。"""
+
+    def __init__(self, code: str, message: str):
+        super().__init__(message)
+        self.code = code
+
+
+def fingerprint_sha256(key: paramiko.PKey) -> str:
+    digest = base64.b64encode(hashlib.sha256(key.asbytes()).digest()).decode().rstrip("=")
+    return f"SHA256:{digest}"
+
+
+def normalize_fingerprint(fingerprint: str) -> str:
+    fingerprint = fingerprint.strip()
+    if fingerprint.startswith("SHA256:"):
+        fingerprint = fingerprint[len("SHA256:"):]
+    return fingerprint.rstrip("=")
+
+
+class PinnedFingerprintPolicy(paramiko.MissingHostKeyPolicy):
+    """只接受与 host_key_fingerprint 一致的主机密钥。"""
+
+    def __init__(self, fingerprint: str):
+        self.fingerprint = fingerprint
+
+    def missing_host_key(self, client, hostname, key):
+        actual = fingerprint_sha256(key)
+        if normalize_fingerprint(actual) != normalize_fingerprint(self.fingerprint):
+            raise HostKeyError("host_key_mismatch",
+                               f"{hostname} presented {actual}, does not match host_key_fingerprint {self.fingerprint}")
+
+
+class StrictPolicy(paramiko.MissingHostKeyPolicy):
+    def missing_host_key(self, client, hostname, key):
+        raise HostKeyError("host_key_unknown",
+                           f"{hostname} presented {fingerprint_sha256(key)}, not found in {known_hosts_file}")
+
+
+class AcceptNewPolicy(paramiko.MissingHostKeyPolicy):
+    """首次连接时把主机密钥追加到 known_hosts，多线程共用同一把锁。"""
+
+    def missing_host_key(self, client, hostname, key):
+        with known_hosts_lock:
+            entry = paramiko.hostkeys.HostKeyEntry([hostname], key)
+            with open(known_hosts_file, "a", encoding="utf-8") as f:
+                f.write(entry.to_line())
+        client.get_host_keys().add(hostname, key.get_name(), key)
+
+
+def new_ssh_client(endpoint: Dict[str, Any]) -> paramiko.SSHClient:
+    """按主机密钥策略创建 SSHClient；设置了 host_key_fingerprint 时只按指纹校验。"""
+    client = paramiko.SSHClient()
+    if endpoint.get("host_key_fingerprint"):
+        client.set_missing_host_key_policy(PinnedFingerprintPolicy(endpoint["host_key_fingerprint"]))
+        return client
+    if host_key_policy == "insecure":
+        client.set_missing_host_key_policy(paramiko.AutoAddPolicy())
+        return client
+
+    with known_hosts_lock:
+        known_hosts_dir = os.path.dirname(known_hosts_file)
+        if known_hosts_dir:
+            os.makedirs(known_hosts_dir, mode=0o700, exist_ok=True)
+        if not os.path.exists(known_hosts_file):
+            os.close(os.open(known_hosts_file, os.O_CREAT | os.O_WRONLY, 0o600))
+        client.load_host_keys(known_hosts_file)
+    if host_key_policy == "strict":
+        client.set_missing_host_key_policy(StrictPolicy())
+    else:
+        client.set_missing_host_key_policy(AcceptNewPolicy())
+    return client
+
+
+def host_key_error_code(exc: Exception) -> str:
+    if isinstance(exc, HostKeyError):
+        return exc.code
+    if isinstance(exc, paramiko.BadHostKeyException):
+        return "host_key_mismatch"
+    return ""
+
+
+def connect_jump_chain(jump_hosts: List[Dict[str, Any]], timeout: int) -> List[paramiko.SSHClient]:
+    """Log in to jump hosts in order, each hop over a direct-tcpip channel of the previous one.
+
+    Clients already connected are closed if a later hop fails.
+    """
+    clients: List[paramiko.SSHClient] = []
+    sock = None
+    try:
+        for i, hop in enumerate(jump_hosts):
+            if logger:
+                logger.info(f"Connecting to jump host {hop['host']}:{hop.get('port', 22)}")
+            client = new_ssh_client(hop)
+            clients.append(client)
+            client.connect(
+                hostname=hop["host"],
+                port=hop.get("port", 22),
+                sock=sock,
+                timeout=timeout,
+                **auth_kwargs(hop),
+            )
+            if i + 1 < len(jump_hosts):
+                sock = open_direct_channel(client, jump_hosts[i + 1])
+    except Exception:
+        close_clients(clients)
+        raise
+    return clients
+
+
+def open_direct_channel(client: paramiko.SSHClient, dest: Dict[str, Any]) -> paramiko.Channel:
+    transport = client.get_transport()
+    if transport is None or not transport.is_active():
+        raise RuntimeError("jump host connection is not active")
+    return transport.open_channel("direct-tcpip", (dest["host"], dest.get("port", 22)), ("127.0.0.1", 0))
+
+
+def close_clients(clients: List[paramiko.SSHClient]) -> None:
+    for client in reversed(clients):
+        client.close()
+
+
+class JumpPool:
+    """任务内共享的跳板机链连接池：最多建立 size 条跳板机链，各目标主机轮流在其上打开
+    direct-tcpip 通道，避免每台目标主机都重新与跳板机握手。没有跳板机时直连目标主机。"""
+
+    def __init__(self, jump_hosts: List[Dict[str, Any]], size: int, timeout: int):
+        self.jump_hosts = jump_hosts
+        self.timeout = timeout
+        self.slots = [{"lock": threading.Lock(), "clients": [], "error": None} for _ in range(max(1, size))]
+        self.counter = itertools.count()
+
+    def open_channel(self, target: Dict[str, Any]):
+        """返回到目标主机的通道；没有跳板机时返回 None 表示直连。"""
+        if not self.jump_hosts:
+            return None
+        slot = self.slots[next(self.counter) % len(self.slots)]
+        with slot["lock"]:
+            # 跳板机链建立失败后不再重试，分配到该链的目标主机直接返回同一错误
+            if slot["error"] is not None:
+                raise slot["error"]
+            clients = slot["clients"]
+            transport = clients[-1].get_transport() if clients else None
+            if transport is None or not transport.is_active():
+                # 尚未建立或连接已断开时重新建立
+                close_clients(clients)
+                slot["clients"] = []
+                try:
+                    slot["clients"] = connect_jump_chain(self.jump_hosts, self.timeout)
+                except Exception as exc:
+                    slot["error"] = exc
+                    raise
+            last = slot["clients"][-1]
+        return open_direct_channel(last, target)
+
+    def close(self) -> None:
+        for slot in self.slots:
+            with slot["lock"]:
+                close_clients(slot["clients"])
+                slot["clients"] = []
+
+
+def connect_target(pool: JumpPool, target: Dict[str, Any], timeout: int) -> paramiko.SSHClient:
+    """Connect to target through the task's jump host pool (directly when there are no jump hosts)."""
+    sock = pool.open_channel(target)
+    if logger:
+        logger.info(f"Connecting to target {target['host']}:{target.get('port', 22)}"
+                    f" via {len(pool.jump_hosts)} jump host(s)")
+
+    target_client = new_ssh_client(target)
+    try:
+        target_client.connect(
+            hostname=target["host"],
+            port=target.get("port", 22),
+            sock=sock,
+            timeout=timeout,
+            **auth_kwargs(target),
+        )
+    except Exception:
+        target_client.close()
+        raise
+
+    if logger:
+        logger.info(f"Successfully connected to target {target['host']}")
+
+    return target_client
+
+
+class DownloadLimitError(Exception):
+    """单台主机取回的总大小超过 --max-bytes。"""
+
+    def __init__(self):
+        super().__init__("download size limit exceeded (Downloads.MaxSizeMB)")
+
+
+def local_rel(remote: str) -> str:
+    """远程路径在主机目录下的相对路径；先规范化为绝对路径，保证不会越出主机目录"""
+    return posixpath.normpath("/" + remote).lstrip("/")
+
+
+def has_magic(segment: str) -> bool:
+    return any(c in segment for c in "*?[")
+
+
+def remote_glob(sftp, pattern: str) -> List[str]:
+    """按路径段展开通配，结果按字典序排列，与 Go 版本的 sftp.Client.Glob 一致；
+    不含通配符时原样返回存在的路径"""
+    pattern = posixpath.normpath(pattern)
+    if not has_magic(pattern):
+        try:
+            sftp.lstat(pattern)
+        except FileNotFoundError:
+            return []
+        return [pattern]
+    paths = ["/"]
+    for segment in pattern.strip("/").split("/"):
+        expanded = []
+        for base in paths:
+            if not has_magic(segment):
+                candidate = posixpath.join(base, segment)
+                try:
+                    sftp.stat(candidate)
+                except FileNotFoundError:
+                    continue
+                expanded.append(candidate)
+                continue
+            try:
+                names = sftp.listdir(base)
+            except (FileNotFoundError, NotADirectoryError, OSError):
+                continue
+            for name in sorted(names):
+                if fnmatch.fnmatchcase(name, segment):
+                    expanded.append(posixpath.join(base, name))
+        paths = expanded
+    return paths
+
+
+def walk_files(sftp, top: str):
+    """递归列出目录下的普通文件，按 lstat 判断类型，不跟随符号链接"""
+    try:
+        entries = sftp.listdir_attr(top)
+    except Exception as e:  # pylint: disable=broad-except
+        yield top, None, e
+        return
+    for attr in sorted(entries, key=lambda a: a.filename):
+        remote = posixpath.join(top, attr.filename)
+        mode = attr.st_mode or 0
+        if stat.S_ISDIR(mode):
+            yield from walk_files(sftp, remote)
+        elif stat.S_ISREG(mode):
+            yield remote, attr, None
+
+
+def get_file(sftp, remote_file: str, local_dir: str, limit: int) -> Dict[str, Any]:
+    """Download a single file into local_dir and record its size, duration and sha256;
+    删除超过 limit 字节的文件并记录 DownloadLimitError"""
+    entry: Dict[str, Any] = {"remote": remote_file, "path": local_rel(remote_file), "bytes": 0, "duration_ms": 0}
+    start = time.monotonic()
+    local_file = os.path.join(local_dir, *entry["path"].split("/"))
+    try:
+        os.makedirs(os.path.dirname(local_file), mode=0o700, exist_ok=True)
+        digest = hashlib.sha256()
+        with sftp.open(remote_file, "rb") as src, \
+                open(os.open(local_file, os.O_WRONLY | os.O_CREAT | os.O_TRUNC, 0o600), "wb") as dst:
+            src.prefetch()
+            received = 0
+            for chunk in iter(lambda: src.read(1 << 20), b""):
+                received += len(chunk)
+                if received > limit:
+                    raise DownloadLimitError()
+                digest.update(chunk)
+                dst.write(chunk)
+        entry["bytes"] = received
+        entry["sha256"] = digest.hexdigest()
+        try:
+            mtime = sftp.stat(remote_file).st_mtime
+            if mtime is not None:
+                os.utime(local_file, (mtime, mtime))
+        except Exception:  # pylint: disable=broad-except
+            pass
+    except Exception as e:  # pylint: disable=broad-except
+        entry["error"] = str(e)
+        try:
+            os.remove(local_file)
+        except OSError:
+            pass
+    entry["duration_ms"] = int((time.monotonic() - start) * 1000)
+    return entry
+
+
+def record(result: Dict[str, Any], entry: Dict[str, Any]) -> None:
+    if entry.get("error"):
+        result["failed_files"].append(entry)
+        result["success"] = False
+        if logger:
+            logger.warning(f"Failed to download {entry['remote']} from {result['name']}: {entry['error']}")
+        return
+    result["files"].append(entry)
+    result["bytes"] += entry["bytes"]
+
+
+def download_files(
+    pool: JumpPool,
+    target: Dict[str, Any],
+    remote_paths: List[str],
+    timeout: int,
+    index: int,
+) -> Dict[str, Any]:
+    """Fetch files matching remote_paths from a target into its local_dir."""
+    result = build_result(target)
+    target_client = None
+    sftp = None
+    local_dir = target["local_dir"]
+
+    target_name = target.get("name") or target.get("host", "unknown")
+
+    try:
+        if logger:
+            logger.info(f"Starting file download from {target_name} ({target.get('host')})")
+            logger.info(f"Remote paths: {remote_paths}, Local dir: {local_dir}")
+
+        emit_event(index, "connecting", result)
+        target_client = connect_target(pool, target, timeout)
+        sftp = target_client.open_sftp()
+        emit_event(index, "running", result)
+
+        # 多个通配可能匹配到同一文件，只下载一次
+        seen = set()
+        last_event = time.monotonic()
+
+        def fetch(remote: str, size: int) -> None:
+            nonlocal last_event
+            if remote in seen:
+                return
+            seen.add(remote)
+            remaining = max_bytes - result["bytes"]
+            if size > remaining:
+                error = DownloadLimitError()
+                record(result, {"remote": remote, "path": local_rel(remote), "bytes": 0, "duration_ms": 0,
+                                "error": str(error)})
+                raise error
+            if logger:
+                logger.debug(f"Downloading {remote} from {target_name}")
+            # 文件在 stat 之后可能继续增长，读取时仍按剩余额度截断
+            entry = get_file(sftp, remote, local_dir, remaining)
+            record(result, entry)
+            if entry.get("error") == str(DownloadLimitError()):
+                raise DownloadLimitError()
+            # 文件较多时按间隔上报进度
+            if time.monotonic() - last_event >= 0.5:
+                last_event = time.monotonic()
+                emit_event(index, "running", result)
+
+        try:
+            for pattern in remote_paths:
+                matches = remote_glob(sftp, pattern)
+                if not matches:
+                    record(result, {"remote": pattern, "path": "", "bytes": 0, "duration_ms": 0,
+                                    "error": "no such file or directory"})
+                    continue
+                for match in matches:
+                    # 不跟随符号链接：通配匹配到的链接与目录内的链接一样跳过，直接指定的链接报错
+                    try:
+                        attr = sftp.lstat(match)
+                    except Exception as e:  # pylint: disable=broad-except
+                        record(result, {"remote": match, "path": local_rel(match), "bytes": 0, "duration_ms": 0,
+                                        "error": str(e)})
+                        continue
+                    mode = attr.st_mode or 0
+                    if stat.S_ISLNK(mode):
+                        if not has_magic(pattern):
+                            record(result, {"remote": match, "path": local_rel(match), "bytes": 0, "duration_ms": 0,
+                                            "error": "symbolic link is not followed"})
+                    elif stat.S_ISREG(mode):
+                        fetch(match, attr.st_size or 0)
+                    elif stat.S_ISDIR(mode):
+                        for remote, file_attr, error in walk_files(sftp, match):
+                            if error is not None:
+                                record(result, {"remote": remote, "path": local_rel(remote), "bytes": 0,
+                                                "duration_ms": 0, "error": str(error)})
+                                continue
+                            fetch(remote, file_attr.st_size or 0)
+        except DownloadLimitError as exc:
+            result["success"] = False
+            result["error"] = str(exc)
+
+        if result["failed_files"]:
+            result["success"] = False
+            if not result["error"]:
+                result["error"] = f"{len(result['failed_files'])} file(s) failed to download"
+
+    except Exception as exc:  # pylint: disable=broad-except
+        error_msg = f"{type(exc).__name__}: {exc}"
+        result["success"] = False
+        result["error"] = error_msg
+        error_code = host_key_error_code(exc)
+        if error_code:
+            result["error_code"] = error_code
+        if logger:
+            logger.error(f"Error downloading files from {target_name}: {error_msg}", exc_info=True)
+    finally:
+        emit_event(index, "done", result)
+        if sftp:
+            sftp.close()
+        if target_client:
+            target_client.close()
+        if logger:
+            logger.debug(f"Closed connections for {target_name}")
+
+    if logger:
+        logger.info(f"File download from {target_name} completed, success={result['success']}, "
+                    f"files={len(result['files'])}, bytes={result['bytes']}, failed={len(result['failed_files'])}")
+
+    return result
+
+
+def worker(
+    task_queue: "queue.Queue[Dict[str, Any]]",
+    pool: JumpPool,
+    remote_paths: List[str],
+    timeout: int,
+    output: List[Dict[str, Any]],
+):
+    """Worker thread for concurrent file downloads."""
+    while True:
+        try:
+            item = task_queue.get_nowait()
+        except queue.Empty:
+            return
+        index, target = item
+        # Go Worker 按下标对应主机目录，结果按目标主机顺序输出
+        output[index] = download_files(pool, target, remote_paths, timeout, index)
+        task_queue.task_done()
+
+
+def main() -> int:
+    global logger, events_stream, allow_agent, host_key_policy, known_hosts_file, max_bytes
+
+    parser = argparse.ArgumentParser(description="Download files via jump hosts using Paramiko.")
+    parser.add_argument("--payload-stdin", action="store_true", required=True,
+                        help="Read jump_hosts/targets/remote_paths as a JSON object from stdin, "
+                             "keeping credentials out of argv. Each target carries its local_dir.")
+    parser.add_argument("--max-bytes", type=int, default=1 << 30,
+                        help="Max total bytes fetched from a single target (default: 1 GiB).")
+    parser.add_argument("--concurrency", type=int, default=1, help="Max number of concurrent target connections.")
+    parser.add_argument("--jump-pool-size", type=int, default=1,
+                        help="Number of jump host chains shared by all targets of the task (default: 1).")
+    parser.add_argument("--timeout", type=int, default=120, help="Timeout per SSH operation in seconds.")
+    parser.add_argument("--log-level", default="INFO", choices=["DEBUG", "INFO", "WARNING", "ERROR"],
+                        help="Log level (default: INFO)")
+    parser.add_argument("--log-file", help="Log file path (optional)")
+    parser.add_argument("--events-fd", type=int, help="Write JSON-line progress events to this file descriptor.")
+    parser.add_argument("--allow-agent", action="store_true", help="Also try keys from ssh-agent (SSH_AUTH_SOCK).")
+    parser.add_argument("--host-key-policy", default="accept-new", choices=["strict", "accept-new", "insecure"],
+                        help="Host key verification policy (default: accept-new)")
+    parser.add_argument("--known-hosts", default="data/known_hosts", help="known_hosts file used by strict/accept-new.")
+    args = parser.parse_args()
+    payload = json.load(sys.stdin)
+
+    # 初始化日志
+    logger = setup_logging(args.log_level, args.log_file)
+    allow_agent = args.allow_agent
+    if args.events_fd is not None:
+        events_stream = os.fdopen(args.events_fd, "w", encoding="utf-8")
+    host_key_policy = args.host_key_policy
+    known_hosts_file = args.known_hosts
+    max_bytes = args.max_bytes
+
+    pool = JumpPool(payload.get("jump_hosts") or [], args.jump_pool_size, args.timeout)
+    targets = payload.get("targets") or []
+    remote_paths = payload.get("remote_paths") or []
+
+    if not targets:
+        raise ValueError("targets cannot be empty")
+    if not remote_paths:
+        raise ValueError("remote_paths cannot be empty")
+    for target in targets:
+        if not target.get("local_dir"):
+            raise ValueError(f"local_dir is required for target {target.get('host')}")
+
+    task_queue: "queue.Queue[Dict[str, Any]]" = queue.Queue()
+    for index, target in enumerate(targets):
+        task_queue.put((index, target))
+
+    results: List[Dict[str, Any]] = [None] * len(targets)
+    threads: List[threading.Thread] = []
+    worker_count = max(1, args.concurrency)
+
+    for _ in range(worker_count):
+        thread = threading.Thread(
+            target=worker,
+            args=(task_queue, pool, remote_paths, args.timeout, results),
+            daemon=True,
+        )
+        thread.start()
+        threads.append(thread)
+
+    for thread in threads:
+        thread.join()
+    pool.close()
+
+    print(json.dumps(results, ensure_ascii=False))
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -1,17 +1,25 @@
 #!/usr/bin/env python3
 """
-Executor script that connects to target servers via a bastion host using Paramiko.
-The script expects JSON payloads passed through CLI arguments so that the Go
-service can invoke it securely.
+Executor script that connects to target servers, directly or through a
+chain of jump hosts, using Paramiko.
+The Go worker passes jump hosts, targets and commands as a JSON object on
+stdin (--payload-stdin) so that credentials never appear in the process
+arguments; --jump-hosts/--targets/--commands remain for manual use.
 """
 
 import argparse
+import base64
+import hashlib
+import io
+import itertools
 import json
 import logging
 import os
 import queue
 import sys
 import threading
+import time
+from datetime import datetime, timezone
 from typing import Any, Dict, List
 
 import paramiko
@@ -45,6 +53,18 @@ def setup_logging(log_level: str = "INFO", log_file: str = None):
 
 # 全局日志对象（在 main 中初始化）
 logger = None
+# 是否允许使用 ssh-agent（由 --allow-agent 控制）
+allow_agent = False
+# 主机密钥策略与 known_hosts 文件（由 --host-key-policy/--known-hosts 控制）
+host_key_policy = "accept-new"
+known_hosts_file = "data/known_hosts"
+known_hosts_lock = threading.Lock()
+# 进度事件输出（由 --events-fd 指定，Go Worker 逐行读取）
+events_stream = None
+events_lock = threading.Lock()
+# 命令失败策略（stop/continue/ignore）与各命令允许的退出码（由 --on-error/--allowed-exit-codes 控制）
+on_error = "stop"
+allowed_exit_codes: List[List[int]] = []
 
 
 def build_result(target: Dict[str, Any]) -> Dict[str, Any]:
@@ -56,61 +76,288 @@ def build_result(target: Dict[str, Any]) -> Dict[str, Any]:
         "stderr": "",
         "exit_code": 0,
         "error": "",
+        "commands": [],
     }
 
 
-def connect_via_bastion(bastion: Dict[str, Any], target: Dict[str, Any], timeout: int):
-    if logger:
-        logger.info(f"Connecting to bastion {bastion['host']}:{bastion.get('port', 22)}")
-    
-    bastion_client = paramiko.SSHClient()
-    bastion_client.set_missing_host_key_policy(paramiko.AutoAddPolicy())
-    bastion_client.connect(
-        hostname=bastion["host"],
-        port=bastion.get("port", 22),
-        username=bastion["user"],
-        password=bastion["password"],
-        timeout=timeout,
-    )
+def utc_now() -> str:
+    return datetime.now(timezone.utc).isoformat(timespec="microseconds").replace("+00:00", "Z")
 
-    transport = bastion_client.get_transport()
-    if transport is None:
-        if logger:
-            logger.error("Unable to obtain bastion transport")
-        raise RuntimeError("unable to obtain bastion transport")
 
-    dest_addr = (target["host"], target.get("port", 22))
-    local_addr = ("127.0.0.1", 0)
-    channel = transport.open_channel("direct-tcpip", dest_addr, local_addr)
+def emit_event(index: int, state: str, result: Dict[str, Any]) -> None:
+    """更新结果中的 state，并在指定 --events-fd 时输出一行 JSON 进度事件。"""
+    result["state"] = state
+    if events_stream is None:
+        return
+    line = json.dumps({"index": index, "state": state, "result": result}, ensure_ascii=False)
+    with events_lock:
+        try:
+            events_stream.write(line + "\n")
+            events_stream.flush()
+        except (BrokenPipeError, ValueError):
+            pass
+
+
+def emit_output(index: int, command: int, stream: str, line: str) -> None:
+    """输出一行命令输出事件，Go Worker 转发到实时输出流。"""
+    if events_stream is None:
+        return
+    event = {"index": index, "command": command, "stream": stream, "line": line}
+    with events_lock:
+        try:
+            events_stream.write(json.dumps(event, ensure_ascii=False) + "\n")
+            events_stream.flush()
+        except (BrokenPipeError, ValueError):
+            pass
+
+
+def pump_output(recv, on_line) -> bytes:
+    """持续读取通道输出直到 EOF，每读到完整的一行回调 on_line，返回全部输出。"""
+    chunks = []
+    pending = b""
+    while True:
+        data = recv(32768)
+        if not data:
+            break
+        chunks.append(data)
+        pending += data
+        *lines, pending = pending.split(b"\n")
+        for line in lines:
+            on_line(line.rstrip(b"\r").decode(errors="ignore"))
+    if pending:
+        on_line(pending.rstrip(b"\r").decode(errors="ignore"))
+    return b"".join(chunks)
+
+
+def load_private_key(key_data: str, passphrase: str = None) -> paramiko.PKey:
+    """Parse a PEM/OpenSSH private key of any supported type."""
+    last_error = None
+    for key_class in (paramiko.Ed25519Key, paramiko.ECDSAKey, paramiko.RSAKey):
+        try:
+            return key_class.from_private_key(io.StringIO(key_data), password=passphrase or None)
+        except paramiko.PasswordRequiredException:
+            raise
+        except paramiko.SSHException as exc:
+            last_error = exc
+    raise paramiko.SSHException(f"unsupported private key: {last_error}")
+
+
+def auth_kwargs(endpoint: Dict[str, Any]) -> Dict[str, Any]:
+    """Build paramiko connect() auth arguments from password/private_key fields."""
+    kwargs: Dict[str, Any] = {
+        "username": endpoint["user"],
+        "allow_agent": allow_agent,
+        "look_for_keys": False,
+    }
+    if endpoint.get("password"):
+        kwargs["password"] = endpoint["password"]
+    if endpoint.get("private_key"):
+        kwargs["pkey"] = load_private_key(endpoint["private_key"], endpoint.get("private_key_passphrase"))
+    return kwargs
+
+
+class HostKeyError(paramiko.SSHException):
+    """主机密钥校验失败，code 写入结果的 error_code 字段。"""
+
+    def __init__(self, code: str, message: str):
+        super().__init__(message)
+        self.code = code
+
+
+def fingerprint_sha256(key: paramiko.PKey) -> str:
+    digest = base64.b64encode(hashlib.sha256(key.asbytes()).digest()).decode().rstrip("=")
+    return f"SHA256:{digest}"
+
+
+def normalize_fingerprint(fingerprint: str) -> str:
+    fingerprint = fingerprint.strip()
+    if fingerprint.startswith("SHA256:"):
+        fingerprint = fingerprint[len("SHA256:"):]
+    return fingerprint.rstrip("=")
+
+
+class PinnedFingerprintPolicy(paramiko.MissingHostKeyPolicy):
+    """只接受与 host_key_fingerprint 一致的主机密钥。"""
+
+    def __init__(self, fingerprint: str):
+        self.fingerprint = fingerprint
+
+    def missing_host_key(self, client, hostname, key):
+        actual = fingerprint_sha256(key)
+        if normalize_fingerprint(actual) != normalize_fingerprint(self.fingerprint):
+            raise HostKeyError("host_key_mismatch",
+                               f"{hostname} presented {actual}, does not match host_key_fingerprint {self.fingerprint}")
+
+
+class StrictPolicy(paramiko.MissingHostKeyPolicy):
+    def missing_host_key(self, client, hostname, key):
+        raise HostKeyError("host_key_unknown",
+                           f"{hostname} presented {fingerprint_sha256(key)}, not found in {known_hosts_file}")
+
+
+class AcceptNewPolicy(paramiko.MissingHostKeyPolicy):
+    """首次连接时把主机密钥追加到 known_hosts，多线程共用同一把锁。"""
+
+    def missing_host_key(self, client, hostname, key):
+        with known_hosts_lock:
+            entry = paramiko.hostkeys.HostKeyEntry([hostname], key)
+            with open(known_hosts_file, "a", encoding="utf-8") as f:
+                f.write(entry.to_line())
+        client.get_host_keys().add(hostname, key.get_name(), key)
 
+
+def new_ssh_client(endpoint: Dict[str, Any]) -> paramiko.SSHClient:
+    """按主机密钥策略创建 SSHClient；设置了 host_key_fingerprint 时只按指纹校验。"""
+    client = paramiko.SSHClient()
+    if endpoint.get("host_key_fingerprint"):
+        client.set_missing_host_key_policy(PinnedFingerprintPolicy(endpoint["host_key_fingerprint"]))
+        return client
+    if host_key_policy == "insecure":
+        client.set_missing_host_key_policy(paramiko.AutoAddPolicy())
+        return client
+
+    with known_hosts_lock:
+        known_hosts_dir = os.path.dirname(known_hosts_file)
+        if known_hosts_dir:
+            os.makedirs(known_hosts_dir, mode=0o700, exist_ok=True)
+        if not os.path.exists(known_hosts_file):
+            os.close(os.open(known_hosts_file, os.O_CREAT | os.O_WRONLY, 0o600))
+        client.load_host_keys(known_hosts_file)
+    if host_key_policy == "strict":
+        client.set_missing_host_key_policy(StrictPolicy())
+    else:
+        client.set_missing_host_key_policy(AcceptNewPolicy())
+    return client
+
+
+def host_key_error_code(exc: Exception) -> str:
+    if isinstance(exc, HostKeyError):
+        return exc.code
+    if isinstance(exc, paramiko.BadHostKeyException):
+        return "host_key_mismatch"
+    return ""
+
+
+def connect_jump_chain(jump_hosts: List[Dict[str, Any]], timeout: int) -> List[paramiko.SSHClient]:
+    """Log in to jump hosts in order, each hop over a direct-tcpip channel of the previous one.
+
+    Clients already connected are closed if a later hop fails.
+    """
+    clients: List[paramiko.SSHClient] = []
+    sock = None
+    try:
+        for i, hop in enumerate(jump_hosts):
+            if logger:
+                logger.info(f"Connecting to jump host {hop['host']}:{hop.get('port', 22)}")
+            client = new_ssh_client(hop)
+            clients.append(client)
+            client.connect(
+                hostname=hop["host"],
+                port=hop.get("port", 22),
+                sock=sock,
+                timeout=timeout,
+                **auth_kwargs(hop),
+            )
+            if i + 1 < len(jump_hosts):
+                sock = open_direct_channel(client, jump_hosts[i + 1])
+    except Exception:
+        close_clients(clients)
+        raise
+    return clients
+
+
+def open_direct_channel(client: paramiko.SSHClient, dest: Dict[str, Any]) -> paramiko.Channel:
+    transport = client.get_transport()
+    if transport is None or not transport.is_active():
+        raise RuntimeError("jump host connection is not active")
+    return transport.open_channel("direct-tcpip", (dest["host"], dest.get("port", 22)), ("127.0.0.1", 0))
+
+
+def close_clients(clients: List[paramiko.SSHClient]) -> None:
+    for client in reversed(clients):
+        client.close()
+
+
+class JumpPool:
+    """任务内共享的跳板机链连接池：最多建立 size 条跳板机链，各目标主机轮流在其上打开
+    direct-tcpip 通道，避免每台目标主机都重新与跳板机握手。没有跳板机时直连目标主机。"""
+
+    def __init__(self, jump_hosts: List[Dict[str, Any]], size: int, timeout: int):
+        self.jump_hosts = jump_hosts
+        self.timeout = timeout
+        self.slots = [{"lock": threading.Lock(), "clients": [], "error": None} for _ in range(max(1, size))]
+        self.counter = itertools.count()
+
+    def open_channel(self, target: Dict[str, Any]):
+        """返回到目标主机的通道；没有跳板机时返回 None 表示直连。"""
+        if not self.jump_hosts:
+            return None
+        slot = self.slots[next(self.counter) % len(self.slots)]
+        with slot["lock"]:
+            # 跳板机链建立失败后不再重试，分配到该链的目标主机直接返回同一错误
+            if slot["error"] is not None:
+                raise slot["error"]
+            clients = slot["clients"]
+            transport = clients[-1].get_transport() if clients else None
+            if transport is None or not transport.is_active():
+                # 尚未建立或连接已断开时重新建立
+                close_clients(clients)
+                slot["clients"] = []
+                try:
+                    slot["clients"] = connect_jump_chain(self.jump_hosts, self.timeout)
+                except Exception as exc:
+                    slot["error"] = exc
+                    raise
+            last = slot["clients"][-1]
+        return open_direct_channel(last, target)
+
+    def close(self) -> None:
+        for slot in self.slots:
+            with slot["lock"]:
+                close_clients(slot["clients"])
+                slot["clients"] = []
+
+
+def connect_target(pool: JumpPool, target: Dict[str, Any], timeout: int) -> paramiko.SSHClient:
+    """Connect to target through the task's jump host pool (directly when there are no jump hosts)."""
+    sock = pool.open_channel(target)
     if logger:
-        logger.info(f"Opening channel to target {target['host']}:{target.get('port', 22)}")
-    
-    target_client = paramiko.SSHClient()
-    target_client.set_missing_host_key_policy(paramiko.AutoAddPolicy())
-    target_client.connect(
-        hostname=target["host"],
-        port=target.get("port", 22),
-        username=target["user"],
-        password=target["password"],
-        sock=channel,
-        timeout=timeout,
-    )
+        logger.info(f"Connecting to target {target['host']}:{target.get('port', 22)}"
+                    f" via {len(pool.jump_hosts)} jump host(s)")
+
+    target_client = new_ssh_client(target)
+    try:
+        target_client.connect(
+            hostname=target["host"],
+            port=target.get("port", 22),
+            sock=sock,
+            timeout=timeout,
+            **auth_kwargs(target),
+        )
+    except Exception:
+        target_client.close()
+        raise
 
     if logger:
         logger.info(f"Successfully connected to target {target['host']}")
-    
-    return bastion_client, target_client
+
+    return target_client
+
+
+def exit_code_allowed(index: int, exit_code: int) -> bool:
+    if index < len(allowed_exit_codes) and allowed_exit_codes[index]:
+        return exit_code in allowed_exit_codes[index]
+    return exit_code == 0
 
 
 def run_commands(
-    bastion: Dict[str, Any],
+    pool: JumpPool,
     target: Dict[str, Any],
     commands: List[str],
     timeout: int,
+    index: int,
 ) -> Dict[str, Any]:
     result = build_result(target)
-    bastion_client = None
     target_client = None
 
     target_name = target.get("name", target.get("host", "unknown"))
@@ -119,27 +366,53 @@ def run_commands(
         if logger:
             logger.info(f"Starting command execution on {target_name} ({target.get('host')})")
         
-        bastion_client, target_client = connect_via_bastion(bastion, target, timeout)
+        emit_event(index, "connecting", result)
+        target_client = connect_target(pool, target, timeout)
         for i, command in enumerate(commands, 1):
+            result["current_command"] = i
+            emit_event(index, "running", result)
             if logger:
                 logger.info(f"Executing command {i}/{len(commands)} on {target_name}: {command}")
             
+            command_result = {"command": command, "started_at": utc_now()}
+            start = time.monotonic()
             stdin, stdout, stderr = target_client.exec_command(command, timeout=timeout)
-            out = stdout.read().decode(errors="ignore")
-            err = stderr.read().decode(errors="ignore")
+            channel = stdout.channel
+            # stderr 在后台线程读取，两路输出都按行实时上报
+            err_chunks: List[bytes] = []
+            err_thread = threading.Thread(
+                target=lambda: err_chunks.append(
+                    pump_output(channel.recv_stderr, lambda line: emit_output(index, i, "stderr", line))),
+                daemon=True,
+            )
+            err_thread.start()
+            out = pump_output(channel.recv, lambda line: emit_output(index, i, "stdout", line)).decode(errors="ignore")
+            err_thread.join(timeout)
+            err = b"".join(err_chunks).decode(errors="ignore")
             result["stdout"] += out
             result["stderr"] += err
             exit_code = stdout.channel.recv_exit_status()
             result["exit_code"] = exit_code
+            command_result.update({
+                "stdout": out,
+                "stderr": err,
+                "exit_code": exit_code,
+                "finished_at": utc_now(),
+                "duration_ms": int((time.monotonic() - start) * 1000),
+                "success": exit_code_allowed(i - 1, exit_code),
+            })
+            result["commands"].append(command_result)
             
             if logger:
                 logger.debug(f"Command {i} on {target_name} completed with exit_code={exit_code}")
             
-            if exit_code != 0:
-                result["success"] = False
+            if not command_result["success"]:
+                if on_error != "ignore":
+                    result["success"] = False
                 if logger:
                     logger.warning(f"Command {i} on {target_name} failed with exit_code={exit_code}")
-                break
+                if on_error == "stop":
+                    break
         
         if logger:
             logger.info(f"Command execution on {target_name} completed, success={result['success']}")
@@ -147,13 +420,16 @@ def run_commands(
         result["success"] = False
         error_msg = f"{type(exc).__name__}: {exc}"
         result["error"] = error_msg
+        error_code = host_key_error_code(exc)
+        if error_code:
+            result["error_code"] = error_code
         if logger:
             logger.error(f"Error executing commands on {target_name}: {error_msg}", exc_info=True)
     finally:
+        result.pop("current_command", None)
+        emit_event(index, "done", result)
         if target_client:
             target_client.close()
-        if bastion_client:
-            bastion_client.close()
         if logger:
             logger.debug(f"Closed connections for {target_name}")
 
@@ -162,41 +438,79 @@ def run_commands(
 
 def worker(
     task_queue: "queue.Queue[Dict[str, Any]]",
-    bastion: Dict[str, Any],
+    pool: JumpPool,
     commands: List[str],
     timeout: int,
     output: List[Dict[str, Any]],
 ):
     while True:
         try:
-            target = task_queue.get_nowait()
+            item = task_queue.get_nowait()
         except queue.Empty:
             return
-        result = run_commands(bastion, target, commands, timeout)
+        index, target = item
+        result = run_commands(pool, target, commands, timeout, index)
         output.append(result)
         task_queue.task_done()
 
 
+def load_payload(parser: argparse.ArgumentParser, args) -> Dict[str, Any]:
+    """读取任务参数：--payload-stdin 时从 stdin 读取 JSON 对象，否则解析命令行中的 JSON 参数"""
+    if args.payload_stdin:
+        return json.load(sys.stdin)
+    if args.targets is None or args.commands is None:
+        parser.error("--targets and --commands are required unless --payload-stdin is given")
+    return {
+        "jump_hosts": json.loads(args.jump_hosts),
+        "targets": json.loads(args.targets),
+        "commands": json.loads(args.commands),
+        "allowed_exit_codes": json.loads(args.allowed_exit_codes),
+    }
+
+
 def main() -> int:
-    global logger
+    global logger, events_stream, allow_agent, host_key_policy, known_hosts_file, on_error, allowed_exit_codes
     
-    parser = argparse.ArgumentParser(description="Execute commands via bastion using Paramiko.")
-    parser.add_argument("--bastion", required=True, help="JSON payload for bastion connection info.")
-    parser.add_argument("--targets", required=True, help="JSON array of target servers.")
-    parser.add_argument("--commands", required=True, help="JSON array of commands to execute sequentially.")
+    parser = argparse.ArgumentParser(description="Execute commands via jump hosts using Paramiko.")
+    parser.add_argument("--jump-hosts", default="[]",
+                        help="JSON array of jump hosts to pass through in order; empty connects directly.")
+    parser.add_argument("--targets", help="JSON array of target servers.")
+    parser.add_argument("--commands", help="JSON array of commands to execute sequentially.")
+    parser.add_argument("--on-error", default="stop", choices=["stop", "continue", "ignore"],
+                        help="What to do after a command fails (default: stop).")
+    parser.add_argument("--allowed-exit-codes", default="[]",
+                        help="JSON array of allowed exit code lists, aligned with --commands.")
     parser.add_argument("--concurrency", type=int, default=1, help="Max number of concurrent target connections.")
+    parser.add_argument("--jump-pool-size", type=int, default=1,
+                        help="Number of jump host chains shared by all targets of the task (default: 1).")
     parser.add_argument("--timeout", type=int, default=120, help="Timeout per SSH operation in seconds.")
     parser.add_argument("--log-level", default="INFO", choices=["DEBUG", "INFO", "WARNING", "ERROR"],
                         help="Log level (default: INFO)")
     parser.add_argument("--log-file", help="Log file path (optional)")
+    parser.add_argument("--events-fd", type=int, help="Write JSON-line progress events to this file descriptor.")
+    parser.add_argument("--allow-agent", action="store_true", help="Also try keys from ssh-agent (SSH_AUTH_SOCK).")
+    parser.add_argument("--host-key-policy", default="accept-new", choices=["strict", "accept-new", "insecure"],
+                        help="Host key verification policy (default: accept-new)")
+    parser.add_argument("--known-hosts", default="data/known_hosts", help="known_hosts file used by strict/accept-new.")
+    parser.add_argument("--payload-stdin", action="store_true",
+                        help="Read jump_hosts/targets/commands/allowed_exit_codes as a JSON object from stdin "
+                             "instead of the arguments above, keeping credentials out of argv.")
     args = parser.parse_args()
+    payload = load_payload(parser, args)
 
     # 初始化日志
     logger = setup_logging(args.log_level, args.log_file)
+    allow_agent = args.allow_agent
+    if args.events_fd is not None:
+        events_stream = os.fdopen(args.events_fd, "w", encoding="utf-8")
+    host_key_policy = args.host_key_policy
+    known_hosts_file = args.known_hosts
 
-    bastion = json.loads(args.bastion)
-    targets = json.loads(args.targets)
-    commands = json.loads(args.commands)
+    pool = JumpPool(payload.get("jump_hosts") or [], args.jump_pool_size, args.timeout)
+    targets = payload.get("targets") or []
+    commands = payload.get("commands") or []
+    on_error = args.on_error
+    allowed_exit_codes = [codes or [] for codes in payload.get("allowed_exit_codes") or []]
 
     if not commands:
         raise ValueError("commands cannot be empty")
@@ -204,8 +518,8 @@ def main() -> int:
         raise ValueError("targets cannot be empty")
 
     task_queue: "queue.Queue[Dict[str, Any]]" = queue.Queue()
-    for target in targets:
-        task_queue.put(target)
+    for index, target in enumerate(targets):
+        task_queue.put((index, target))
 
     results: List[Dict[str, Any]] = []
     threads: List[threading.Thread] = []
@@ -214,7 +528,7 @@ def main() -> int:
     for _ in range(worker_count):
         thread = threading.Thread(
             target=worker,
-            args=(task_queue, bastion, commands, args.timeout, results),
+            args=(task_queue, pool, commands, args.timeout, results),
             daemon=True,
         )
         thread.start()
@@ -222,6 +536,7 @@ def main() -> int:
 
     for thread in threads:
         thread.join()
+    pool.close()
 
     print(json.dumps(results, ensure_ascii=False))
     return 0
@@ -1,16 +1,29 @@
 #!/usr/bin/env python3
 """
-File upload script that connects to target servers via a bastion host using Paramiko.
+File upload script that connects to target servers, directly or through a
+chain of jump hosts, using Paramiko.
 The script uploads files from local directory to remote servers.
+The Go worker passes jump hosts and targets as a JSON object on stdin
+(--payload-stdin) so that credentials never appear in the process arguments.
 """
 
 import argparse
+import base64
+import fnmatch
+import hashlib
+import io
+import itertools
 import json
 import logging
 import os
+import posixpath
 import queue
+import secrets
+import socket
+import stat
 import sys
 import threading
+import time
 from typing import Any, Dict, List
 
 import paramiko
@@ -44,6 +57,29 @@ def setup_logging(log_level: str = "INFO", log_file: str = None):
 
 # 全局日志对象（在 main 中初始化）
 logger = None
+# 是否允许使用 ssh-agent（由 --allow-agent 控制）
+allow_agent = False
+# 主机密钥策略与 known_hosts 文件（由 --host-key-policy/--known-hosts 控制）
+host_key_policy = "accept-new"
+known_hosts_file = "data/known_hosts"
+# 上传模式（overwrite/skip_existing/sync）与 sync 的比较方式（size_mtime/sha256），由 --upload-mode/--sync-compare 控制
+upload_mode = "overwrite"
+sync_compare = "size_mtime"
+# 文件落盘方式（由 --mode/--owner/--group/--atomic/--backup-existing 控制）
+file_mode = ""
+file_owner = ""
+file_group = ""
+atomic = False
+backup_existing = False
+# 远端命令（sha256sum、chown、ln）的超时秒数（由 --timeout 控制）
+command_timeout = 120
+# 目录上传的过滤规则（payload 中的 include/exclude），解析为 (segments, dir_only)，与 internal/pathfilter 一致
+include_patterns: List = []
+exclude_patterns: List = []
+known_hosts_lock = threading.Lock()
+# 进度事件输出（由 --events-fd 指定，Go Worker 逐行读取）
+events_stream = None
+events_lock = threading.Lock()
 
 
 def build_result(target: Dict[str, Any]) -> Dict[str, Any]:
@@ -53,65 +89,572 @@ def build_result(target: Dict[str, Any]) -> Dict[str, Any]:
         "success": True,
         "uploaded_files": [],
         "failed_files": [],
+        "transferred": 0,
+        "skipped": 0,
+        "verified": 0,
+        "filtered": 0,
         "error": "",
     }
 
 
-def connect_via_bastion(bastion: Dict[str, Any], target: Dict[str, Any], timeout: int):
-    """Connect to target server via bastion host."""
-    if logger:
-        logger.info(f"Connecting to bastion {bastion['host']}:{bastion.get('port', 22)}")
-    
-    bastion_client = paramiko.SSHClient()
-    bastion_client.set_missing_host_key_policy(paramiko.AutoAddPolicy())
-    bastion_client.connect(
-        hostname=bastion["host"],
-        port=bastion.get("port", 22),
-        username=bastion["user"],
-        password=bastion["password"],
-        timeout=timeout,
-    )
+def emit_event(index: int, state: str, result: Dict[str, Any]) -> None:
+    """更新结果中的 state，并在指定 --events-fd 时输出一行 JSON 进度事件。"""
+    result["state"] = state
+    if events_stream is None:
+        return
+    line = json.dumps({"index": index, "state": state, "result": result}, ensure_ascii=False)
+    with events_lock:
+        try:
+            events_stream.write(line + "\n")
+            events_stream.flush()
+        except (BrokenPipeError, ValueError):
+            pass
 
-    transport = bastion_client.get_transport()
-    if transport is None:
-        if logger:
-            logger.error("Unable to obtain bastion transport")
-        raise RuntimeError("unable to obtain bastion transport")
 
-    dest_addr = (target["host"], target.get("port", 22))
-    local_addr = ("127.0.0.1", 0)
-    channel = transport.open_channel("direct-tcpip", dest_addr, local_addr)
+def load_private_key(key_data: str, passphrase: str = None) -> paramiko.PKey:
+    """Parse a PEM/OpenSSH private key of any supported type."""
+    last_error = None
+    for key_class in (paramiko.Ed25519Key, paramiko.ECDSAKey, paramiko.RSAKey):
+        try:
+            return key_class.from_private_key(io.StringIO(key_data), password=passphrase or None)
+        except paramiko.PasswordRequiredException:
+            raise
+        except paramiko.SSHException as exc:
+            last_error = exc
+    raise paramiko.SSHException(f"unsupported private key: {last_error}")
+
+
+def auth_kwargs(endpoint: Dict[str, Any]) -> Dict[str, Any]:
+    """Build paramiko connect() auth arguments from password/private_key fields."""
+    kwargs: Dict[str, Any] = {
+        "username": endpoint["user"],
+        "allow_agent": allow_agent,
+        "look_for_keys": False,
+    }
+    if endpoint.get("password"):
+        kwargs["password"] = endpoint["password"]
+    if endpoint.get("private_key"):
+        kwargs["pkey"] = load_private_key(endpoint["private_key"], endpoint.get("private_key_passphrase"))
+    return kwargs
+
+
+class HostKeyError(paramiko.SSHException):
+    """主机密钥校验失败，code 写入结果的 error_code 字段。"""
+
+    def __init__(self, code: str, message: str):
+        super().__init__(message)
+        self.code = code
+
+
+def fingerprint_sha256(key: paramiko.PKey) -> str:
+    digest = base64.b64encode(hashlib.sha256(key.asbytes()).digest()).decode().rstrip("=")
+    return f"SHA256:{digest}"
+
+
+def normalize_fingerprint(fingerprint: str) -> str:
+    fingerprint = fingerprint.strip()
+    if fingerprint.startswith("SHA256:"):
+        fingerprint = fingerprint[len("SHA256:"):]
+    return fingerprint.rstrip("=")
+
+
+class PinnedFingerprintPolicy(paramiko.MissingHostKeyPolicy):
+    """只接受与 host_key_fingerprint 一致的主机密钥。"""
+
+    def __init__(self, fingerprint: str):
+        self.fingerprint = fingerprint
+
+    def missing_host_key(self, client, hostname, key):
+        actual = fingerprint_sha256(key)
+        if normalize_fingerprint(actual) != normalize_fingerprint(self.fingerprint):
+            raise HostKeyError("host_key_mismatch",
+                               f"{hostname} presented {actual}, does not match host_key_fingerprint {self.fingerprint}")
+
+
+class StrictPolicy(paramiko.MissingHostKeyPolicy):
+    def missing_host_key(self, client, hostname, key):
+        raise HostKeyError("host_key_unknown",
+                           f"{hostname} presented {fingerprint_sha256(key)}, not found in {known_hosts_file}")
+
+
+class AcceptNewPolicy(paramiko.MissingHostKeyPolicy):
+    """首次连接时把主机密钥追加到 known_hosts，多线程共用同一把锁。"""
+
+    def missing_host_key(self, client, hostname, key):
+        with known_hosts_lock:
+            entry = paramiko.hostkeys.HostKeyEntry([hostname], key)
+            with open(known_hosts_file, "a", encoding="utf-8") as f:
+                f.write(entry.to_line())
+        client.get_host_keys().add(hostname, key.get_name(), key)
 
+
+def new_ssh_client(endpoint: Dict[str, Any]) -> paramiko.SSHClient:
+    """按主机密钥策略创建 SSHClient；设置了 host_key_fingerprint 时只按指纹校验。"""
+    client = paramiko.SSHClient()
+    if endpoint.get("host_key_fingerprint"):
+        client.set_missing_host_key_policy(PinnedFingerprintPolicy(endpoint["host_key_fingerprint"]))
+        return client
+    if host_key_policy == "insecure":
+        client.set_missing_host_key_policy(paramiko.AutoAddPolicy())
+        return client
+
+    with known_hosts_lock:
+        known_hosts_dir = os.path.dirname(known_hosts_file)
+        if known_hosts_dir:
+            os.makedirs(known_hosts_dir, mode=0o700, exist_ok=True)
+        if not os.path.exists(known_hosts_file):
+            os.close(os.open(known_hosts_file, os.O_CREAT | os.O_WRONLY, 0o600))
+        client.load_host_keys(known_hosts_file)
+    if host_key_policy == "strict":
+        client.set_missing_host_key_policy(StrictPolicy())
+    else:
+        client.set_missing_host_key_policy(AcceptNewPolicy())
+    return client
+
+
+def host_key_error_code(exc: Exception) -> str:
+    if isinstance(exc, HostKeyError):
+        return exc.code
+    if isinstance(exc, paramiko.BadHostKeyException):
+        return "host_key_mismatch"
+    return ""
+
+
+def connect_jump_chain(jump_hosts: List[Dict[str, Any]], timeout: int) -> List[paramiko.SSHClient]:
+    """Log in to jump hosts in order, each hop over a direct-tcpip channel of the previous one.
+
+    Clients already connected are closed if a later hop fails.
+    """
+    clients: List[paramiko.SSHClient] = []
+    sock = None
+    try:
+        for i, hop in enumerate(jump_hosts):
+            if logger:
+                logger.info(f"Connecting to jump host {hop['host']}:{hop.get('port', 22)}")
+            client = new_ssh_client(hop)
+            clients.append(client)
+            client.connect(
+                hostname=hop["host"],
+                port=hop.get("port", 22),
+                sock=sock,
+                timeout=timeout,
+                **auth_kwargs(hop),
+            )
+            if i + 1 < len(jump_hosts):
+                sock = open_direct_channel(client, jump_hosts[i + 1])
+    except Exception:
+        close_clients(clients)
+        raise
+    return clients
+
+
+def open_direct_channel(client: paramiko.SSHClient, dest: Dict[str, Any]) -> paramiko.Channel:
+    transport = client.get_transport()
+    if transport is None or not transport.is_active():
+        raise RuntimeError("jump host connection is not active")
+    return transport.open_channel("direct-tcpip", (dest["host"], dest.get("port", 22)), ("127.0.0.1", 0))
+
+
+def close_clients(clients: List[paramiko.SSHClient]) -> None:
+    for client in reversed(clients):
+        client.close()
+
+
+class JumpPool:
+    """任务内共享的跳板机链连接池：最多建立 size 条跳板机链，各目标主机轮流在其上打开
+    direct-tcpip 通道，避免每台目标主机都重新与跳板机握手。没有跳板机时直连目标主机。"""
+
+    def __init__(self, jump_hosts: List[Dict[str, Any]], size: int, timeout: int):
+        self.jump_hosts = jump_hosts
+        self.timeout = timeout
+        self.slots = [{"lock": threading.Lock(), "clients": [], "error": None} for _ in range(max(1, size))]
+        self.counter = itertools.count()
+
+    def open_channel(self, target: Dict[str, Any]):
+        """返回到目标主机的通道；没有跳板机时返回 None 表示直连。"""
+        if not self.jump_hosts:
+            return None
+        slot = self.slots[next(self.counter) % len(self.slots)]
+        with slot["lock"]:
+            # 跳板机链建立失败后不再重试，分配到该链的目标主机直接返回同一错误
+            if slot["error"] is not None:
+                raise slot["error"]
+            clients = slot["clients"]
+            transport = clients[-1].get_transport() if clients else None
+            if transport is None or not transport.is_active():
+                # 尚未建立或连接已断开时重新建立
+                close_clients(clients)
+                slot["clients"] = []
+                try:
+                    slot["clients"] = connect_jump_chain(self.jump_hosts, self.timeout)
+                except Exception as exc:
+                    slot["error"] = exc
+                    raise
+            last = slot["clients"][-1]
+        return open_direct_channel(last, target)
+
+    def close(self) -> None:
+        for slot in self.slots:
+            with slot["lock"]:
+                close_clients(slot["clients"])
+                slot["clients"] = []
+
+
+def connect_target(pool: JumpPool, target: Dict[str, Any], timeout: int) -> paramiko.SSHClient:
+    """Connect to target through the task's jump host pool (directly when there are no jump hosts)."""
+    sock = pool.open_channel(target)
     if logger:
-        logger.info(f"Opening channel to target {target['host']}:{target.get('port', 22)}")
-    
-    target_client = paramiko.SSHClient()
-    target_client.set_missing_host_key_policy(paramiko.AutoAddPolicy())
-    target_client.connect(
-        hostname=target["host"],
-        port=target.get("port", 22),
-        username=target["user"],
-        password=target["password"],
-        sock=channel,
-        timeout=timeout,
-    )
+        logger.info(f"Connecting to target {target['host']}:{target.get('port', 22)}"
+                    f" via {len(pool.jump_hosts)} jump host(s)")
+
+    target_client = new_ssh_client(target)
+    try:
+        target_client.connect(
+            hostname=target["host"],
+            port=target.get("port", 22),
+            sock=sock,
+            timeout=timeout,
+            **auth_kwargs(target),
+        )
+    except Exception:
+        target_client.close()
+        raise
 
     if logger:
         logger.info(f"Successfully connected to target {target['host']}")
-    
-    return bastion_client, target_client
+
+    return target_client
+
+
+def put_file(sftp, local_file: str, remote_file: str, prepare=None) -> Dict[str, Any]:
+    """Upload a single file and record its size, duration and sha256.
+    prepare 在打开远端文件后、写入内容前调用，用于先收紧权限"""
+    entry: Dict[str, Any] = {"local": local_file, "remote": remote_file, "bytes": 0, "duration_ms": 0}
+    start = time.monotonic()
+    try:
+        with open(local_file, "rb") as src, sftp.open(remote_file, "wb") as dst:
+            if prepare is not None:
+                prepare(dst)
+            dst.set_pipelined(True)
+            digest = hashlib.sha256()
+            for chunk in iter(lambda: src.read(32768), b""):
+                dst.write(chunk)
+                digest.update(chunk)
+                entry["bytes"] += len(chunk)
+        entry["sha256"] = digest.hexdigest()
+    except Exception as e:  # pylint: disable=broad-except
+        entry["error"] = str(e)
+    entry["duration_ms"] = int((time.monotonic() - start) * 1000)
+    return entry
+
+
+def file_sha256(path: str) -> str:
+    digest = hashlib.sha256()
+    with open(path, "rb") as f:
+        for chunk in iter(lambda: f.read(1 << 20), b""):
+            digest.update(chunk)
+    return digest.hexdigest()
+
+
+def shell_quote(value: str) -> str:
+    return "'" + value.replace("'", "'\\''") + "'"
+
+
+def remote_run(client: paramiko.SSHClient, cmd: str) -> str:
+    """在目标主机上执行命令，失败时抛出带 stderr 的异常；超时后关闭通道，不等待卡住的远端命令"""
+    name = cmd.split(" ", 1)[0]
+    _, stdout, stderr = client.exec_command(cmd, timeout=command_timeout)
+    try:
+        out = stdout.read().decode("utf-8", errors="replace")
+        err = stderr.read().decode("utf-8", errors="replace").strip()
+        if not stdout.channel.status_event.wait(command_timeout):
+            raise socket.timeout()
+    except socket.timeout:
+        stdout.channel.close()
+        raise RuntimeError(f"{name}: timed out after {command_timeout}s") from None
+    status = stdout.channel.recv_exit_status()
+    if status != 0:
+        if err.startswith(name + ":"):
+            raise RuntimeError(err)
+        raise RuntimeError(f"{name}: {err or f'exit status {status}'}")
+    return out
+
+
+def remote_sha256(client: paramiko.SSHClient, remote_file: str) -> str:
+    """在目标主机上执行 sha256sum 计算文件摘要"""
+    out = remote_run(client, "sha256sum -- " + shell_quote(remote_file))
+    # 文件名含反斜杠或换行时 sha256sum 会在输出行首加 \
+    fields = out.lstrip("\\").split()
+    if not fields or len(fields[0]) != 64:
+        raise RuntimeError(f"unexpected sha256sum output: {out!r}")
+    return fields[0]
+
+
+def unchanged(client: paramiko.SSHClient, sftp, local_file: str, remote_file: str) -> bool:
+    """skip_existing 只看远端文件是否存在，sync 按 sync_compare 比较内容"""
+    if upload_mode == "overwrite":
+        return False
+    try:
+        remote = sftp.stat(remote_file)
+    except FileNotFoundError:
+        return False
+    if upload_mode == "skip_existing":
+        return True
+    local = os.stat(local_file)
+    if not stat.S_ISREG(remote.st_mode or 0) or remote.st_size != local.st_size:
+        return False
+    if sync_compare == "size_mtime":
+        return int(remote.st_mtime or 0) == int(local.st_mtime)
+    return file_sha256(local_file) == remote_sha256(client, remote_file)
+
+
+def verify_file(client: paramiko.SSHClient, sftp, entry: Dict[str, Any], written: str,
+                result: Dict[str, Any]) -> None:
+    """用远端 sha256sum 校验写入的文件，并把远端修改时间设为与本地一致"""
+    try:
+        remote_sum = remote_sha256(client, written)
+    except Exception as e:  # pylint: disable=broad-except
+        entry["error"] = f"verify checksum: {e}"
+        return
+    if remote_sum != entry["sha256"]:
+        entry["error"] = f"checksum mismatch: local {entry['sha256']}, remote {remote_sum}"
+        return
+    result["verified"] += 1
+    try:
+        mtime = os.stat(entry["local"]).st_mtime
+        sftp.utime(written, (mtime, mtime))
+    except Exception as e:  # pylint: disable=broad-except
+        entry["error"] = f"set remote mtime: {e}"
+
+
+def record(result: Dict[str, Any], entry: Dict[str, Any]) -> None:
+    if entry.get("error"):
+        result["failed_files"].append(entry)
+        result["success"] = False
+        if logger:
+            logger.warning(f"Failed to upload {entry['local']} to {result['name']}: {entry['error']}")
+        return
+    result["uploaded_files"].append(entry)
+    result["transferred"] += 1
+
+
+def transfer_file(client: paramiko.SSHClient, sftp, local_file: str, remote_file: str,
+                  result: Dict[str, Any]) -> None:
+    """按 upload_mode 处理单个文件：跳过未变化的文件，sync 模式传输后校验 sha256"""
+    try:
+        skip = unchanged(client, sftp, local_file, remote_file)
+    except Exception as e:  # pylint: disable=broad-except
+        record(result, {"local": local_file, "remote": remote_file, "bytes": 0, "duration_ms": 0, "error": str(e)})
+        return
+    if skip:
+        result["skipped"] += 1
+        return
+    record(result, place_file(client, sftp, local_file, remote_file, result))
+
+
+def temp_name(remote_file: str) -> str:
+    """与目标文件位于同一目录的临时文件名，保证 rename 不跨文件系统"""
+    directory, name = posixpath.split(remote_file)
+    return posixpath.join(directory, f".{name}.gocerery-{secrets.token_hex(6)}.tmp")
+
+
+def backup_name(remote_file: str) -> str:
+    return f"{remote_file}.{time.strftime('%Y%m%d%H%M%S')}.bak"
+
+
+def place_file(client: paramiko.SSHClient, sftp, local_file: str, remote_file: str,
+               result: Dict[str, Any]) -> Dict[str, Any]:
+    """写入单个文件并设置权限、属主；atomic 时写入临时文件，sync 校验通过后再替换目标文件。
+    对应 Go 版本的 fileSyncer.place"""
+    existing = None
+    if atomic or backup_existing:
+        try:
+            existing = sftp.stat(remote_file)
+        except FileNotFoundError:
+            pass
+        except Exception as e:  # pylint: disable=broad-except
+            return {"local": local_file, "remote": remote_file, "bytes": 0, "duration_ms": 0,
+                    "error": f"stat remote file: {e}"}
+
+    written, backup = remote_file, ""
+    if atomic:
+        written = temp_name(remote_file)
+    elif backup_existing and existing is not None:
+        # 非原子上传时先把旧文件改名为备份，写入失败再改回
+        backup = backup_name(remote_file)
+        try:
+            sftp.rename(remote_file, backup)
+        except Exception as e:  # pylint: disable=broad-except
+            return {"local": local_file, "remote": remote_file, "bytes": 0, "duration_ms": 0,
+                    "error": f"backup existing file: {e}"}
+
+    # 权限与属主在写入内容前设置，上传过程中文件不会以服务端默认权限暴露
+    attrs: Dict[str, Any] = {}
+    opened = False
+
+    def prepare(dst) -> None:
+        nonlocal opened
+        opened = True
+        apply_attrs(client, dst, attrs, written, existing)
+
+    entry = put_file(sftp, local_file, written, prepare)
+    entry["remote"] = remote_file
+    if atomic:
+        entry["atomic"] = True
+    entry.update(attrs)
+    perm = file_perm(existing)
+    if not entry.get("error") and perm is not None and perm & (stat.S_ISUID | stat.S_ISGID):
+        # 非 root 写入内容会清除 setuid/setgid，写完后再设置一次
+        try:
+            sftp.chmod(written, perm)
+        except Exception as e:  # pylint: disable=broad-except
+            entry["error"] = f"chmod: {e}"
+    if not entry.get("error") and upload_mode == "sync":
+        verify_file(client, sftp, entry, written, result)
+    if not entry.get("error") and atomic:
+        if backup_existing and existing is not None:
+            # 用硬链接保留旧版本，目标路径在替换前始终可用
+            backup = backup_name(remote_file)
+            try:
+                remote_run(client, f"ln -- {shell_quote(remote_file)} {shell_quote(backup)}")
+            except Exception as e:  # pylint: disable=broad-except
+                entry["error"] = f"backup existing file: {e}"
+                backup = ""
+        if not entry.get("error"):
+            try:
+                sftp.posix_rename(written, remote_file)
+            except Exception as e:  # pylint: disable=broad-except
+                entry["error"] = f"rename into place: {e}"
+
+    if entry.get("error"):
+        restored = False
+        try:
+            if atomic:
+                sftp.remove(written)
+            elif backup:
+                sftp.posix_rename(backup, remote_file)
+                backup, restored = "", True
+        except Exception:  # pylint: disable=broad-except
+            pass
+        if not atomic and not restored and opened:
+            # 没有可恢复的备份时删除已截断、未写完或未通过校验的文件，不在目标路径留下内容不一致的文件
+            try:
+                sftp.remove(remote_file)
+            except Exception:  # pylint: disable=broad-except
+                pass
+    if backup:
+        entry["backup"] = backup
+    return entry
+
+
+def file_perm(existing):
+    """要设置的权限；atomic 且未指定 mode 时沿用被替换文件的权限"""
+    if file_mode:
+        return int(file_mode, 8)
+    if atomic and existing is not None:
+        return stat.S_IMODE(existing.st_mode or 0)
+    return None
+
+
+def apply_attrs(client: paramiko.SSHClient, dst, attrs: Dict[str, Any], written: str, existing) -> None:
+    """在写入内容前设置属主与权限；chown 会清除 setuid/setgid，所以先 chown 再 chmod"""
+    if file_owner or file_group:
+        # 远端用户名与组名只能由目标主机解析，交给 chown/chgrp 处理
+        if file_owner:
+            spec = file_owner + (f":{file_group}" if file_group else "")
+            cmd = f"chown -- {shell_quote(spec)} {shell_quote(written)}"
+        else:
+            cmd = f"chgrp -- {shell_quote(file_group)} {shell_quote(written)}"
+        remote_run(client, cmd)
+        if file_owner:
+            attrs["owner"] = file_owner
+        if file_group:
+            attrs["group"] = file_group
+
+    perm = file_perm(existing)
+    if perm is not None:
+        try:
+            dst.chmod(perm)
+        except Exception as e:  # pylint: disable=broad-except
+            raise RuntimeError(f"chmod: {e}") from e
+        attrs["mode"] = f"{perm:04o}"
+
+
+def parse_pattern(raw: str):
+    """解析 gitignore 风格的规则：不含 / 的规则匹配任意层级，结尾的 / 只匹配目录"""
+    if not raw.strip():
+        raise ValueError("pattern cannot be empty")
+    if raw.startswith("!"):
+        raise ValueError(f"negated patterns are not supported, use include instead: {raw}")
+    if "\\" in raw:
+        raise ValueError(f"backslash is not supported in patterns: {raw}")
+    dir_only = raw.endswith("/")
+    body = raw.strip("/")
+    if not body:
+        raise ValueError(f"invalid pattern: {raw}")
+    anchored = raw.startswith("/") or "/" in body
+    segments = [] if anchored else ["**"]
+    for seg in body.split("/"):
+        if seg in ("", ".", ".."):
+            raise ValueError(f"invalid pattern: {raw}")
+        # fnmatch 只认 [!...] 表示取反
+        segments.append(seg if seg == "**" else seg.replace("[^", "[!"))
+    return segments, dir_only
+
+
+def match_segments(pattern: List[str], name: List[str]) -> bool:
+    """逐段匹配，** 匹配任意层级；结尾的 ** 至少匹配一层"""
+    while pattern:
+        if pattern[0] == "**":
+            pattern = pattern[1:]
+            if not pattern:
+                return len(name) > 0
+            return any(match_segments(pattern, name[i:]) for i in range(len(name)))
+        if not name or not fnmatch.fnmatchcase(name[0], pattern[0]):
+            return False
+        pattern, name = pattern[1:], name[1:]
+    return not name
+
+
+def match_any(patterns: List, segments: List[str], is_dir: bool) -> bool:
+    return any((is_dir or not dir_only) and match_segments(p, segments) for p, dir_only in patterns)
+
+
+def filtered_out(rel: str, is_dir: bool) -> bool:
+    """命中 exclude 的文件或目录被跳过；配置了 include 时，文件本身或所在的某一级目录需命中 include"""
+    segments = rel.split("/")
+    if match_any(exclude_patterns, segments, is_dir):
+        return True
+    if is_dir or not include_patterns:
+        return False
+    return not any(match_any(include_patterns, segments[:i], i < len(segments))
+                   for i in range(len(segments), 0, -1))
+
+
+def make_remote_dirs(sftp, remote_dir: str, made: set) -> None:
+    """逐级创建远程目录，made 记录已确认存在的目录"""
+    if remote_dir in made:
+        return
+    parent = posixpath.dirname(remote_dir)
+    if parent and parent != remote_dir:
+        make_remote_dirs(sftp, parent, made)
+    try:
+        sftp.stat(remote_dir)
+    except FileNotFoundError:
+        sftp.mkdir(remote_dir)
+    made.add(remote_dir)
 
 
 def upload_files(
-    bastion: Dict[str, Any],
+    pool: JumpPool,
     target: Dict[str, Any],
     local_path: str,
     remote_path: str,
     timeout: int,
+    index: int,
 ) -> Dict[str, Any]:
     """Upload files from local directory to remote server."""
     result = build_result(target)
-    bastion_client = None
     target_client = None
     sftp = None
 
@@ -122,8 +665,10 @@ def upload_files(
             logger.info(f"Starting file upload to {target_name} ({target.get('host')})")
             logger.info(f"Local path: {local_path}, Remote path: {remote_path}")
         
-        bastion_client, target_client = connect_via_bastion(bastion, target, timeout)
+        emit_event(index, "connecting", result)
+        target_client = connect_target(pool, target, timeout)
         sftp = target_client.open_sftp()
+        emit_event(index, "running", result)
 
         # 确保本地路径存在
         if not os.path.exists(local_path):
@@ -146,36 +691,48 @@ def upload_files(
                 result["error"] = f"failed to create remote directory: {e}"
                 return result
 
-        uploaded = []
-        failed = []
+        failed = result["failed_files"]
+        last_event = time.monotonic()
 
         # 如果是文件，直接上传
         if os.path.isfile(local_path):
             remote_file_path = os.path.join(remote_path, os.path.basename(local_path)).replace("\\", "/")
-            try:
-                sftp.put(local_path, remote_file_path)
-                uploaded.append({"local": local_path, "remote": remote_file_path})
-            except Exception as e:
-                failed.append({"local": local_path, "remote": remote_file_path, "error": str(e)})
-                result["success"] = False
+            transfer_file(target_client, sftp, local_path, remote_file_path, result)
         # 如果是目录，递归上传
         elif os.path.isdir(local_path):
+            # 配置了 include 时目录在上传第一个文件前才创建，避免留下只含被过滤文件的空目录
+            made = {remote_path}
             for root, dirs, files in os.walk(local_path):
                 # 计算相对路径
                 rel_path = os.path.relpath(root, local_path)
                 if rel_path == ".":
+                    rel_root = ""
                     remote_dir = remote_path
                 else:
+                    rel_root = rel_path.replace("\\", "/")
                     remote_dir = os.path.join(remote_path, rel_path).replace("\\", "/")
 
+                def rel_name(name: str) -> str:
+                    return f"{rel_root}/{name}" if rel_root else name
+
+                # 被排除的目录不再展开；目录的符号链接与 Go 版本一样不参与过滤
+                kept = []
+                for name in dirs:
+                    if os.path.islink(os.path.join(root, name)):
+                        continue
+                    if filtered_out(rel_name(name), True):
+                        result["filtered"] += 1
+                        continue
+                    kept.append(name)
+                dirs[:] = kept
+
                 # 确保远程目录存在
-                try:
-                    sftp.stat(remote_dir)
-                except FileNotFoundError:
+                if not include_patterns:
                     try:
-                        sftp.mkdir(remote_dir)
+                        make_remote_dirs(sftp, remote_dir, made)
                     except Exception as e:
-                        failed.append({"local": root, "remote": remote_dir, "error": f"failed to create directory: {e}"})
+                        failed.append({"local": root, "remote": remote_dir, "bytes": 0, "duration_ms": 0,
+                                       "error": f"failed to create directory: {e}"})
                         result["success"] = False
                         continue
 
@@ -183,20 +740,32 @@ def upload_files(
                 for file in files:
                     local_file = os.path.join(root, file)
                     remote_file = os.path.join(remote_dir, file).replace("\\", "/")
+                    # 与 Go 版本一样只上传普通文件，跳过符号链接、管道等
                     try:
-                        if logger:
-                            logger.debug(f"Uploading {local_file} to {remote_file} on {target_name}")
-                        sftp.put(local_file, remote_file)
-                        uploaded.append({"local": local_file, "remote": remote_file})
+                        if not stat.S_ISREG(os.lstat(local_file).st_mode):
+                            continue
+                    except OSError as e:
+                        failed.append({"local": local_file, "remote": remote_file, "bytes": 0, "duration_ms": 0,
+                                       "error": str(e)})
+                        result["success"] = False
+                        continue
+                    if filtered_out(rel_name(file), False):
+                        result["filtered"] += 1
+                        continue
+                    try:
+                        make_remote_dirs(sftp, remote_dir, made)
                     except Exception as e:
-                        error_msg = str(e)
-                        failed.append({"local": local_file, "remote": remote_file, "error": error_msg})
+                        failed.append({"local": local_file, "remote": remote_file, "bytes": 0, "duration_ms": 0,
+                                       "error": f"failed to create directory: {e}"})
                         result["success"] = False
-                        if logger:
-                            logger.warning(f"Failed to upload {local_file} to {target_name}: {error_msg}")
-
-        result["uploaded_files"] = uploaded
-        result["failed_files"] = failed
+                        continue
+                    if logger:
+                        logger.debug(f"Uploading {local_file} to {remote_file} on {target_name}")
+                    transfer_file(target_client, sftp, local_file, remote_file, result)
+                    # 文件较多时按间隔上报进度
+                    if time.monotonic() - last_event >= 0.5:
+                        last_event = time.monotonic()
+                        emit_event(index, "running", result)
 
         if len(failed) > 0:
             result["success"] = False
@@ -207,28 +776,32 @@ def upload_files(
         error_msg = f"{type(exc).__name__}: {exc}"
         result["success"] = False
         result["error"] = error_msg
+        error_code = host_key_error_code(exc)
+        if error_code:
+            result["error_code"] = error_code
         if logger:
             logger.error(f"Error uploading files to {target_name}: {error_msg}", exc_info=True)
     finally:
+        emit_event(index, "done", result)
         if sftp:
             sftp.close()
         if target_client:
             target_client.close()
-        if bastion_client:
-            bastion_client.close()
         if logger:
             logger.debug(f"Closed connections for {target_name}")
 
     if logger:
         logger.info(f"File upload to {target_name} completed, success={result['success']}, "
-                   f"uploaded={len(result['uploaded_files'])}, failed={len(result['failed_files'])}")
+                   f"transferred={result['transferred']}, skipped={result['skipped']}, "
+                   f"verified={result['verified']}, filtered={result['filtered']}, "
+                   f"failed={len(result['failed_files'])}")
 
     return result
 
 
 def worker(
     task_queue: "queue.Queue[Dict[str, Any]]",
-    bastion: Dict[str, Any],
+    pool: JumpPool,
     local_path: str,
     remote_path: str,
     timeout: int,
@@ -237,34 +810,94 @@ def worker(
     """Worker thread for concurrent file uploads."""
     while True:
         try:
-            target = task_queue.get_nowait()
+            item = task_queue.get_nowait()
         except queue.Empty:
             return
-        result = upload_files(bastion, target, local_path, remote_path, timeout)
+        index, target = item
+        result = upload_files(pool, target, local_path, remote_path, timeout, index)
         output.append(result)
         task_queue.task_done()
 
 
+def load_payload(parser: argparse.ArgumentParser, args) -> Dict[str, Any]:
+    """读取任务参数：--payload-stdin 时从 stdin 读取 JSON 对象，否则解析命令行中的 JSON 参数"""
+    if args.payload_stdin:
+        return json.load(sys.stdin)
+    if args.targets is None:
+        parser.error("--targets is required unless --payload-stdin is given")
+    return {
+        "jump_hosts": json.loads(args.jump_hosts),
+        "targets": json.loads(args.targets),
+        "include": args.include or [],
+        "exclude": args.exclude or [],
+    }
+
+
 def main() -> int:
-    global logger
+    global logger, events_stream, allow_agent, host_key_policy, known_hosts_file, upload_mode, sync_compare
+    global file_mode, file_owner, file_group, atomic, backup_existing, include_patterns, exclude_patterns
+    global command_timeout
     
-    parser = argparse.ArgumentParser(description="Upload files via bastion using Paramiko.")
-    parser.add_argument("--bastion", required=True, help="JSON payload for bastion connection info.")
-    parser.add_argument("--targets", required=True, help="JSON array of target servers.")
+    parser = argparse.ArgumentParser(description="Upload files via jump hosts using Paramiko.")
+    parser.add_argument("--jump-hosts", default="[]",
+                        help="JSON array of jump hosts to pass through in order; empty connects directly.")
+    parser.add_argument("--targets", help="JSON array of target servers.")
     parser.add_argument("--local-path", required=True, help="Local file or directory path to upload.")
     parser.add_argument("--remote-path", required=True, help="Remote directory path on target servers.")
+    parser.add_argument("--upload-mode", default="overwrite", choices=["overwrite", "skip_existing", "sync"],
+                        help="overwrite: always upload; skip_existing: skip files that exist remotely; "
+                             "sync: upload changed files only and verify sha256 afterwards (default: overwrite)")
+    parser.add_argument("--sync-compare", default="size_mtime", choices=["size_mtime", "sha256"],
+                        help="How sync mode detects changed files (default: size_mtime)")
+    parser.add_argument("--mode", default="", help="Octal permission applied to uploaded files, e.g. 0644.")
+    parser.add_argument("--owner", default="", help="Owner applied to uploaded files (chown on the target).")
+    parser.add_argument("--group", default="", help="Group applied to uploaded files.")
+    parser.add_argument("--atomic", action="store_true",
+                        help="Upload to a temporary file in the same directory and rename it into place.")
+    parser.add_argument("--backup-existing", action="store_true",
+                        help="Keep the replaced file as <name>.<timestamp>.bak.")
+    parser.add_argument("--include", action="append",
+                        help="gitignore-style pattern; directory uploads only send matching files (repeatable).")
+    parser.add_argument("--exclude", action="append",
+                        help="gitignore-style pattern; matching files and directories are skipped (repeatable).")
     parser.add_argument("--concurrency", type=int, default=1, help="Max number of concurrent target connections.")
+    parser.add_argument("--jump-pool-size", type=int, default=1,
+                        help="Number of jump host chains shared by all targets of the task (default: 1).")
     parser.add_argument("--timeout", type=int, default=120, help="Timeout per SSH operation in seconds.")
     parser.add_argument("--log-level", default="INFO", choices=["DEBUG", "INFO", "WARNING", "ERROR"],
                         help="Log level (default: INFO)")
     parser.add_argument("--log-file", help="Log file path (optional)")
+    parser.add_argument("--events-fd", type=int, help="Write JSON-line progress events to this file descriptor.")
+    parser.add_argument("--allow-agent", action="store_true", help="Also try keys from ssh-agent (SSH_AUTH_SOCK).")
+    parser.add_argument("--host-key-policy", default="accept-new", choices=["strict", "accept-new", "insecure"],
+                        help="Host key verification policy (default: accept-new)")
+    parser.add_argument("--known-hosts", default="data/known_hosts", help="known_hosts file used by strict/accept-new.")
+    parser.add_argument("--payload-stdin", action="store_true",
+                        help="Read jump_hosts/targets/include/exclude as a JSON object from stdin instead of "
+                             "the arguments above, keeping credentials out of argv.")
     args = parser.parse_args()
+    payload = load_payload(parser, args)
 
     # 初始化日志
     logger = setup_logging(args.log_level, args.log_file)
-
-    bastion = json.loads(args.bastion)
-    targets = json.loads(args.targets)
+    allow_agent = args.allow_agent
+    if args.events_fd is not None:
+        events_stream = os.fdopen(args.events_fd, "w", encoding="utf-8")
+    host_key_policy = args.host_key_policy
+    known_hosts_file = args.known_hosts
+    upload_mode = args.upload_mode
+    sync_compare = args.sync_compare
+    file_mode = args.mode
+    file_owner = args.owner
+    file_group = args.group
+    atomic = args.atomic
+    backup_existing = args.backup_existing
+    command_timeout = args.timeout
+    include_patterns = [parse_pattern(p) for p in payload.get("include") or []]
+    exclude_patterns = [parse_pattern(p) for p in payload.get("exclude") or []]
+
+    pool = JumpPool(payload.get("jump_hosts") or [], args.jump_pool_size, args.timeout)
+    targets = payload.get("targets") or []
 
     if not targets:
         raise ValueError("targets cannot be empty")
@@ -273,8 +906,8 @@ def main() -> int:
         raise ValueError(f"local path does not exist: {args.local_path}")
 
     task_queue: "queue.Queue[Dict[str, Any]]" = queue.Queue()
-    for target in targets:
-        task_queue.put(target)
+    for index, target in enumerate(targets):
+        task_queue.put((index, target))
 
     results: List[Dict[str, Any]] = []
     threads: List[threading.Thread] = []
@@ -283,7 +916,7 @@ def main() -> int:
     for _ in range(worker_count):
         thread = threading.Thread(
             target=worker,
-            args=(task_queue, bastion, args.local_path, args.remote_path, args.timeout, results),
+            args=(task_queue, pool, args.local_path, args.remote_path, args.timeout, results),
             daemon=True,
         )
         thread.start()
@@ -291,6 +924,7 @@ def main() -> int:
 
     for thread in threads:
         thread.join()
+    pool.close()
 
     print(json.dumps(results, ensure_ascii=False))
     return 0